@@ -0,0 +1,304 @@
+// Package activity reads recorded cardio activities from GPX, TCX and
+// Garmin FIT files and turns them into a workout with a track.
+package activity
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatAuto Format = "auto"
+	FormatGPX  Format = "gpx"
+	FormatTCX  Format = "tcx"
+	FormatFIT  Format = "fit"
+)
+
+// Sports, as stored on a track.
+const (
+	SportRunning  = "running"
+	SportCycling  = "cycling"
+	SportWalking  = "walking"
+	SportHiking   = "hiking"
+	SportSwimming = "swimming"
+	SportOther    = "other"
+)
+
+var (
+	ErrUnknownFormat = errors.New("not a GPX, TCX or FIT file")
+	ErrNoTrack       = errors.New("file has no timed track points")
+)
+
+// Activity is a parsed recording.
+type Activity struct {
+	Format Format
+	// Name is the activity name from the file, if it has one.
+	Name     string
+	Sport    string
+	Calories int
+	Points   []store.TrackPoint
+}
+
+// Parse reads data in format, or the detected one for FormatAuto.
+func Parse(data []byte, format Format) (*Activity, error) {
+	if format == FormatAuto {
+		var err error
+		format, err = detectFormat(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var a *Activity
+	var err error
+	switch format {
+	case FormatGPX:
+		a, err = parseGPX(data)
+	case FormatTCX:
+		a, err = parseTCX(data)
+	case FormatFIT:
+		a, err = parseFIT(data)
+	default:
+		return nil, fmt.Errorf("unknown activity format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a.Format = format
+
+	// points without a time cannot be placed on the timeline
+	a.Points = slices.DeleteFunc(a.Points, func(p store.TrackPoint) bool { return p.Time.IsZero() })
+	slices.SortStableFunc(a.Points, func(a, b store.TrackPoint) int { return a.Time.Compare(b.Time) })
+	if len(a.Points) < 2 {
+		return nil, ErrNoTrack
+	}
+
+	return a, nil
+}
+
+func detectFormat(data []byte) (Format, error) {
+	if len(data) >= 12 && string(data[8:12]) == ".FIT" {
+		return FormatFIT, nil
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", ErrUnknownFormat
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			switch start.Name.Local {
+			case "gpx":
+				return FormatGPX, nil
+			case "TrainingCenterDatabase":
+				return FormatTCX, nil
+			}
+			return "", ErrUnknownFormat
+		}
+	}
+}
+
+// normalizeSport maps the sport names used by apps and devices to ours.
+func normalizeSport(s string) string {
+	switch s = strings.ToLower(strings.TrimSpace(s)); {
+	case strings.Contains(s, "run"):
+		return SportRunning
+	case strings.Contains(s, "bik"), strings.Contains(s, "cycl"), strings.Contains(s, "ride"):
+		return SportCycling
+	case strings.Contains(s, "walk"):
+		return SportWalking
+	case strings.Contains(s, "hik"):
+		return SportHiking
+	case strings.Contains(s, "swim"):
+		return SportSwimming
+	}
+	return SportOther
+}
+
+// exerciseNames are the catalog exercises activities are logged as.
+var exerciseNames = map[string]string{
+	SportRunning:  "Running",
+	SportCycling:  "Cycling",
+	SportWalking:  "Walking",
+	SportHiking:   "Walking",
+	SportSwimming: "Swimming",
+	SportOther:    "Cardio",
+}
+
+// Track returns the track of the activity with its summary.
+func (a *Activity) Track() *store.Track {
+	return &store.Track{
+		Format:  string(a.Format),
+		Sport:   a.Sport,
+		Summary: Summarize(a.Points),
+		Points:  a.Points,
+	}
+}
+
+// Workout returns the workout the activity is saved as: one cardio entry for
+// the moving time.
+func (a *Activity) Workout(track *store.Track) *store.Workout {
+	summary := track.Summary
+
+	name := a.Name
+	if name == "" {
+		name = strings.ToUpper(a.Sport[:1]) + a.Sport[1:]
+	}
+	suffix := " (" + summary.StartedAt.UTC().Format("2006-01-02 15:04") + ")"
+	if runes := []rune(name); len(runes) > 255-len(suffix) {
+		name = string(runes[:255-len(suffix)])
+	}
+
+	moving := max(1, summary.MovingSeconds)
+
+	entry := store.WorkoutEntries{
+		Type:            store.EntryCardio,
+		ExerciseName:    exerciseNames[a.Sport],
+		Sets:            1,
+		DurationSeconds: &moving,
+		AvgHeartRate:    summary.AvgHeartRate,
+		MaxHeartRate:    summary.MaxHeartRate,
+	}
+	if summary.DistanceMeters > 0 {
+		km := math.Round(summary.DistanceMeters) / 1000
+		entry.Distance = &km
+		entry.DistanceUnit = store.UnitKilometers
+	}
+	if summary.ElevationGainMeters > 0 {
+		gain := summary.ElevationGainMeters
+		entry.ElevationGainMeters = &gain
+	}
+
+	return &store.Workout{
+		Title:           name + suffix,
+		DurationMinutes: max(1, int(math.Ceil(float64(summary.ElapsedSeconds)/60))),
+		CaloriesBurned:  a.Calories,
+		PerformedAt:     summary.StartedAt,
+		Entries:         []store.WorkoutEntries{entry},
+	}
+}
+
+const (
+	// movingSpeed is the speed, in m/s, below which time counts as paused.
+	movingSpeed = 0.5
+	// elevationThreshold filters GPS and barometer noise: climbs and
+	// descents count once they exceed it.
+	elevationThreshold = 3.0
+)
+
+// Summarize computes the summary stats of points, which must be sorted by
+// time.
+func Summarize(points []store.TrackPoint) store.TrackSummary {
+	summary := store.TrackSummary{}
+	if len(points) == 0 {
+		return summary
+	}
+
+	first, last := points[0], points[len(points)-1]
+	summary.StartedAt = first.Time
+	summary.ElapsedSeconds = int(last.Time.Sub(first.Time).Seconds())
+
+	hasDistance := false
+	moving := 0.0
+	for i := 1; i < len(points); i++ {
+		dist, ok := segmentDistance(&points[i-1], &points[i])
+		hasDistance = hasDistance || ok
+		summary.DistanceMeters += dist
+
+		dt := points[i].Time.Sub(points[i-1].Time).Seconds()
+		if dt > 0 && dist/dt >= movingSpeed {
+			moving += dt
+		}
+	}
+	summary.MovingSeconds = int(moving)
+	if !hasDistance {
+		// nothing to tell pauses apart by
+		summary.MovingSeconds = summary.ElapsedSeconds
+	}
+
+	var reference *float64
+	for _, p := range points {
+		if p.Elevation == nil {
+			continue
+		}
+		if reference == nil {
+			reference = p.Elevation
+			continue
+		}
+
+		switch diff := *p.Elevation - *reference; {
+		case diff >= elevationThreshold:
+			summary.ElevationGainMeters += diff
+			reference = p.Elevation
+		case -diff >= elevationThreshold:
+			summary.ElevationLossMeters -= diff
+			reference = p.Elevation
+		}
+	}
+
+	hrSum, hrCount, hrMax := 0, 0, 0
+	for _, p := range points {
+		if p.HeartRate != nil {
+			hrSum += *p.HeartRate
+			hrCount++
+			hrMax = max(hrMax, *p.HeartRate)
+		}
+	}
+	if hrCount > 0 {
+		avg := int(math.Round(float64(hrSum) / float64(hrCount)))
+		summary.AvgHeartRate = &avg
+		summary.MaxHeartRate = &hrMax
+	}
+
+	summary.DistanceMeters = math.Round(summary.DistanceMeters*10) / 10
+	summary.ElevationGainMeters = math.Round(summary.ElevationGainMeters*10) / 10
+	summary.ElevationLossMeters = math.Round(summary.ElevationLossMeters*10) / 10
+
+	return summary
+}
+
+// segmentDistance returns the meters between two points, preferring the
+// device's distance over the GPS positions. ok is false when neither is
+// known.
+func segmentDistance(a, b *store.TrackPoint) (float64, bool) {
+	if a.Distance != nil && b.Distance != nil {
+		return max(0, *b.Distance-*a.Distance), true
+	}
+	if a.Lat != nil && a.Lon != nil && b.Lat != nil && b.Lon != nil {
+		return haversine(*a.Lat, *a.Lon, *b.Lat, *b.Lon), true
+	}
+	return 0, false
+}
+
+const earthRadius = 6371008.8
+
+// haversine returns the great circle distance between two positions, in
+// meters.
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
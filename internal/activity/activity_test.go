@@ -0,0 +1,198 @@
+package activity
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const testGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1"
+	xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+	<trk>
+		<name>Morning Run</name>
+		<type>running</type>
+		<trkseg>
+			<trkpt lat="52.0000" lon="13.0000"><ele>30</ele><time>2024-03-01T07:00:00Z</time>
+				<extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>120</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions></trkpt>
+			<trkpt lat="52.0045" lon="13.0000"><ele>35</ele><time>2024-03-01T07:02:30Z</time>
+				<extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>150</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions></trkpt>
+			<trkpt lat="52.0045" lon="13.0000"><ele>34</ele><time>2024-03-01T07:04:00Z</time></trkpt>
+			<trkpt lat="52.0090" lon="13.0000"><ele>28</ele><time>2024-03-01T07:06:30Z</time>
+				<extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>160</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions></trkpt>
+		</trkseg>
+	</trk>
+</gpx>`
+
+func TestParseGPX(t *testing.T) {
+	a, err := Parse([]byte(testGPX), FormatAuto)
+	require.NoError(t, err)
+
+	assert.Equal(t, FormatGPX, a.Format)
+	assert.Equal(t, "Morning Run", a.Name)
+	assert.Equal(t, SportRunning, a.Sport)
+	require.Len(t, a.Points, 4)
+
+	track := a.Track()
+	summary := track.Summary
+	assert.Equal(t, time.Date(2024, 3, 1, 7, 0, 0, 0, time.UTC), summary.StartedAt)
+	assert.Equal(t, 390, summary.ElapsedSeconds)
+	// the 90 s standing still do not count as moving
+	assert.Equal(t, 300, summary.MovingSeconds)
+	assert.InDelta(t, 1000.8, summary.DistanceMeters, 1)
+	assert.Equal(t, 5.0, summary.ElevationGainMeters)
+	assert.Equal(t, 7.0, summary.ElevationLossMeters)
+	assert.Equal(t, 143, *summary.AvgHeartRate)
+	assert.Equal(t, 160, *summary.MaxHeartRate)
+
+	workout := a.Workout(track)
+	assert.Equal(t, "Morning Run (2024-03-01 07:00)", workout.Title)
+	assert.Equal(t, 7, workout.DurationMinutes)
+	require.Len(t, workout.Entries, 1)
+	assert.Equal(t, "Running", workout.Entries[0].ExerciseName)
+	assert.Equal(t, 300, *workout.Entries[0].DurationSeconds)
+	assert.Equal(t, store.EntryCardio, workout.Entries[0].Type)
+	assert.Equal(t, 1.001, *workout.Entries[0].Distance)
+	assert.Equal(t, store.UnitKilometers, workout.Entries[0].DistanceUnit)
+	assert.Equal(t, 5.0, *workout.Entries[0].ElevationGainMeters)
+	assert.Equal(t, 160, *workout.Entries[0].MaxHeartRate)
+}
+
+const testTCX = `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">
+	<Activities>
+		<Activity Sport="Biking">
+			<Id>2024-03-02T10:00:00Z</Id>
+			<Lap StartTime="2024-03-02T10:00:00Z">
+				<Calories>120</Calories>
+				<Track>
+					<Trackpoint><Time>2024-03-02T10:00:00Z</Time><DistanceMeters>0</DistanceMeters><HeartRateBpm><Value>100</Value></HeartRateBpm></Trackpoint>
+					<Trackpoint><Time>2024-03-02T10:10:00Z</Time><DistanceMeters>5000</DistanceMeters><HeartRateBpm><Value>140</Value></HeartRateBpm></Trackpoint>
+				</Track>
+			</Lap>
+		</Activity>
+	</Activities>
+</TrainingCenterDatabase>`
+
+func TestParseTCX(t *testing.T) {
+	a, err := Parse([]byte(testTCX), FormatAuto)
+	require.NoError(t, err)
+
+	assert.Equal(t, FormatTCX, a.Format)
+	assert.Equal(t, SportCycling, a.Sport)
+	assert.Equal(t, 120, a.Calories)
+
+	track := a.Track()
+	assert.Equal(t, 5000.0, track.Summary.DistanceMeters)
+	assert.Equal(t, 600, track.Summary.MovingSeconds)
+
+	// no positions, so there is no line to draw
+	geo := GeoJSON(track)
+	require.Len(t, geo.Features, 1)
+	assert.Nil(t, geo.Features[0].Geometry)
+
+	workout := a.Workout(track)
+	assert.Equal(t, "Cycling (2024-03-02 10:00)", workout.Title)
+	assert.Equal(t, 120, workout.CaloriesBurned)
+}
+
+// fitBuilder writes a little endian FIT file with the messages used here.
+type fitBuilder struct {
+	buf bytes.Buffer
+}
+
+func (b *fitBuilder) definition(local byte, global uint16, fields ...fitField) {
+	b.buf.WriteByte(0x40 | local)
+	b.buf.Write([]byte{0, 0})
+	binary.Write(&b.buf, binary.LittleEndian, global)
+	b.buf.WriteByte(byte(len(fields)))
+	for _, f := range fields {
+		b.buf.Write([]byte{f.num, byte(f.size), f.baseType})
+	}
+}
+
+func (b *fitBuilder) data(header byte, values ...any) {
+	b.buf.WriteByte(header)
+	for _, v := range values {
+		binary.Write(&b.buf, binary.LittleEndian, v)
+	}
+}
+
+func (b *fitBuilder) bytes() []byte {
+	header := []byte{12, 0x10, 0, 0, 0, 0, 0, 0, '.', 'F', 'I', 'T'}
+	binary.LittleEndian.PutUint32(header[4:8], uint32(b.buf.Len()))
+
+	file := append(header, b.buf.Bytes()...)
+	return binary.LittleEndian.AppendUint16(file, fitCRC(file))
+}
+
+func TestParseFIT(t *testing.T) {
+	start := time.Date(2024, 3, 3, 8, 0, 0, 0, time.UTC)
+	ts := uint32(start.Sub(fitEpoch).Seconds())
+	semicircles := func(deg float64) int32 { return int32(deg * (1 << 31) / 180) }
+
+	b := &fitBuilder{}
+	b.definition(0, fitRecord,
+		fitField{num: fitTimestamp, size: 4, baseType: 0x86},
+		fitField{num: fitRecordLat, size: 4, baseType: 0x85},
+		fitField{num: fitRecordLon, size: 4, baseType: 0x85},
+		fitField{num: fitRecordAltitude, size: 2, baseType: 0x84},
+		fitField{num: fitRecordHeartRate, size: 1, baseType: 0x02},
+		fitField{num: fitRecordDistance, size: 4, baseType: 0x86},
+	)
+	b.data(0x00, ts, semicircles(48.1), semicircles(11.5), uint16((520+500)*5), uint8(110), uint32(0))
+	b.data(0x00, ts+60, semicircles(48.101), semicircles(11.5), uint16((525+500)*5), uint8(0xFF), uint32(25000))
+	b.data(0x00, ts+120, semicircles(48.102), semicircles(11.5), uint16(0xFFFF), uint8(150), uint32(50000))
+
+	b.definition(1, fitSession,
+		fitField{num: fitSessionSport, size: 1, baseType: 0x00},
+		fitField{num: fitSessionTotalCalories, size: 2, baseType: 0x84},
+	)
+	b.data(0x01, uint8(1), uint16(42))
+
+	a, err := Parse(b.bytes(), FormatAuto)
+	require.NoError(t, err)
+
+	assert.Equal(t, FormatFIT, a.Format)
+	assert.Equal(t, SportRunning, a.Sport)
+	assert.Equal(t, 42, a.Calories)
+	require.Len(t, a.Points, 3)
+
+	assert.Equal(t, start, a.Points[0].Time)
+	assert.InDelta(t, 48.1, *a.Points[0].Lat, 1e-6)
+	assert.InDelta(t, 520, *a.Points[0].Elevation, 0.01)
+	assert.Nil(t, a.Points[1].HeartRate)
+	assert.Nil(t, a.Points[2].Elevation)
+
+	summary := Summarize(a.Points)
+	assert.Equal(t, 500.0, summary.DistanceMeters)
+	assert.Equal(t, 120, summary.ElapsedSeconds)
+	assert.Equal(t, 130, *summary.AvgHeartRate)
+
+	geo := GeoJSON(&store.Track{Points: a.Points})
+	require.NotNil(t, geo.Features[0].Geometry)
+	assert.Len(t, geo.Features[0].Geometry.Coordinates, 3)
+	assert.Len(t, geo.Features[0].Geometry.Coordinates[2], 2)
+}
+
+func TestParseFITChecksum(t *testing.T) {
+	b := &fitBuilder{}
+	b.definition(0, fitRecord, fitField{num: fitTimestamp, size: 4, baseType: 0x86})
+	data := b.bytes()
+	data[len(data)-3] ^= 0xFF
+
+	_, err := Parse(data, FormatFIT)
+	assert.ErrorContains(t, err, "checksum")
+}
+
+func TestParseUnknown(t *testing.T) {
+	_, err := Parse([]byte("<kml></kml>"), FormatAuto)
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	_, err = Parse([]byte(`<gpx><trk><trkseg><trkpt lat="1" lon="1"/></trkseg></trk></gpx>`), FormatAuto)
+	assert.ErrorIs(t, err, ErrNoTrack)
+}
@@ -0,0 +1,329 @@
+package activity
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"time"
+)
+
+// This is a minimal reader for the Garmin FIT protocol. It decodes the
+// record messages (track points) and the session message (sport and
+// calories) and skips everything else, including developer fields.
+
+// FIT message numbers.
+const (
+	fitSession = 18
+	fitRecord  = 20
+)
+
+// FIT field numbers of the record and session messages.
+const (
+	fitTimestamp = 253
+
+	fitRecordLat              = 0
+	fitRecordLon              = 1
+	fitRecordAltitude         = 2
+	fitRecordHeartRate        = 3
+	fitRecordDistance         = 5
+	fitRecordEnhancedAltitude = 78
+
+	fitSessionSport         = 5
+	fitSessionTotalCalories = 11
+)
+
+// fitEpoch is the start of FIT time, 1989-12-31T00:00:00Z.
+var fitEpoch = time.Date(1989, 12, 31, 0, 0, 0, 0, time.UTC)
+
+var errFITTruncated = errors.New("read FIT: file is truncated")
+
+type fitField struct {
+	num      byte
+	size     int
+	baseType byte
+}
+
+type fitDefinition struct {
+	global    uint16
+	byteOrder binary.ByteOrder
+	fields    []fitField
+	// devSize is the total size of the developer fields, which are skipped.
+	devSize int
+}
+
+// fitValues are the integer fields of one message that hold a valid value.
+type fitValues map[byte]int64
+
+func parseFIT(data []byte) (*Activity, error) {
+	if len(data) < 12 {
+		return nil, errFITTruncated
+	}
+
+	headerSize := int(data[0])
+	dataSize := int(binary.LittleEndian.Uint32(data[4:8]))
+	if headerSize < 12 || string(data[8:12]) != ".FIT" {
+		return nil, ErrUnknownFormat
+	}
+
+	end := headerSize + dataSize
+	if len(data) < end+2 {
+		return nil, errFITTruncated
+	}
+	if want := binary.LittleEndian.Uint16(data[end : end+2]); want != 0 && fitCRC(data[:end]) != want {
+		return nil, errors.New("read FIT: checksum mismatch")
+	}
+
+	a := &Activity{Sport: SportOther}
+	definitions := make(map[byte]*fitDefinition)
+	var lastTimestamp uint32
+
+	pos := headerSize
+	for pos < end {
+		header := data[pos]
+		pos++
+
+		var local byte
+		var compressedTime *uint32
+
+		switch {
+		case header&0x80 != 0:
+			// compressed timestamp header: a data message whose time is an
+			// offset to the last full timestamp
+			local = (header >> 5) & 0x03
+			offset := uint32(header & 0x1F)
+			timestamp := lastTimestamp&^0x1F + offset
+			if offset < lastTimestamp&0x1F {
+				timestamp += 0x20
+			}
+			compressedTime = &timestamp
+
+		case header&0x40 != 0:
+			def, n, err := readFITDefinition(data[pos:end], header&0x20 != 0)
+			if err != nil {
+				return nil, err
+			}
+			definitions[header&0x0F] = def
+			pos += n
+			continue
+
+		default:
+			local = header & 0x0F
+		}
+
+		def, ok := definitions[local]
+		if !ok {
+			return nil, fmt.Errorf("read FIT: data message for undefined local type %d", local)
+		}
+
+		values, n, err := readFITMessage(data[pos:end], def)
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+
+		if compressedTime != nil {
+			values[fitTimestamp] = int64(*compressedTime)
+		}
+		if ts, ok := values[fitTimestamp]; ok {
+			lastTimestamp = uint32(ts)
+		}
+
+		switch def.global {
+		case fitRecord:
+			a.Points = append(a.Points, fitPoint(values))
+		case fitSession:
+			if sport, ok := values[fitSessionSport]; ok {
+				a.Sport = fitSport(sport)
+			}
+			if calories, ok := values[fitSessionTotalCalories]; ok {
+				a.Calories += int(calories)
+			}
+		}
+	}
+
+	return a, nil
+}
+
+func readFITDefinition(data []byte, developer bool) (*fitDefinition, int, error) {
+	if len(data) < 5 {
+		return nil, 0, errFITTruncated
+	}
+
+	def := &fitDefinition{byteOrder: binary.LittleEndian}
+	if data[1] == 1 {
+		def.byteOrder = binary.BigEndian
+	}
+	def.global = def.byteOrder.Uint16(data[2:4])
+
+	count := int(data[4])
+	pos := 5
+	if len(data) < pos+count*3 {
+		return nil, 0, errFITTruncated
+	}
+	for i := 0; i < count; i++ {
+		def.fields = append(def.fields, fitField{num: data[pos], size: int(data[pos+1]), baseType: data[pos+2]})
+		pos += 3
+	}
+
+	if developer {
+		if len(data) < pos+1 {
+			return nil, 0, errFITTruncated
+		}
+		count = int(data[pos])
+		pos++
+		if len(data) < pos+count*3 {
+			return nil, 0, errFITTruncated
+		}
+		for i := 0; i < count; i++ {
+			def.devSize += int(data[pos+1])
+			pos += 3
+		}
+	}
+
+	return def, pos, nil
+}
+
+func readFITMessage(data []byte, def *fitDefinition) (fitValues, int, error) {
+	values := make(fitValues, len(def.fields))
+
+	pos := 0
+	for _, field := range def.fields {
+		if len(data) < pos+field.size {
+			return nil, 0, errFITTruncated
+		}
+		if v, ok := fitInt(data[pos:pos+field.size], field.baseType, def.byteOrder); ok {
+			values[field.num] = v
+		}
+		pos += field.size
+	}
+
+	pos += def.devSize
+	if len(data) < pos {
+		return nil, 0, errFITTruncated
+	}
+
+	return values, pos, nil
+}
+
+// fitInt decodes a single integer field. Arrays, strings, floats and the
+// invalid value of the type, which marks a missing value, are not ok.
+func fitInt(b []byte, baseType byte, order binary.ByteOrder) (int64, bool) {
+	signed := false
+	zeroInvalid := false
+
+	switch baseType & 0x1F {
+	case 0x00, 0x02, 0x04, 0x06: // enum, uint8, uint16, uint32
+	case 0x01, 0x03, 0x05: // sint8, sint16, sint32
+		signed = true
+	case 0x0A, 0x0B, 0x0C: // uint8z, uint16z, uint32z
+		zeroInvalid = true
+	default:
+		return 0, false
+	}
+
+	var u uint64
+	switch len(b) {
+	case 1:
+		u = uint64(b[0])
+	case 2:
+		u = uint64(order.Uint16(b))
+	case 4:
+		u = uint64(order.Uint32(b))
+	default:
+		return 0, false
+	}
+
+	bits := uint(len(b) * 8)
+	switch {
+	case zeroInvalid:
+		if u == 0 {
+			return 0, false
+		}
+	case signed:
+		if u == 1<<(bits-1)-1 {
+			return 0, false
+		}
+		// sign extend
+		return int64(u<<(64-bits)) >> (64 - bits), true
+	default:
+		if u == 1<<bits-1 {
+			return 0, false
+		}
+	}
+
+	return int64(u), true
+}
+
+func fitPoint(values fitValues) store.TrackPoint {
+	point := store.TrackPoint{}
+
+	if ts, ok := values[fitTimestamp]; ok {
+		point.Time = fitEpoch.Add(time.Duration(ts) * time.Second)
+	}
+
+	lat, okLat := values[fitRecordLat]
+	lon, okLon := values[fitRecordLon]
+	if okLat && okLon {
+		// positions are in semicircles
+		latDeg := float64(lat) * 180 / (1 << 31)
+		lonDeg := float64(lon) * 180 / (1 << 31)
+		point.Lat, point.Lon = &latDeg, &lonDeg
+	}
+
+	altitude, ok := values[fitRecordEnhancedAltitude]
+	if !ok {
+		altitude, ok = values[fitRecordAltitude]
+	}
+	if ok {
+		meters := float64(altitude)/5 - 500
+		point.Elevation = &meters
+	}
+
+	if hr, ok := values[fitRecordHeartRate]; ok && hr > 0 {
+		bpm := int(hr)
+		point.HeartRate = &bpm
+	}
+
+	if distance, ok := values[fitRecordDistance]; ok {
+		meters := float64(distance) / 100
+		point.Distance = &meters
+	}
+
+	return point
+}
+
+func fitSport(sport int64) string {
+	switch sport {
+	case 1:
+		return SportRunning
+	case 2:
+		return SportCycling
+	case 5:
+		return SportSwimming
+	case 11:
+		return SportWalking
+	case 17:
+		return SportHiking
+	}
+	return SportOther
+}
+
+var fitCRCTable = [16]uint16{
+	0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+	0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+}
+
+func fitCRC(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		tmp := fitCRCTable[crc&0x0F]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[b&0x0F]
+
+		tmp = fitCRCTable[crc&0x0F]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[(b>>4)&0x0F]
+	}
+	return crc
+}
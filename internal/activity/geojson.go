@@ -0,0 +1,74 @@
+package activity
+
+import (
+	"github.com/oki-irawan/fem_project/internal/store"
+	"time"
+)
+
+// GeoJSON types, trimmed to what a track needs (RFC 7946).
+
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+type Feature struct {
+	Type       string         `json:"type"`
+	Geometry   *Geometry      `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates [][]float64 `json:"coordinates"`
+}
+
+// CoordinateProperties holds one value per coordinate of the line, the
+// convention used by togeojson and Mapbox for per point data.
+type CoordinateProperties struct {
+	Times      []time.Time `json:"times"`
+	HeartRates []*int      `json:"heart_rates,omitempty"`
+}
+
+// GeoJSON returns track as a feature collection with one LineString of the
+// points that have a position. Coordinates are [lon, lat] or [lon, lat, ele].
+// A track without positions, e.g. from a treadmill, has a null geometry.
+func GeoJSON(track *store.Track) *FeatureCollection {
+	coordinates := [][]float64{}
+	props := CoordinateProperties{Times: []time.Time{}}
+	hasHeartRate := false
+
+	for _, p := range track.Points {
+		if p.Lat == nil || p.Lon == nil {
+			continue
+		}
+
+		coordinate := []float64{*p.Lon, *p.Lat}
+		if p.Elevation != nil {
+			coordinate = append(coordinate, *p.Elevation)
+		}
+		coordinates = append(coordinates, coordinate)
+
+		props.Times = append(props.Times, p.Time)
+		props.HeartRates = append(props.HeartRates, p.HeartRate)
+		hasHeartRate = hasHeartRate || p.HeartRate != nil
+	}
+	if !hasHeartRate {
+		props.HeartRates = nil
+	}
+
+	feature := Feature{
+		Type: "Feature",
+		Properties: map[string]any{
+			"workout_id": track.WorkoutID,
+			"sport":      track.Sport,
+			"summary":    track.Summary,
+		},
+	}
+	if len(coordinates) >= 2 {
+		feature.Geometry = &Geometry{Type: "LineString", Coordinates: coordinates}
+		feature.Properties["coordinateProperties"] = props
+	}
+
+	return &FeatureCollection{Type: "FeatureCollection", Features: []Feature{feature}}
+}
@@ -0,0 +1,146 @@
+package activity
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"io"
+	"math"
+)
+
+// Elements are matched by local name, so the namespaces and prefixes used by
+// different apps, e.g. for Garmin's TrackPointExtension, do not matter.
+
+type gpxFile struct {
+	Metadata struct {
+		Name string `xml:"name"`
+	} `xml:"metadata"`
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Type     string `xml:"type"`
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+type gpxPoint struct {
+	Lat        float64  `xml:"lat,attr"`
+	Lon        float64  `xml:"lon,attr"`
+	Elevation  *float64 `xml:"ele"`
+	Time       string   `xml:"time"`
+	Extensions struct {
+		TrackPoint struct {
+			HeartRate *float64 `xml:"hr"`
+		} `xml:"TrackPointExtension"`
+	} `xml:"extensions"`
+}
+
+func parseGPX(data []byte) (*Activity, error) {
+	var file gpxFile
+	err := decodeXML(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("read GPX: %w", err)
+	}
+
+	a := &Activity{Name: file.Metadata.Name, Sport: SportOther}
+	for i, track := range file.Tracks {
+		if i == 0 {
+			if track.Name != "" {
+				a.Name = track.Name
+			}
+			a.Sport = normalizeSport(track.Type)
+		}
+
+		for _, segment := range track.Segments {
+			for _, p := range segment.Points {
+				lat, lon := p.Lat, p.Lon
+				a.Points = append(a.Points, store.TrackPoint{
+					Time:      parseTime(p.Time),
+					Lat:       &lat,
+					Lon:       &lon,
+					Elevation: p.Elevation,
+					HeartRate: heartRate(p.Extensions.TrackPoint.HeartRate),
+				})
+			}
+		}
+	}
+
+	return a, nil
+}
+
+type tcxFile struct {
+	Activities []struct {
+		Sport string `xml:"Sport,attr"`
+		Notes string `xml:"Notes"`
+		Laps  []struct {
+			Calories int `xml:"Calories"`
+			Points   []struct {
+				Time     string `xml:"Time"`
+				Position *struct {
+					Lat float64 `xml:"LatitudeDegrees"`
+					Lon float64 `xml:"LongitudeDegrees"`
+				} `xml:"Position"`
+				Altitude  *float64 `xml:"AltitudeMeters"`
+				Distance  *float64 `xml:"DistanceMeters"`
+				HeartRate *struct {
+					Value float64 `xml:"Value"`
+				} `xml:"HeartRateBpm"`
+			} `xml:"Track>Trackpoint"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+func parseTCX(data []byte) (*Activity, error) {
+	var file tcxFile
+	err := decodeXML(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("read TCX: %w", err)
+	}
+	if len(file.Activities) == 0 {
+		return nil, ErrNoTrack
+	}
+
+	// a file can hold several activities; the first is the recording
+	activity := file.Activities[0]
+	a := &Activity{Name: activity.Notes, Sport: normalizeSport(activity.Sport)}
+
+	for _, lap := range activity.Laps {
+		a.Calories += lap.Calories
+
+		for _, p := range lap.Points {
+			point := store.TrackPoint{
+				Time:      parseTime(p.Time),
+				Elevation: p.Altitude,
+				Distance:  p.Distance,
+			}
+			if p.Position != nil {
+				lat, lon := p.Position.Lat, p.Position.Lon
+				point.Lat, point.Lon = &lat, &lon
+			}
+			if p.HeartRate != nil {
+				point.HeartRate = heartRate(&p.HeartRate.Value)
+			}
+
+			a.Points = append(a.Points, point)
+		}
+	}
+
+	return a, nil
+}
+
+func decodeXML(data []byte, v any) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// exports are UTF-8 in practice; other declared charsets are read as is
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) { return input, nil }
+	return decoder.Decode(v)
+}
+
+func heartRate(bpm *float64) *int {
+	if bpm == nil || *bpm <= 0 {
+		return nil
+	}
+	hr := int(math.Round(*bpm))
+	return &hr
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/activity"
+	"github.com/oki-irawan/fem_project/internal/metrics"
+	"github.com/oki-irawan/fem_project/internal/middleware"
+	"github.com/oki-irawan/fem_project/internal/records"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/utils"
+	"github.com/oki-irawan/fem_project/internal/validator"
+	"log/slog"
+	"net/http"
+)
+
+type ActivityHandler struct {
+	trackStore   store.TrackStore
+	workoutStore store.WorkoutStore
+	logger       *slog.Logger
+}
+
+func NewActivityHandler(trackStore store.TrackStore, workoutStore store.WorkoutStore, logger *slog.Logger) *ActivityHandler {
+	return &ActivityHandler{
+		trackStore:   trackStore,
+		workoutStore: workoutStore,
+		logger:       logger,
+	}
+}
+
+// HandleCreateActivity saves an uploaded GPX, TCX or FIT recording as a
+// workout with one cardio entry and keeps the track for the map. The file is
+// sent like an import, as the file field of a multipart form or as the raw
+// body; the format query parameter overrides detection.
+func (ah *ActivityHandler) HandleCreateActivity(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = string(activity.FormatAuto)
+	}
+
+	_, data, err := readUpload(r)
+	if err != nil {
+		writeError(ah.logger, w, r, "Reading Activity Upload", err)
+		return
+	}
+
+	v := validator.New()
+	v.In("format", format, string(activity.FormatAuto), string(activity.FormatGPX), string(activity.FormatTCX), string(activity.FormatFIT))
+	v.Check(len(data) > 0, "file", "required", "file must not be empty")
+	if err = v.Err(); err != nil {
+		writeError(ah.logger, w, r, "Validating Activity", err)
+		return
+	}
+
+	parsed, err := activity.Parse(data, activity.Format(format))
+	if err != nil {
+		v.AddError("file", "invalid_file", err.Error())
+		writeError(ah.logger, w, r, "Parsing Activity", v.Err())
+		return
+	}
+
+	track := parsed.Track()
+	workout := parsed.Workout(track)
+	workout.UserID = middleware.GetUser(r).ID
+
+	validator.Workout(v, workout)
+	if err = v.Err(); err != nil {
+		writeError(ah.logger, w, r, "Validating Activity Workout", err)
+		return
+	}
+
+	previous := records.Previous(r.Context(), ah.logger, ah.workoutStore, workout)
+
+	// the title is made up from the recording, so a taken one is numbered
+	// rather than reported
+	err = store.SaveWithNumberedTitle(workout, store.TitleAttempts, func() error {
+		return ah.trackStore.CreateWorkoutWithTrack(r.Context(), workout, track)
+	})
+	if err != nil {
+		writeError(ah.logger, w, r, "CreateWorkoutWithTrack", err)
+		return
+	}
+
+	metrics.WorkoutsCreated.Inc()
+	records.Record(previous, workout)
+	w.Header().Set("Location", fmt.Sprintf("/workouts/%d", workout.ID))
+	w.Header().Set("ETag", utils.ETag(workout.Version))
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"workout": workout, "track": track})
+}
+
+// HandleGetTrack returns the track of a workout as GeoJSON.
+func (ah *ActivityHandler) HandleGetTrack(w http.ResponseWriter, r *http.Request) {
+	workoutId, err := utils.ReadIdParameter(r)
+	if err != nil {
+		ah.logger.WarnContext(r.Context(), "read id parameter", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeInvalidID, "invalid workout id")
+		return
+	}
+
+	err = checkWorkoutOwner(r, ah.workoutStore, workoutId, middleware.GetUser(r))
+	if err != nil {
+		writeError(ah.logger, w, r, "GetWorkoutOwner", err)
+		return
+	}
+
+	track, err := ah.trackStore.GetTrack(r.Context(), workoutId)
+	if err != nil {
+		writeError(ah.logger, w, r, "GetTrack", err)
+		return
+	}
+
+	js, err := json.Marshal(activity.GeoJSON(track))
+	if err != nil {
+		writeError(ah.logger, w, r, "Encoding Track", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(append(js, '\n'))
+}
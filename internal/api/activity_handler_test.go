@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+	<trk>
+		<name>Morning Run</name>
+		<type>running</type>
+		<trkseg>
+			<trkpt lat="52.0000" lon="13.0000"><time>2024-03-01T07:00:00Z</time></trkpt>
+			<trkpt lat="52.0045" lon="13.0000"><time>2024-03-01T07:02:30Z</time></trkpt>
+			<trkpt lat="52.0090" lon="13.0000"><time>2024-03-01T07:05:00Z</time></trkpt>
+		</trkseg>
+	</trk>
+</gpx>`
+
+// fakeTrackStore keeps workout titles unique per user, like
+// workouts_title_key.
+type fakeTrackStore struct {
+	store.TrackStore
+	titles map[string]bool
+}
+
+func (s *fakeTrackStore) CreateWorkoutWithTrack(ctx context.Context, workout *store.Workout, track *store.Track) error {
+	if s.titles[workout.Title] {
+		return &store.ConflictError{Constraint: "workouts_title_key", Field: "title"}
+	}
+	s.titles[workout.Title] = true
+	workout.ID = len(s.titles)
+	workout.Version = 1
+	return nil
+}
+
+func TestHandleCreateActivityTitleTaken(t *testing.T) {
+	const title = "Morning Run (2024-03-01 07:00)"
+
+	test := []struct {
+		name string
+		// taken is how many of the title and its numbered variants are
+		// taken already
+		taken      int
+		wantStatus int
+		wantTitle  string
+	}{
+		{name: "Free", wantStatus: http.StatusCreated, wantTitle: title},
+		{name: "Taken", taken: 1, wantStatus: http.StatusCreated, wantTitle: title + " #2"},
+		{name: "Numbered Ones Taken Too", taken: 2, wantStatus: http.StatusCreated, wantTitle: title + " #3"},
+		{name: "All Taken", taken: store.TitleAttempts, wantStatus: http.StatusConflict},
+	}
+
+	for _, tt := range test {
+		t.Run(tt.name, func(t *testing.T) {
+			tracks := &fakeTrackStore{titles: map[string]bool{}}
+			for n := 1; n <= tt.taken; n++ {
+				if n == 1 {
+					tracks.titles[title] = true
+				} else {
+					tracks.titles[fmt.Sprintf("%s #%d", title, n)] = true
+				}
+			}
+			ah := NewActivityHandler(tracks, newFakeWorkoutStore(), discardLogger())
+
+			r := newUserRequest(http.MethodPost, "/activities", testGPX, &store.User{ID: 1})
+			r.Header.Set("Content-Type", "application/gpx+xml")
+
+			w := httptest.NewRecorder()
+			ah.HandleCreateActivity(w, r)
+			require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantStatus != http.StatusCreated {
+				assert.Equal(t, "conflict", problemCode(t, w))
+				return
+			}
+
+			var body struct {
+				Workout store.Workout `json:"workout"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantTitle, body.Workout.Title)
+			assert.Equal(t, 1, body.Workout.UserID)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"github.com/oki-irawan/fem_project/internal/middleware"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/utils"
+	"github.com/oki-irawan/fem_project/internal/validator"
+	"log/slog"
+	"net/http"
+)
+
+type AnalyticsHandler struct {
+	analyticsStore store.AnalyticsStore
+	logger         *slog.Logger
+}
+
+func NewAnalyticsHandler(analyticsStore store.AnalyticsStore, logger *slog.Logger) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		analyticsStore: analyticsStore,
+		logger:         logger,
+	}
+}
+
+// HandleWeeklyDistance returns the cardio distance of the current user per
+// week. from and to limit the workouts counted, like for an export.
+func (ah *AnalyticsHandler) HandleWeeklyDistance(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	timeRange := readTimeRange(v, r)
+	if err := v.Err(); err != nil {
+		writeError(ah.logger, w, r, "Validating Weekly Distance", err)
+		return
+	}
+
+	weeks, err := ah.analyticsStore.WeeklyDistance(r.Context(), middleware.GetUser(r).ID, timeRange)
+	if err != nil {
+		writeError(ah.logger, w, r, "WeeklyDistance", err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"weeks": weeks})
+}
+
+// HandleRelativeStrength returns the heaviest set of each weighted exercise
+// of the current user as a multiple of their body weight at the time.
+func (ah *AnalyticsHandler) HandleRelativeStrength(w http.ResponseWriter, r *http.Request) {
+	lifts, err := ah.analyticsStore.RelativeStrength(r.Context(), middleware.GetUser(r).ID)
+	if err != nil {
+		writeError(ah.logger, w, r, "RelativeStrength", err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"lifts": lifts})
+}
@@ -0,0 +1,297 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/middleware"
+	"github.com/oki-irawan/fem_project/internal/records"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/utils"
+	"github.com/oki-irawan/fem_project/internal/validator"
+	"net/http"
+)
+
+// The entry endpoints edit a single entry but save the whole workout through
+// UpdateWorkout, so every entry change bumps the workout version, honours
+// If-Match and is recorded as a revision. Entry IDs stay stable across saves.
+
+// loadWorkoutForEdit loads the workout of the request for an entry change.
+// It writes the error response itself.
+func (wh *WorkoutHandler) loadWorkoutForEdit(w http.ResponseWriter, r *http.Request) (*store.Workout, bool) {
+	workoutId, err := utils.ReadIdParameter(r)
+	if err != nil {
+		wh.logger.WarnContext(r.Context(), "read id parameter", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeInvalidID, "invalid workout id")
+		return nil, false
+	}
+
+	err = wh.checkOwner(r, workoutId, middleware.GetUser(r))
+	if err != nil {
+		writeError(wh.logger, w, r, "GetWorkoutOwner", err)
+		return nil, false
+	}
+
+	workout, err := wh.workoutStore.GetWorkoutByID(r.Context(), workoutId)
+	if err != nil {
+		writeError(wh.logger, w, r, "GetWorkoutByID", err)
+		return nil, false
+	}
+
+	if match := r.Header.Get("If-Match"); match != "" && !utils.MatchETag(match, utils.ETag(workout.Version)) {
+		writeError(wh.logger, w, r, "If-Match", store.ErrVersionMismatch)
+		return nil, false
+	}
+
+	return workout, true
+}
+
+// saveWorkout validates and saves a workout changed by an entry endpoint.
+func (wh *WorkoutHandler) saveWorkout(w http.ResponseWriter, r *http.Request, workout *store.Workout) bool {
+	v := validator.New()
+	validator.Workout(v, workout)
+	if err := v.Err(); err != nil {
+		writeError(wh.logger, w, r, "Validating Workout Entries", err)
+		return false
+	}
+
+	previous := records.Previous(r.Context(), wh.logger, wh.workoutStore, workout)
+
+	err := wh.workoutStore.UpdateWorkout(r.Context(), workout)
+	if err != nil {
+		writeError(wh.logger, w, r, "UpdateWorkout", err)
+		return false
+	}
+
+	records.Record(previous, workout)
+
+	w.Header().Set("ETag", utils.ETag(workout.Version))
+	return true
+}
+
+// readEntryIndex finds the entry of the entryId URL parameter in workout.
+func (wh *WorkoutHandler) readEntryIndex(w http.ResponseWriter, r *http.Request, workout *store.Workout) (int, bool) {
+	entryId, err := utils.ReadIntParameter(r, "entryId")
+	if err != nil {
+		wh.logger.WarnContext(r.Context(), "read entryId parameter", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeInvalidID, "invalid entry id")
+		return 0, false
+	}
+
+	for i := range workout.Entries {
+		if int64(workout.Entries[i].ID) == entryId {
+			return i, true
+		}
+	}
+
+	writeError(wh.logger, w, r, "find entry", store.ErrNotFound)
+	return 0, false
+}
+
+func (wh *WorkoutHandler) HandleCreateEntry(w http.ResponseWriter, r *http.Request) {
+	workout, ok := wh.loadWorkoutForEdit(w, r)
+	if !ok {
+		return
+	}
+
+	// OrderIndex shadows the embedded field so a missing order_index can be
+	// told apart from 0
+	var req struct {
+		store.WorkoutEntries
+		OrderIndex *int `json:"order_index"`
+	}
+
+	err := utils.ReadJSON(r, &req)
+	if err != nil {
+		writeError(wh.logger, w, r, "Decoding Create Entry", err)
+		return
+	}
+
+	entry := req.WorkoutEntries
+	entry.ID = 0
+	if req.OrderIndex != nil {
+		entry.OrderIndex = *req.OrderIndex
+	} else {
+		// append after the last entry
+		for _, e := range workout.Entries {
+			entry.OrderIndex = max(entry.OrderIndex, e.OrderIndex+1)
+		}
+	}
+
+	v := validator.New()
+	validator.Entry(v, &entry)
+	if err = v.Err(); err != nil {
+		writeError(wh.logger, w, r, "Validating Create Entry", err)
+		return
+	}
+
+	workout.Entries = append(workout.Entries, entry)
+	if !wh.saveWorkout(w, r, workout) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"entry": workout.Entries[len(workout.Entries)-1]})
+}
+
+// HandleUpdateEntry changes the fields present in the body. A field set to
+// null is cleared, e.g. {"reps": null, "duration_seconds": 60} turns a rep
+// based entry into a timed one.
+func (wh *WorkoutHandler) HandleUpdateEntry(w http.ResponseWriter, r *http.Request) {
+	workout, ok := wh.loadWorkoutForEdit(w, r)
+	if !ok {
+		return
+	}
+
+	i, ok := wh.readEntryIndex(w, r, workout)
+	if !ok {
+		return
+	}
+
+	var patch map[string]json.RawMessage
+	err := utils.ReadJSON(r, &patch)
+	if err != nil {
+		writeError(wh.logger, w, r, "Decoding Update Entry", err)
+		return
+	}
+
+	entry, err := patchEntry(workout.Entries[i], patch)
+	if err != nil {
+		writeError(wh.logger, w, r, "Decoding Update Entry", err)
+		return
+	}
+
+	v := validator.New()
+	validator.Entry(v, &entry)
+	if err = v.Err(); err != nil {
+		writeError(wh.logger, w, r, "Validating Update Entry", err)
+		return
+	}
+
+	workout.Entries[i] = entry
+	if !wh.saveWorkout(w, r, workout) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"entry": workout.Entries[i]})
+}
+
+// patchEntry overlays the fields of patch on entry. The ID and UUID of an
+// entry cannot be changed.
+func patchEntry(entry store.WorkoutEntries, patch map[string]json.RawMessage) (store.WorkoutEntries, error) {
+	current, err := json.Marshal(entry)
+	if err != nil {
+		return entry, err
+	}
+
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(current, &fields)
+	if err != nil {
+		return entry, err
+	}
+
+	for name, value := range patch {
+		fields[name] = value
+	}
+
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return entry, err
+	}
+
+	patched := store.WorkoutEntries{}
+	err = json.Unmarshal(merged, &patched)
+	if err != nil {
+		return entry, fmt.Errorf("%w: %v", utils.ErrInvalidRequestBody, err)
+	}
+
+	patched.ID = entry.ID
+	patched.UUID = entry.UUID
+
+	return patched, nil
+}
+
+func (wh *WorkoutHandler) HandleDeleteEntry(w http.ResponseWriter, r *http.Request) {
+	workout, ok := wh.loadWorkoutForEdit(w, r)
+	if !ok {
+		return
+	}
+
+	i, ok := wh.readEntryIndex(w, r, workout)
+	if !ok {
+		return
+	}
+
+	workout.Entries = append(workout.Entries[:i], workout.Entries[i+1:]...)
+	if !wh.saveWorkout(w, r, workout) {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type reorderEntriesRequest struct {
+	EntryIDs []int `json:"entry_ids"`
+}
+
+// validate checks that the request lists every entry of workout exactly once.
+func (req *reorderEntriesRequest) validate(v *validator.Validator, workout *store.Workout) {
+	v.Check(len(req.EntryIDs) == len(workout.Entries), "entry_ids", "invalid_length", fmt.Sprintf("entry_ids must list all %d entries", len(workout.Entries)))
+
+	known := make(map[int]bool, len(workout.Entries))
+	for _, entry := range workout.Entries {
+		known[entry.ID] = true
+	}
+
+	seen := make(map[int]bool, len(req.EntryIDs))
+	for i, id := range req.EntryIDs {
+		field := v.Field("entry_ids").Index(i)
+		switch {
+		case !known[id]:
+			field.AddError("id", "not_found", fmt.Sprintf("entry %d is not part of the workout", id))
+		case seen[id]:
+			field.AddError("id", "duplicate", fmt.Sprintf("entry %d is listed more than once", id))
+		}
+		seen[id] = true
+	}
+}
+
+// HandleReorderEntries puts the entries in the order of entry_ids, rewriting
+// every order_index in one transaction.
+func (wh *WorkoutHandler) HandleReorderEntries(w http.ResponseWriter, r *http.Request) {
+	workout, ok := wh.loadWorkoutForEdit(w, r)
+	if !ok {
+		return
+	}
+
+	var req reorderEntriesRequest
+	err := utils.ReadJSON(r, &req)
+	if err != nil {
+		writeError(wh.logger, w, r, "Decoding Reorder Entries", err)
+		return
+	}
+
+	v := validator.New()
+	req.validate(v, workout)
+	if err = v.Err(); err != nil {
+		writeError(wh.logger, w, r, "Validating Reorder Entries", err)
+		return
+	}
+
+	byID := make(map[int]store.WorkoutEntries, len(workout.Entries))
+	for _, entry := range workout.Entries {
+		byID[entry.ID] = entry
+	}
+
+	entries := make([]store.WorkoutEntries, 0, len(req.EntryIDs))
+	for i, id := range req.EntryIDs {
+		entry := byID[id]
+		entry.OrderIndex = i
+		entries = append(entries, entry)
+	}
+
+	workout.Entries = entries
+	if !wh.saveWorkout(w, r, workout) {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout})
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"errors"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/utils"
+	"log/slog"
+	"net/http"
+)
+
+// writeError logs err and renders it as a problem response. Domain errors
+// such as not found are expected and logged below error level.
+func writeError(logger *slog.Logger, w http.ResponseWriter, r *http.Request, message string, err error) {
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, store.ErrNotFound), errors.Is(err, store.ErrForbidden), errors.Is(err, store.ErrVersionMismatch), errors.Is(err, store.ErrCursorExpired):
+		logger.DebugContext(r.Context(), message, "error", err)
+	case errors.Is(err, store.ErrValidation), errors.Is(err, store.ErrConflict), errors.Is(err, utils.ErrInvalidRequestBody), errors.As(err, &maxBytesErr):
+		logger.WarnContext(r.Context(), message, "error", err)
+	default:
+		logger.ErrorContext(r.Context(), message, "error", err)
+	}
+
+	utils.WriteError(w, r, err)
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/export"
+	"github.com/oki-irawan/fem_project/internal/middleware"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/validator"
+	"net/http"
+	"time"
+)
+
+const (
+	// exportBatchSize is how many workouts an export loads at a time.
+	exportBatchSize = 100
+	// exportBatchTimeout is how long writing one batch may take. The
+	// server's write timeout would otherwise cut off a long export.
+	exportBatchTimeout = 30 * time.Second
+)
+
+// readTimeBound reads a from or to parameter, either RFC 3339 or a date. A
+// date in to includes the whole day.
+func readTimeBound(v *validator.Validator, r *http.Request, name string) time.Time {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return time.Time{}
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		v.AddError(name, "invalid_format", name+" must be a date (2006-01-02) or an RFC 3339 time")
+		return time.Time{}
+	}
+	if name == "to" {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// readTimeRange reads the from and to parameters; either may be left open.
+func readTimeRange(v *validator.Validator, r *http.Request) store.TimeRange {
+	timeRange := store.TimeRange{
+		From: readTimeBound(v, r, "from"),
+		To:   readTimeBound(v, r, "to"),
+	}
+	if !timeRange.From.IsZero() && !timeRange.To.IsZero() {
+		v.Check(timeRange.From.Before(timeRange.To), "to", "invalid_range", "to must be after from")
+	}
+	return timeRange
+}
+
+// HandleExportWorkouts streams the workouts of the current user, oldest
+// first, as CSV (one row per entry), JSON Lines (one workout per line) or a
+// Markdown training log. from and to limit the export by performed_at.
+//
+// Workouts are loaded in batches, so memory use does not grow with the size
+// of the export. An error after the first byte cannot be reported as a
+// problem anymore; the connection is cut instead so the client does not
+// mistake a truncated file for a complete one.
+func (wh *WorkoutHandler) HandleExportWorkouts(w http.ResponseWriter, r *http.Request) {
+	format := export.Format(r.URL.Query().Get("format"))
+	if format == "" {
+		format = export.FormatCSV
+	}
+
+	v := validator.New()
+	v.In("format", string(format), export.Formats...)
+	timeRange := readTimeRange(v, r)
+	if err := v.Err(); err != nil {
+		writeError(wh.logger, w, r, "Validating Export", err)
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	// the first batch is loaded before anything is written, so the common
+	// failures still get a proper error response
+	batch, err := wh.workoutStore.ListWorkoutsPerformed(r.Context(), currentUser.ID, timeRange, nil, exportBatchSize)
+	if err != nil {
+		writeError(wh.logger, w, r, "ListWorkoutsPerformed", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="workouts.%s"`, format))
+	w.Header().Set("Cache-Control", "no-store")
+
+	writer, err := export.NewWriter(w, format)
+	if err == nil {
+		err = wh.writeExport(r, w, writer, batch, currentUser.ID, timeRange)
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		wh.logger.ErrorContext(r.Context(), "export workouts", "error", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// writeExport writes batch and the batches that follow it, flushing the
+// response after each one. Every batch gets its own write deadline.
+func (wh *WorkoutHandler) writeExport(r *http.Request, w http.ResponseWriter, writer export.Writer, batch []*store.Workout, userID int, timeRange store.TimeRange) error {
+	rc := http.NewResponseController(w)
+
+	for len(batch) > 0 {
+		// like Flush, not supported by every ResponseWriter
+		_ = rc.SetWriteDeadline(time.Now().Add(exportBatchTimeout))
+
+		for _, workout := range batch {
+			if err := writer.Write(workout); err != nil {
+				return err
+			}
+		}
+
+		// not every ResponseWriter can flush; the export still completes
+		_ = rc.Flush()
+
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+
+		var err error
+		batch, err = wh.workoutStore.ListWorkoutsPerformed(r.Context(), userID, timeRange, batch[len(batch)-1], exportBatchSize)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/oki-irawan/fem_project/internal/middleware"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeWorkoutStore keeps workouts in memory and checks versions like the
+// Postgres store does.
+type fakeWorkoutStore struct {
+	store.WorkoutStore
+	workouts map[int]*store.Workout
+	nextID   int
+	// updateErr, when set, is returned by the next UpdateWorkout.
+	updateErr error
+}
+
+func newFakeWorkoutStore(workouts ...*store.Workout) *fakeWorkoutStore {
+	s := &fakeWorkoutStore{workouts: map[int]*store.Workout{}}
+	for _, workout := range workouts {
+		s.nextID++
+		saved := *workout
+		saved.ID = s.nextID
+		if saved.Version == 0 {
+			saved.Version = 1
+		}
+		s.workouts[saved.ID] = &saved
+	}
+	return s
+}
+
+func (s *fakeWorkoutStore) CreateWorkout(ctx context.Context, workout *store.Workout) (*store.Workout, error) {
+	s.nextID++
+	created := *workout
+	created.ID = s.nextID
+	created.Version = 1
+	s.workouts[created.ID] = &created
+
+	result := created
+	return &result, nil
+}
+
+func (s *fakeWorkoutStore) GetWorkoutByID(ctx context.Context, id int64) (*store.Workout, error) {
+	workout, ok := s.workouts[int(id)]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+
+	result := *workout
+	return &result, nil
+}
+
+func (s *fakeWorkoutStore) GetWorkoutByUUID(ctx context.Context, userID int, uuid string) (*store.Workout, error) {
+	for _, workout := range s.workouts {
+		if workout.UserID == userID && workout.UUID == uuid {
+			result := *workout
+			return &result, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *fakeWorkoutStore) GetWorkoutOwner(ctx context.Context, id int64) (int, error) {
+	workout, ok := s.workouts[int(id)]
+	if !ok {
+		return 0, store.ErrNotFound
+	}
+	return workout.UserID, nil
+}
+
+func (s *fakeWorkoutStore) UpdateWorkout(ctx context.Context, workout *store.Workout) error {
+	if err := s.updateErr; err != nil {
+		s.updateErr = nil
+		return err
+	}
+
+	current, ok := s.workouts[workout.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if current.Version != workout.Version {
+		return store.ErrVersionMismatch
+	}
+
+	workout.Version++
+	saved := *workout
+	s.workouts[workout.ID] = &saved
+	return nil
+}
+
+func (s *fakeWorkoutStore) DeleteWorkout(ctx context.Context, id int64) error {
+	if _, ok := s.workouts[int(id)]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.workouts, int(id))
+	return nil
+}
+
+func (s *fakeWorkoutStore) BestWeights(ctx context.Context, userID int, exercises []string) (map[string]float64, error) {
+	best := map[string]float64{}
+	for _, workout := range s.workouts {
+		if workout.UserID != userID {
+			continue
+		}
+		for _, entry := range workout.Entries {
+			name := strings.ToLower(entry.ExerciseName)
+			if entry.Weight != nil && (best[name] == 0 || *entry.Weight > best[name]) {
+				best[name] = *entry.Weight
+			}
+		}
+	}
+	return best, nil
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// newUserRequest builds a request as the authentication middleware would
+// hand it to a handler.
+func newUserRequest(method, target, body string, user *store.User) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return middleware.SetUser(r, user)
+}
+
+// withURLParam sets a chi URL parameter the way the router would.
+func withURLParam(r *http.Request, name, value string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add(name, value)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func problemCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	var problem struct {
+		Code string `json:"code"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	return problem.Code
+}
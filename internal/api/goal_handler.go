@@ -0,0 +1,180 @@
+package api
+
+import (
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/goals"
+	"github.com/oki-irawan/fem_project/internal/middleware"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/utils"
+	"github.com/oki-irawan/fem_project/internal/validator"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// goalResponse is a goal with its progress at the time of the request.
+type goalResponse struct {
+	*store.Goal
+	Progress goals.Progress `json:"progress"`
+}
+
+type GoalHandler struct {
+	goalStore store.GoalStore
+	logger    *slog.Logger
+}
+
+func NewGoalHandler(goalStore store.GoalStore, logger *slog.Logger) *GoalHandler {
+	return &GoalHandler{
+		goalStore: goalStore,
+		logger:    logger,
+	}
+}
+
+func (gh *GoalHandler) progress(r *http.Request, goal *store.Goal, now time.Time) (goalResponse, error) {
+	current, err := gh.goalStore.MeasureGoal(r.Context(), goal, goals.Window(goal, now))
+	if err != nil {
+		return goalResponse{}, err
+	}
+
+	return goalResponse{Goal: goal, Progress: goals.Evaluate(goal, current, now)}, nil
+}
+
+func (gh *GoalHandler) writeGoal(w http.ResponseWriter, r *http.Request, status int, goal *store.Goal) {
+	response, err := gh.progress(r, goal, time.Now())
+	if err != nil {
+		writeError(gh.logger, w, r, "MeasureGoal", err)
+		return
+	}
+
+	utils.WriteJSON(w, status, utils.Envelope{"goal": response})
+}
+
+// HandleListGoals returns the goals of the current user with their
+// progress, oldest first.
+func (gh *GoalHandler) HandleListGoals(w http.ResponseWriter, r *http.Request) {
+	all, err := gh.goalStore.ListGoals(r.Context(), middleware.GetUser(r).ID)
+	if err != nil {
+		writeError(gh.logger, w, r, "ListGoals", err)
+		return
+	}
+
+	now := time.Now()
+	responses := make([]goalResponse, 0, len(all))
+	for _, goal := range all {
+		response, err := gh.progress(r, goal, now)
+		if err != nil {
+			writeError(gh.logger, w, r, "MeasureGoal", err)
+			return
+		}
+		responses = append(responses, response)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"goals": responses})
+}
+
+// HandleCreateGoal creates a goal for the current user. It starts today
+// unless starts_on says otherwise.
+func (gh *GoalHandler) HandleCreateGoal(w http.ResponseWriter, r *http.Request) {
+	var goal store.Goal
+	err := utils.ReadJSON(r, &goal)
+	if err != nil {
+		writeError(gh.logger, w, r, "Decoding Create Goal", err)
+		return
+	}
+	goal.UserID = middleware.GetUser(r).ID
+	if goal.StartsOn.IsZero() {
+		goal.StartsOn = store.NewDate(time.Now())
+	}
+
+	v := validator.New()
+	validator.Goal(v, &goal)
+	if err = v.Err(); err != nil {
+		writeError(gh.logger, w, r, "Validating Create Goal", err)
+		return
+	}
+
+	err = gh.goalStore.CreateGoal(r.Context(), &goal)
+	if err != nil {
+		writeError(gh.logger, w, r, "CreateGoal", err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/goals/%d", goal.ID))
+	gh.writeGoal(w, r, http.StatusCreated, &goal)
+}
+
+func (gh *GoalHandler) readGoal(w http.ResponseWriter, r *http.Request) (*store.Goal, bool) {
+	id, err := utils.ReadIdParameter(r)
+	if err != nil {
+		gh.logger.WarnContext(r.Context(), "read id parameter", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeInvalidID, "invalid goal id")
+		return nil, false
+	}
+
+	goal, err := gh.goalStore.GetGoal(r.Context(), middleware.GetUser(r).ID, id)
+	if err != nil {
+		writeError(gh.logger, w, r, "GetGoal", err)
+		return nil, false
+	}
+
+	return goal, true
+}
+
+func (gh *GoalHandler) HandleGetGoal(w http.ResponseWriter, r *http.Request) {
+	goal, ok := gh.readGoal(w, r)
+	if !ok {
+		return
+	}
+
+	gh.writeGoal(w, r, http.StatusOK, goal)
+}
+
+// HandleUpdateGoal applies the fields of the request body to a goal. Fields
+// left out keep their value. The type of a goal cannot change.
+func (gh *GoalHandler) HandleUpdateGoal(w http.ResponseWriter, r *http.Request) {
+	goal, ok := gh.readGoal(w, r)
+	if !ok {
+		return
+	}
+	id, userID, goalType := goal.ID, goal.UserID, goal.Type
+
+	err := utils.ReadJSON(r, goal)
+	if err != nil {
+		writeError(gh.logger, w, r, "Decoding Update Goal", err)
+		return
+	}
+	goal.ID, goal.UserID = id, userID
+
+	v := validator.New()
+	v.Check(goal.Type == goalType, "type", "immutable", "the type of a goal cannot be changed")
+	validator.Goal(v, goal)
+	if err = v.Err(); err != nil {
+		writeError(gh.logger, w, r, "Validating Update Goal", err)
+		return
+	}
+
+	err = gh.goalStore.UpdateGoal(r.Context(), goal)
+	if err != nil {
+		writeError(gh.logger, w, r, "UpdateGoal", err)
+		return
+	}
+
+	gh.writeGoal(w, r, http.StatusOK, goal)
+}
+
+func (gh *GoalHandler) HandleDeleteGoal(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParameter(r)
+	if err != nil {
+		gh.logger.WarnContext(r.Context(), "read id parameter", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeInvalidID, "invalid goal id")
+		return
+	}
+
+	err = gh.goalStore.DeleteGoal(r.Context(), middleware.GetUser(r).ID, id)
+	if err != nil {
+		writeError(gh.logger, w, r, "DeleteGoal", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/imports"
+	"github.com/oki-irawan/fem_project/internal/middleware"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/utils"
+	"github.com/oki-irawan/fem_project/internal/validator"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+const maxListedImports = 50
+
+type ImportHandler struct {
+	importStore store.ImportStore
+	logger      *slog.Logger
+}
+
+func NewImportHandler(importStore store.ImportStore, logger *slog.Logger) *ImportHandler {
+	return &ImportHandler{
+		importStore: importStore,
+		logger:      logger,
+	}
+}
+
+// readUpload returns the uploaded file, sent either as the file field of a
+// multipart form or as the raw request body.
+func readUpload(r *http.Request) (filename string, data []byte, err error) {
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || contentType != "multipart/form-data" {
+		data, err = io.ReadAll(r.Body)
+		return "", data, err
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", utils.ErrInvalidRequestBody, err)
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return "", nil, fmt.Errorf("%w: missing file field", utils.ErrInvalidRequestBody)
+		}
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return "", nil, maxBytesErr
+			}
+			return "", nil, fmt.Errorf("%w: %v", utils.ErrInvalidRequestBody, err)
+		}
+
+		if part.FormName() == "file" {
+			data, err = io.ReadAll(part)
+			return part.FileName(), data, err
+		}
+	}
+}
+
+// HandleCreateImport queues a CSV upload for import. The format and dry_run
+// query parameters pick the layout (auto detected by default) and whether the
+// job only reports what it would do. The job runs in the background; its
+// progress and report are read from GET /imports/{id}.
+func (ih *ImportHandler) HandleCreateImport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = string(imports.FormatAuto)
+	}
+
+	dryRun := false
+	if s := query.Get("dry_run"); s != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(s)
+		if err != nil {
+			utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeBadRequest, "dry_run must be true or false")
+			return
+		}
+	}
+
+	filename, data, err := readUpload(r)
+	if err != nil {
+		writeError(ih.logger, w, r, "Reading Import Upload", err)
+		return
+	}
+
+	v := validator.New()
+	v.In("format", format, string(imports.FormatAuto), string(imports.FormatGeneric), string(imports.FormatStrong), string(imports.FormatHevy))
+	v.Check(len(data) > 0, "file", "required", "file must not be empty")
+	v.MaxLength("filename", filename, 255)
+	if err = v.Err(); err != nil {
+		writeError(ih.logger, w, r, "Validating Import", err)
+		return
+	}
+
+	job := &store.ImportJob{
+		UserID:   middleware.GetUser(r).ID,
+		Format:   format,
+		DryRun:   dryRun,
+		Filename: filename,
+		Data:     data,
+	}
+
+	err = ih.importStore.CreateImport(r.Context(), job)
+	if err != nil {
+		writeError(ih.logger, w, r, "CreateImport", err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/imports/%d", job.ID))
+	utils.WriteJSON(w, http.StatusAccepted, utils.Envelope{"import": job})
+}
+
+func (ih *ImportHandler) HandleGetImport(w http.ResponseWriter, r *http.Request) {
+	importId, err := utils.ReadIdParameter(r)
+	if err != nil {
+		ih.logger.WarnContext(r.Context(), "read id parameter", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeInvalidID, "invalid import id")
+		return
+	}
+
+	job, err := ih.importStore.GetImport(r.Context(), middleware.GetUser(r).ID, importId)
+	if err != nil {
+		writeError(ih.logger, w, r, "GetImport", err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"import": job})
+}
+
+// HandleListImports returns the most recent imports without their reports.
+func (ih *ImportHandler) HandleListImports(w http.ResponseWriter, r *http.Request) {
+	jobs, err := ih.importStore.ListImports(r.Context(), middleware.GetUser(r).ID, maxListedImports)
+	if err != nil {
+		writeError(ih.logger, w, r, "ListImports", err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"imports": jobs})
+}
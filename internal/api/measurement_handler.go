@@ -0,0 +1,155 @@
+package api
+
+import (
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/measurements"
+	"github.com/oki-irawan/fem_project/internal/middleware"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/utils"
+	"github.com/oki-irawan/fem_project/internal/validator"
+	"log/slog"
+	"net/http"
+)
+
+type MeasurementHandler struct {
+	measurementStore store.MeasurementStore
+	logger           *slog.Logger
+}
+
+func NewMeasurementHandler(measurementStore store.MeasurementStore, logger *slog.Logger) *MeasurementHandler {
+	return &MeasurementHandler{
+		measurementStore: measurementStore,
+		logger:           logger,
+	}
+}
+
+// HandleListMeasurements returns the measurements of the current user,
+// oldest first, each with the trend of its values and their weekly change.
+// from and to limit the days returned; the trends always start from the
+// first measurement.
+func (mh *MeasurementHandler) HandleListMeasurements(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	timeRange := readTimeRange(v, r)
+	if err := v.Err(); err != nil {
+		writeError(mh.logger, w, r, "Validating Measurements Range", err)
+		return
+	}
+
+	all, err := mh.measurementStore.ListMeasurements(r.Context(), middleware.GetUser(r).ID)
+	if err != nil {
+		writeError(mh.logger, w, r, "ListMeasurements", err)
+		return
+	}
+
+	points := []measurements.Point{}
+	for _, p := range measurements.Trend(all) {
+		day := p.MeasuredOn.Time
+		if (timeRange.From.IsZero() || !day.Before(store.NewDate(timeRange.From).Time)) &&
+			(timeRange.To.IsZero() || day.Before(timeRange.To)) {
+			points = append(points, p)
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"measurements": points})
+}
+
+func (mh *MeasurementHandler) HandleCreateMeasurement(w http.ResponseWriter, r *http.Request) {
+	var m store.BodyMeasurement
+	err := utils.ReadJSON(r, &m)
+	if err != nil {
+		writeError(mh.logger, w, r, "Decoding Create Measurement", err)
+		return
+	}
+	m.UserID = middleware.GetUser(r).ID
+
+	v := validator.New()
+	validator.Measurement(v, &m)
+	if err = v.Err(); err != nil {
+		writeError(mh.logger, w, r, "Validating Create Measurement", err)
+		return
+	}
+
+	err = mh.measurementStore.CreateMeasurement(r.Context(), &m)
+	if err != nil {
+		writeError(mh.logger, w, r, "CreateMeasurement", err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/users/me/measurements/%d", m.ID))
+	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"measurement": m})
+}
+
+func (mh *MeasurementHandler) readMeasurement(w http.ResponseWriter, r *http.Request) (*store.BodyMeasurement, bool) {
+	id, err := utils.ReadIdParameter(r)
+	if err != nil {
+		mh.logger.WarnContext(r.Context(), "read id parameter", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeInvalidID, "invalid measurement id")
+		return nil, false
+	}
+
+	m, err := mh.measurementStore.GetMeasurement(r.Context(), middleware.GetUser(r).ID, id)
+	if err != nil {
+		writeError(mh.logger, w, r, "GetMeasurement", err)
+		return nil, false
+	}
+
+	return m, true
+}
+
+func (mh *MeasurementHandler) HandleGetMeasurement(w http.ResponseWriter, r *http.Request) {
+	m, ok := mh.readMeasurement(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"measurement": m})
+}
+
+// HandleUpdateMeasurement applies the fields of the request body to a
+// measurement. Fields left out keep their value and null clears a value.
+func (mh *MeasurementHandler) HandleUpdateMeasurement(w http.ResponseWriter, r *http.Request) {
+	m, ok := mh.readMeasurement(w, r)
+	if !ok {
+		return
+	}
+	id, userID := m.ID, m.UserID
+
+	err := utils.ReadJSON(r, m)
+	if err != nil {
+		writeError(mh.logger, w, r, "Decoding Update Measurement", err)
+		return
+	}
+	m.ID, m.UserID = id, userID
+
+	v := validator.New()
+	validator.Measurement(v, m)
+	if err = v.Err(); err != nil {
+		writeError(mh.logger, w, r, "Validating Update Measurement", err)
+		return
+	}
+
+	err = mh.measurementStore.UpdateMeasurement(r.Context(), m)
+	if err != nil {
+		writeError(mh.logger, w, r, "UpdateMeasurement", err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"measurement": m})
+}
+
+func (mh *MeasurementHandler) HandleDeleteMeasurement(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParameter(r)
+	if err != nil {
+		mh.logger.WarnContext(r.Context(), "read id parameter", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeInvalidID, "invalid measurement id")
+		return
+	}
+
+	err = mh.measurementStore.DeleteMeasurement(r.Context(), middleware.GetUser(r).ID, id)
+	if err != nil {
+		writeError(mh.logger, w, r, "DeleteMeasurement", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
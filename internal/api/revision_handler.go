@@ -0,0 +1,181 @@
+package api
+
+import (
+	"github.com/oki-irawan/fem_project/internal/history"
+	"github.com/oki-irawan/fem_project/internal/middleware"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/utils"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+type RevisionHandler struct {
+	revisionStore store.RevisionStore
+	workoutStore  store.WorkoutStore
+	logger        *slog.Logger
+}
+
+func NewRevisionHandler(revisionStore store.RevisionStore, workoutStore store.WorkoutStore, logger *slog.Logger) *RevisionHandler {
+	return &RevisionHandler{
+		revisionStore: revisionStore,
+		workoutStore:  workoutStore,
+		logger:        logger,
+	}
+}
+
+// readOwnedWorkoutID reads the workout id of the request and checks that the
+// current user owns the workout. It writes the error response itself.
+func (rh *RevisionHandler) readOwnedWorkoutID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	workoutId, err := utils.ReadIdParameter(r)
+	if err != nil {
+		rh.logger.WarnContext(r.Context(), "read id parameter", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeInvalidID, "invalid workout id")
+		return 0, false
+	}
+
+	err = checkWorkoutOwner(r, rh.workoutStore, workoutId, middleware.GetUser(r))
+	if err != nil {
+		writeError(rh.logger, w, r, "GetWorkoutOwner", err)
+		return 0, false
+	}
+
+	return workoutId, true
+}
+
+func (rh *RevisionHandler) readRevision(w http.ResponseWriter, r *http.Request, workoutId int64) (*store.Revision, bool) {
+	version, err := utils.ReadIntParameter(r, "rev")
+	if err != nil {
+		rh.logger.WarnContext(r.Context(), "read rev parameter", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeInvalidID, "invalid revision")
+		return nil, false
+	}
+
+	revision, err := rh.revisionStore.GetRevision(r.Context(), workoutId, int(version))
+	if err != nil {
+		writeError(rh.logger, w, r, "GetRevision", err)
+		return nil, false
+	}
+
+	return revision, true
+}
+
+func (rh *RevisionHandler) HandleListRevisions(w http.ResponseWriter, r *http.Request) {
+	workoutId, ok := rh.readOwnedWorkoutID(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := rh.revisionStore.ListRevisions(r.Context(), workoutId)
+	if err != nil {
+		writeError(rh.logger, w, r, "ListRevisions", err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"revisions": revisions})
+}
+
+func (rh *RevisionHandler) HandleGetRevision(w http.ResponseWriter, r *http.Request) {
+	workoutId, ok := rh.readOwnedWorkoutID(w, r)
+	if !ok {
+		return
+	}
+
+	revision, ok := rh.readRevision(w, r, workoutId)
+	if !ok {
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"revision": revision})
+}
+
+// HandleDiffRevisions compares revision from with revision to, which
+// defaults to the current version.
+func (rh *RevisionHandler) HandleDiffRevisions(w http.ResponseWriter, r *http.Request) {
+	workoutId, ok := rh.readOwnedWorkoutID(w, r)
+	if !ok {
+		return
+	}
+
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeBadRequest, "from must be a revision number")
+		return
+	}
+
+	var to int
+	if s := r.URL.Query().Get("to"); s != "" {
+		to, err = strconv.Atoi(s)
+		if err != nil {
+			utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeBadRequest, "to must be a revision number")
+			return
+		}
+	} else {
+		current, err := rh.workoutStore.GetWorkoutByID(r.Context(), workoutId)
+		if err != nil {
+			writeError(rh.logger, w, r, "GetWorkoutByID", err)
+			return
+		}
+		to = current.Version
+	}
+
+	fromRevision, err := rh.revisionStore.GetRevision(r.Context(), workoutId, from)
+	if err != nil {
+		writeError(rh.logger, w, r, "GetRevision", err)
+		return
+	}
+
+	toRevision, err := rh.revisionStore.GetRevision(r.Context(), workoutId, to)
+	if err != nil {
+		writeError(rh.logger, w, r, "GetRevision", err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"diff": history.Compare(fromRevision.Snapshot, toRevision.Snapshot)})
+}
+
+// HandleRevertRevision saves the content of an old revision as a new
+// version. History is kept: the revert is a revision of its own.
+func (rh *RevisionHandler) HandleRevertRevision(w http.ResponseWriter, r *http.Request) {
+	workoutId, ok := rh.readOwnedWorkoutID(w, r)
+	if !ok {
+		return
+	}
+
+	workout, err := rh.workoutStore.GetWorkoutByID(r.Context(), workoutId)
+	if err != nil {
+		writeError(rh.logger, w, r, "GetWorkoutByID", err)
+		return
+	}
+
+	if match := r.Header.Get("If-Match"); match != "" && !utils.MatchETag(match, utils.ETag(workout.Version)) {
+		writeError(rh.logger, w, r, "If-Match", store.ErrVersionMismatch)
+		return
+	}
+
+	revision, ok := rh.readRevision(w, r, workoutId)
+	if !ok {
+		return
+	}
+
+	snapshot := revision.Snapshot
+	workout.Title = snapshot.Title
+	workout.Description = snapshot.Description
+	workout.DurationMinutes = snapshot.DurationMinutes
+	workout.CaloriesBurned = snapshot.CaloriesBurned
+	if snapshot.CaloriesSource == store.CaloriesEstimated {
+		// estimated again, from the entries as they were
+		workout.CaloriesBurned = 0
+	}
+	workout.PerformedAt = snapshot.PerformedAt
+	workout.Entries = snapshot.Entries
+
+	err = rh.workoutStore.RevertWorkout(r.Context(), workout, revision.Version)
+	if err != nil {
+		writeError(rh.logger, w, r, "RevertWorkout", err)
+		return
+	}
+
+	w.Header().Set("ETag", utils.ETag(workout.Version))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout})
+}
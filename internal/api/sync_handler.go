@@ -0,0 +1,309 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/metrics"
+	"github.com/oki-irawan/fem_project/internal/middleware"
+	"github.com/oki-irawan/fem_project/internal/records"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/utils"
+	"github.com/oki-irawan/fem_project/internal/validator"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultSyncLimit = 100
+	maxSyncLimit     = 500
+	maxSyncChanges   = 100
+)
+
+// Statuses of a change pushed with POST /sync.
+const (
+	syncApplied  = "applied"
+	syncConflict = "conflict"
+	syncInvalid  = "invalid"
+	syncError    = "error"
+)
+
+type SyncHandler struct {
+	syncStore    store.SyncStore
+	workoutStore store.WorkoutStore
+	logger       *slog.Logger
+}
+
+func NewSyncHandler(syncStore store.SyncStore, workoutStore store.WorkoutStore, logger *slog.Logger) *SyncHandler {
+	return &SyncHandler{
+		syncStore:    syncStore,
+		workoutStore: workoutStore,
+		logger:       logger,
+	}
+}
+
+// encodeCursor makes the cursor opaque to clients; they only hand it back.
+func encodeCursor(c store.SyncCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%d", c.TxID, c.Seq)))
+}
+
+func decodeCursor(s string) (store.SyncCursor, error) {
+	if s == "" {
+		return store.SyncCursor{}, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return store.SyncCursor{}, err
+	}
+
+	txID, seq, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return store.SyncCursor{}, errors.New("malformed cursor")
+	}
+
+	var c store.SyncCursor
+	if c.TxID, err = strconv.ParseInt(txID, 10, 64); err != nil {
+		return store.SyncCursor{}, err
+	}
+	if c.Seq, err = strconv.ParseInt(seq, 10, 64); err != nil {
+		return store.SyncCursor{}, err
+	}
+
+	return c, nil
+}
+
+// HandleGetChanges returns everything that changed since the cursor in the
+// since parameter; without it the client gets a full copy of its data. The
+// returned cursor is passed as since on the next call, and has_more tells the
+// client to call again right away. A cursor older than the tombstone
+// retention is answered with 410 Gone and the client has to start over.
+//
+// Workouts come with their entries, next to the body measurements and goals
+// that changed. Deletions of any of them are listed in deleted, by entity.
+func (sh *SyncHandler) HandleGetChanges(w http.ResponseWriter, r *http.Request) {
+	since, err := decodeCursor(r.URL.Query().Get("since"))
+	if err != nil {
+		sh.logger.WarnContext(r.Context(), "decode sync cursor", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeBadRequest, "invalid sync cursor")
+		return
+	}
+
+	limit := defaultSyncLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxSyncLimit {
+			utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxSyncLimit))
+			return
+		}
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	changes, err := sh.syncStore.ListChanges(r.Context(), currentUser.ID, since, limit)
+	if err != nil {
+		writeError(sh.logger, w, r, "ListChanges", err)
+		return
+	}
+
+	workouts := changes.Workouts
+	if workouts == nil {
+		workouts = []*store.Workout{}
+	}
+	measurements := changes.Measurements
+	if measurements == nil {
+		measurements = []*store.BodyMeasurement{}
+	}
+	goals := changes.Goals
+	if goals == nil {
+		goals = []*store.Goal{}
+	}
+	deleted := changes.Deletions
+	if deleted == nil {
+		deleted = []store.Deletion{}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{
+		"workouts":     workouts,
+		"measurements": measurements,
+		"goals":        goals,
+		"deleted":      deleted,
+		"cursor":       encodeCursor(changes.Cursor),
+		"has_more":     changes.HasMore,
+	})
+}
+
+// syncChange is one offline change. BaseVersion is the version the client
+// last saw, 0 for a workout it created itself.
+type syncChange struct {
+	Entity      string         `json:"entity"`
+	Op          string         `json:"op"`
+	UUID        string         `json:"uuid"`
+	BaseVersion int            `json:"base_version"`
+	Workout     *store.Workout `json:"workout"`
+}
+
+func (c *syncChange) Validate(v *validator.Validator) {
+	// entries travel inside their workout
+	v.In("entity", c.Entity, "workout")
+	v.In("op", c.Op, "upsert", "delete")
+	v.UUID("uuid", c.UUID)
+	v.Min("base_version", c.BaseVersion, 0)
+
+	if c.Op == "upsert" {
+		if c.Workout == nil {
+			v.AddError("workout", "required", "workout is required")
+			return
+		}
+		validator.Workout(v.Field("workout"), c.Workout)
+	}
+}
+
+type syncResult struct {
+	UUID   string             `json:"uuid"`
+	Status string             `json:"status"`
+	Errors []store.FieldError `json:"errors,omitempty"`
+	// Workout is the server copy after the change was applied, or the copy
+	// the change conflicted with.
+	Workout *store.Workout `json:"workout,omitempty"`
+}
+
+// HandlePushChanges applies a batch of offline changes. Each change succeeds
+// or fails on its own; a conflict returns the server copy so the client can
+// resolve it and push again.
+func (sh *SyncHandler) HandlePushChanges(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Changes []syncChange `json:"changes"`
+	}
+
+	err := utils.ReadJSON(r, &req)
+	if err != nil {
+		writeError(sh.logger, w, r, "Decoding Sync Changes", err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(len(req.Changes) > 0, "changes", "required", "changes is required")
+	v.Max("changes", len(req.Changes), maxSyncChanges)
+	if err = v.Err(); err != nil {
+		writeError(sh.logger, w, r, "Validating Sync Changes", err)
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	results := make([]syncResult, 0, len(req.Changes))
+	for i := range req.Changes {
+		results = append(results, sh.apply(r, currentUser, &req.Changes[i]))
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"results": results})
+}
+
+func (sh *SyncHandler) apply(r *http.Request, user *store.User, change *syncChange) syncResult {
+	result := syncResult{UUID: change.UUID}
+
+	if err := validator.Validate(change); err != nil {
+		return sh.failed(r, user, result, err)
+	}
+
+	existing, err := sh.workoutStore.GetWorkoutByUUID(r.Context(), user.ID, change.UUID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return sh.failed(r, user, result, err)
+	}
+
+	if change.Op == "delete" {
+		if existing == nil {
+			// already gone
+			result.Status = syncApplied
+			return result
+		}
+		if change.BaseVersion != 0 && change.BaseVersion != existing.Version {
+			result.Status = syncConflict
+			result.Workout = existing
+			return result
+		}
+
+		err = sh.workoutStore.DeleteWorkout(r.Context(), int64(existing.ID))
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return sh.failed(r, user, result, err)
+		}
+
+		result.Status = syncApplied
+		return result
+	}
+
+	workout := change.Workout
+	workout.UUID = change.UUID
+	workout.UserID = user.ID
+
+	if existing == nil {
+		if change.BaseVersion != 0 {
+			// the client edited a workout that was deleted here meanwhile
+			result.Status = syncConflict
+			return result
+		}
+
+		previous := records.Previous(r.Context(), sh.logger, sh.workoutStore, workout)
+
+		created, err := sh.workoutStore.CreateWorkout(r.Context(), workout)
+		if err != nil {
+			return sh.failed(r, user, result, err)
+		}
+
+		metrics.WorkoutsCreated.Inc()
+		records.Record(previous, created)
+		result.Status = syncApplied
+		result.Workout = created
+		return result
+	}
+
+	if change.BaseVersion != existing.Version {
+		result.Status = syncConflict
+		result.Workout = existing
+		return result
+	}
+
+	workout.ID = existing.ID
+	workout.Version = change.BaseVersion
+
+	previous := records.Previous(r.Context(), sh.logger, sh.workoutStore, workout)
+
+	err = sh.workoutStore.UpdateWorkout(r.Context(), workout)
+	if err != nil {
+		return sh.failed(r, user, result, err)
+	}
+
+	records.Record(previous, workout)
+
+	result.Status = syncApplied
+	result.Workout = workout
+	return result
+}
+
+// failed fills in result for a change that could not be applied.
+func (sh *SyncHandler) failed(r *http.Request, user *store.User, result syncResult, err error) syncResult {
+	var validationErr *store.ValidationError
+	var conflictErr *store.ConflictError
+
+	switch {
+	case errors.As(err, &validationErr):
+		result.Status = syncInvalid
+		result.Errors = validationErr.Fields
+	case errors.As(err, &conflictErr):
+		result.Status = syncConflict
+		result.Errors = []store.FieldError{{Field: conflictErr.Field, Code: "conflict", Message: conflictErr.Error()}}
+	case errors.Is(err, store.ErrVersionMismatch):
+		result.Status = syncConflict
+		if current, err := sh.workoutStore.GetWorkoutByUUID(r.Context(), user.ID, result.UUID); err == nil {
+			result.Workout = current
+		}
+	default:
+		sh.logger.ErrorContext(r.Context(), "apply sync change", "uuid", result.UUID, "error", err)
+		result.Status = syncError
+	}
+
+	return result
+}
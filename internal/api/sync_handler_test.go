@@ -0,0 +1,252 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	pushDayUUID  = "2f1c9a52-8d3e-4b7a-9c61-0e5d4f3a2b10"
+	legDayUUID   = "7b0e4c1d-3a5f-4e2b-8d6c-9f1a2b3c4d5e"
+	otherDayUUID = "c3d2e1f0-1a2b-4c3d-9e4f-5a6b7c8d9e0f"
+)
+
+type fakeSyncStore struct {
+	store.SyncStore
+	changes *store.ChangeSet
+	err     error
+}
+
+func (s *fakeSyncStore) ListChanges(ctx context.Context, userID int, since store.SyncCursor, limit int) (*store.ChangeSet, error) {
+	return s.changes, s.err
+}
+
+func TestSyncCursor(t *testing.T) {
+	cursor := store.SyncCursor{TxID: 912, Seq: 40817}
+
+	decoded, err := decodeCursor(encodeCursor(cursor))
+	require.NoError(t, err)
+	assert.Equal(t, cursor, decoded)
+
+	decoded, err = decodeCursor("")
+	require.NoError(t, err)
+	assert.Equal(t, store.SyncCursor{}, decoded)
+
+	_, err = decodeCursor("bm90LWEtY3Vyc29y")
+	assert.Error(t, err)
+}
+
+func TestHandleGetChanges(t *testing.T) {
+	user := &store.User{ID: 1}
+	cursor := encodeCursor(store.SyncCursor{TxID: 10, Seq: 3})
+
+	test := []struct {
+		name       string
+		query      string
+		syncStore  *fakeSyncStore
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "Changes",
+			query:      "?since=" + cursor,
+			syncStore:  &fakeSyncStore{changes: &store.ChangeSet{Cursor: store.SyncCursor{TxID: 11, Seq: 1}}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Invalid Cursor",
+			query:      "?since=%25",
+			syncStore:  &fakeSyncStore{},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "bad_request",
+		},
+		{
+			name:       "Limit Too Large",
+			query:      "?limit=501",
+			syncStore:  &fakeSyncStore{},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "bad_request",
+		},
+		{
+			name:       "Expired Cursor",
+			query:      "?since=" + cursor,
+			syncStore:  &fakeSyncStore{err: store.ErrCursorExpired},
+			wantStatus: http.StatusGone,
+			wantCode:   "sync_cursor_expired",
+		},
+	}
+
+	for _, tt := range test {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewSyncHandler(tt.syncStore, newFakeWorkoutStore(), discardLogger())
+
+			w := httptest.NewRecorder()
+			handler.HandleGetChanges(w, newUserRequest(http.MethodGet, "/sync"+tt.query, "", user))
+			require.Equal(t, tt.wantStatus, w.Code)
+
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			if tt.wantCode != "" {
+				assert.Equal(t, tt.wantCode, body["code"])
+				return
+			}
+
+			assert.Equal(t, []any{}, body["workouts"])
+			assert.Equal(t, []any{}, body["measurements"])
+			assert.Equal(t, []any{}, body["goals"])
+			assert.Equal(t, []any{}, body["deleted"])
+			assert.Equal(t, encodeCursor(store.SyncCursor{TxID: 11, Seq: 1}), body["cursor"])
+			assert.Equal(t, false, body["has_more"])
+		})
+	}
+}
+
+func TestHandlePushChanges(t *testing.T) {
+	user := &store.User{ID: 1}
+	workout := func(title string) map[string]any {
+		return map[string]any{"title": title, "duration_minutes": 45, "performed_at": time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC)}
+	}
+
+	test := []struct {
+		name        string
+		change      map[string]any
+		updateErr   error
+		wantStatus  string
+		wantVersion int
+		wantTitle   string
+		wantFields  []string
+	}{
+		{
+			name:        "Create",
+			change:      map[string]any{"entity": "workout", "op": "upsert", "uuid": otherDayUUID, "workout": workout("Arms")},
+			wantStatus:  syncApplied,
+			wantVersion: 1,
+			wantTitle:   "Arms",
+		},
+		{
+			name:        "Update",
+			change:      map[string]any{"entity": "workout", "op": "upsert", "uuid": pushDayUUID, "base_version": 2, "workout": workout("Push Day Heavy")},
+			wantStatus:  syncApplied,
+			wantVersion: 3,
+			wantTitle:   "Push Day Heavy",
+		},
+		{
+			name:        "Stale Update",
+			change:      map[string]any{"entity": "workout", "op": "upsert", "uuid": pushDayUUID, "base_version": 1, "workout": workout("Push Day Heavy")},
+			wantStatus:  syncConflict,
+			wantVersion: 2,
+			wantTitle:   "Push Day",
+		},
+		{
+			name:        "Update Lost A Race",
+			change:      map[string]any{"entity": "workout", "op": "upsert", "uuid": pushDayUUID, "base_version": 2, "workout": workout("Push Day Heavy")},
+			updateErr:   store.ErrVersionMismatch,
+			wantStatus:  syncConflict,
+			wantVersion: 2,
+			wantTitle:   "Push Day",
+		},
+		{
+			name:       "Update Of A Deleted Workout",
+			change:     map[string]any{"entity": "workout", "op": "upsert", "uuid": otherDayUUID, "base_version": 4, "workout": workout("Arms")},
+			wantStatus: syncConflict,
+		},
+		{
+			name:       "Title Taken",
+			change:     map[string]any{"entity": "workout", "op": "upsert", "uuid": pushDayUUID, "base_version": 2, "workout": workout("Leg Day")},
+			updateErr:  &store.ConflictError{Constraint: "workouts_title_key", Field: "title"},
+			wantStatus: syncConflict,
+			wantFields: []string{"title"},
+		},
+		{
+			name:       "Delete",
+			change:     map[string]any{"entity": "workout", "op": "delete", "uuid": legDayUUID, "base_version": 1},
+			wantStatus: syncApplied,
+		},
+		{
+			name:        "Stale Delete",
+			change:      map[string]any{"entity": "workout", "op": "delete", "uuid": pushDayUUID, "base_version": 1},
+			wantStatus:  syncConflict,
+			wantVersion: 2,
+			wantTitle:   "Push Day",
+		},
+		{
+			name:       "Delete Of A Deleted Workout",
+			change:     map[string]any{"entity": "workout", "op": "delete", "uuid": otherDayUUID, "base_version": 1},
+			wantStatus: syncApplied,
+		},
+		{
+			name:       "Invalid",
+			change:     map[string]any{"entity": "template", "op": "upsert", "uuid": "not-a-uuid", "workout": workout("")},
+			wantStatus: syncInvalid,
+			wantFields: []string{"entity", "uuid", "workout.title"},
+		},
+	}
+
+	for _, tt := range test {
+		t.Run(tt.name, func(t *testing.T) {
+			workouts := newFakeWorkoutStore(
+				&store.Workout{UUID: pushDayUUID, UserID: 1, Title: "Push Day", DurationMinutes: 60, Version: 2},
+				&store.Workout{UUID: legDayUUID, UserID: 1, Title: "Leg Day", DurationMinutes: 60},
+			)
+			workouts.updateErr = tt.updateErr
+			handler := NewSyncHandler(&fakeSyncStore{}, workouts, discardLogger())
+
+			body, err := json.Marshal(map[string]any{"changes": []any{tt.change}})
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			handler.HandlePushChanges(w, newUserRequest(http.MethodPost, "/sync", string(body), user))
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var response struct {
+				Results []syncResult `json:"results"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Len(t, response.Results, 1)
+
+			result := response.Results[0]
+			assert.Equal(t, tt.change["uuid"], result.UUID)
+			assert.Equal(t, tt.wantStatus, result.Status)
+
+			fields := make([]string, 0, len(result.Errors))
+			for _, f := range result.Errors {
+				fields = append(fields, f.Field)
+			}
+			assert.ElementsMatch(t, tt.wantFields, fields)
+
+			if tt.wantTitle == "" {
+				assert.Nil(t, result.Workout)
+				return
+			}
+			require.NotNil(t, result.Workout)
+			assert.Equal(t, tt.wantTitle, result.Workout.Title)
+			assert.Equal(t, tt.wantVersion, result.Workout.Version)
+		})
+	}
+}
+
+func TestHandlePushChangesLimits(t *testing.T) {
+	handler := NewSyncHandler(&fakeSyncStore{}, newFakeWorkoutStore(), discardLogger())
+	user := &store.User{ID: 1}
+
+	changes := make([]map[string]any, maxSyncChanges+1)
+	for i := range changes {
+		changes[i] = map[string]any{"entity": "workout", "op": "delete", "uuid": pushDayUUID}
+	}
+
+	for _, body := range []any{map[string]any{"changes": []any{}}, map[string]any{"changes": changes}} {
+		js, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		handler.HandlePushChanges(w, newUserRequest(http.MethodPost, "/sync", string(js), user))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	}
+}
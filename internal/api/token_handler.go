@@ -1,11 +1,11 @@
 package api
 
 import (
-	"encoding/json"
 	"github.com/oki-irawan/fem_project/internal/store"
 	"github.com/oki-irawan/fem_project/internal/tokens"
 	"github.com/oki-irawan/fem_project/internal/utils"
-	"log"
+	"github.com/oki-irawan/fem_project/internal/validator"
+	"log/slog"
 	"net/http"
 	"time"
 )
@@ -13,7 +13,7 @@ import (
 type TokenHandler struct {
 	tokenStore store.TokenStore
 	userStore  store.UserStore
-	logger     *log.Logger
+	logger     *slog.Logger
 }
 
 type createTokenRequest struct {
@@ -21,7 +21,12 @@ type createTokenRequest struct {
 	Password string `json:"password"`
 }
 
-func NewTokenHandler(tokenStore store.TokenStore, userStore store.UserStore, logger *log.Logger) *TokenHandler {
+func (req *createTokenRequest) Validate(v *validator.Validator) {
+	v.Required("username", req.Username)
+	v.Required("password", req.Password)
+}
+
+func NewTokenHandler(tokenStore store.TokenStore, userStore store.UserStore, logger *slog.Logger) *TokenHandler {
 	return &TokenHandler{
 		tokenStore: tokenStore,
 		userStore:  userStore,
@@ -31,48 +36,49 @@ func NewTokenHandler(tokenStore store.TokenStore, userStore store.UserStore, log
 
 func (t *TokenHandler) HandlerCreateToken(w http.ResponseWriter, r *http.Request) {
 	var req createTokenRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
+	err := utils.ReadJSON(r, &req)
+	if err != nil {
+		writeError(t.logger, w, r, "Decoding Create Token", err)
+		return
+	}
 
+	err = validator.Validate(&req)
 	if err != nil {
-		t.logger.Printf("ERROR: Decoding Create Token: %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Invalid request body"})
+		writeError(t.logger, w, r, "Validating Create Token", err)
 		return
 	}
 
 	// get user from username
-	user, err := t.userStore.GetUserByUsername(req.Username)
+	user, err := t.userStore.GetUserByUsername(r.Context(), req.Username)
 	if err != nil {
-		t.logger.Printf("ERROR: GetUserByUsername: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		writeError(t.logger, w, r, "GetUserByUsername", err)
 		return
 	}
 
 	// user doesn't exist
 	if user == nil {
-		t.logger.Printf("ERROR: GetUserByUsername: user not found %v", err)
-		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "Invalid username and password"})
+		t.logger.InfoContext(r.Context(), "login failed: user not found", "username", req.Username)
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.CodeInvalidCredentials, "Invalid username and password")
 		return
 	}
 
 	// compare password
 	passwordDoMatch, err := user.PasswordHash.Matches(req.Password)
 	if err != nil {
-		t.logger.Printf("ERROR: PasswordHash.Matches: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		writeError(t.logger, w, r, "PasswordHash.Matches", err)
 		return
 	}
 
 	// password doesn't match
 	if !passwordDoMatch {
-		t.logger.Printf("ERROR: PasswordHash.Matches: password not match %v", err)
-		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "Invalid username and password"})
+		t.logger.InfoContext(r.Context(), "login failed: password does not match", "user_id", user.ID)
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.CodeInvalidCredentials, "Invalid username and password")
 		return
 	}
 
-	token, err := t.tokenStore.CreateNewToken(int64(user.ID), 24*time.Hour, tokens.ScopeAuth)
+	token, err := t.tokenStore.CreateNewToken(r.Context(), int64(user.ID), 24*time.Hour, tokens.ScopeAuth)
 	if err != nil {
-		t.logger.Printf("ERROR: CreateNewToken: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		writeError(t.logger, w, r, "CreateNewToken", err)
 		return
 	}
 
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/middleware"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/training"
+	"github.com/oki-irawan/fem_project/internal/utils"
+	"github.com/oki-irawan/fem_project/internal/validator"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// maxTrainingLoadDays bounds the days a training load request covers.
+const maxTrainingLoadDays = 366
+
+type heartRateSettingsRequest struct {
+	Method                    string `json:"method"`
+	MaxHeartRate              *int   `json:"max_heart_rate"`
+	RestingHeartRate          *int   `json:"resting_heart_rate"`
+	LactateThresholdHeartRate *int   `json:"lactate_threshold_heart_rate"`
+}
+
+type TrainingHandler struct {
+	trainingStore store.TrainingStore
+	workoutStore  store.WorkoutStore
+	logger        *slog.Logger
+}
+
+func NewTrainingHandler(trainingStore store.TrainingStore, workoutStore store.WorkoutStore, logger *slog.Logger) *TrainingHandler {
+	return &TrainingHandler{
+		trainingStore: trainingStore,
+		workoutStore:  workoutStore,
+		logger:        logger,
+	}
+}
+
+func (th *TrainingHandler) writeSettings(w http.ResponseWriter, r *http.Request, settings *store.HeartRateSettings) {
+	zones, err := training.Zones(settings)
+	if err != nil {
+		writeError(th.logger, w, r, "Heart Rate Zones", err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"heart_rate_settings": settings, "zones": zones})
+}
+
+// HandleGetHeartRateZones returns the heart rate settings of the current
+// user and the zones they give.
+func (th *TrainingHandler) HandleGetHeartRateZones(w http.ResponseWriter, r *http.Request) {
+	settings, err := th.trainingStore.GetHeartRateSettings(r.Context(), middleware.GetUser(r).ID)
+	if err != nil {
+		writeError(th.logger, w, r, "GetHeartRateSettings", err)
+		return
+	}
+
+	th.writeSettings(w, r, settings)
+}
+
+// HandlePutHeartRateZones replaces the heart rate settings of the current
+// user. The training loads of their workouts are recomputed in the
+// background.
+func (th *TrainingHandler) HandlePutHeartRateZones(w http.ResponseWriter, r *http.Request) {
+	var req heartRateSettingsRequest
+	err := utils.ReadJSON(r, &req)
+	if err != nil {
+		writeError(th.logger, w, r, "Decoding Heart Rate Settings", err)
+		return
+	}
+
+	settings := &store.HeartRateSettings{
+		UserID:                    middleware.GetUser(r).ID,
+		Method:                    req.Method,
+		MaxHeartRate:              req.MaxHeartRate,
+		RestingHeartRate:          req.RestingHeartRate,
+		LactateThresholdHeartRate: req.LactateThresholdHeartRate,
+	}
+
+	v := validator.New()
+	validator.HeartRateSettings(v, settings)
+	if err = v.Err(); err != nil {
+		writeError(th.logger, w, r, "Validating Heart Rate Settings", err)
+		return
+	}
+
+	err = th.trainingStore.PutHeartRateSettings(r.Context(), settings)
+	if err != nil {
+		writeError(th.logger, w, r, "PutHeartRateSettings", err)
+		return
+	}
+
+	th.writeSettings(w, r, settings)
+}
+
+// HandleGetWorkoutTrainingLoad returns the time in zone and TRIMP of a
+// workout. Workouts without heart rate data have none.
+func (th *TrainingHandler) HandleGetWorkoutTrainingLoad(w http.ResponseWriter, r *http.Request) {
+	workoutId, err := utils.ReadIdParameter(r)
+	if err != nil {
+		th.logger.WarnContext(r.Context(), "read id parameter", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeInvalidID, "invalid workout id")
+		return
+	}
+
+	err = checkWorkoutOwner(r, th.workoutStore, workoutId, middleware.GetUser(r))
+	if err != nil {
+		writeError(th.logger, w, r, "GetWorkoutOwner", err)
+		return
+	}
+
+	load, err := th.trainingStore.GetTrainingLoad(r.Context(), workoutId)
+	if err != nil {
+		writeError(th.logger, w, r, "GetTrainingLoad", err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"training_load": load})
+}
+
+// HandleTrainingLoad returns the daily training load of the current user
+// with the acute:chronic workload ratio, for the last four weeks unless from
+// and to say otherwise. A warning is added when the latest day is in the
+// overreaching or high risk range.
+func (th *TrainingHandler) HandleTrainingLoad(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	timeRange := readTimeRange(v, r)
+	if err := v.Err(); err != nil {
+		writeError(th.logger, w, r, "Validating Training Load", err)
+		return
+	}
+
+	// whole UTC days, the last one included
+	to := timeRange.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	to = to.UTC()
+	if day := to.Truncate(24 * time.Hour); !day.Equal(to) {
+		to = day.AddDate(0, 0, 1)
+	}
+	from := timeRange.From.UTC().Truncate(24 * time.Hour)
+	if timeRange.From.IsZero() {
+		from = to.AddDate(0, 0, -training.ChronicDays)
+	}
+
+	v.Check(to.Sub(from) <= maxTrainingLoadDays*24*time.Hour, "from", "too_long", fmt.Sprintf("the range must not exceed %d days", maxTrainingLoadDays))
+	if err := v.Err(); err != nil {
+		writeError(th.logger, w, r, "Validating Training Load", err)
+		return
+	}
+
+	daily, err := th.trainingStore.DailyTrainingLoads(r.Context(), middleware.GetUser(r).ID, store.TimeRange{
+		From: from.AddDate(0, 0, -(training.ChronicDays - 1)),
+		To:   to,
+	})
+	if err != nil {
+		writeError(th.logger, w, r, "DailyTrainingLoads", err)
+		return
+	}
+
+	days := training.Workload(daily, from, to)
+	result := utils.Envelope{
+		"days":         days,
+		"acute_days":   training.AcuteDays,
+		"chronic_days": training.ChronicDays,
+	}
+	if len(days) > 0 {
+		switch latest := days[len(days)-1]; latest.Status {
+		case training.StatusOverreaching:
+			result["warning"] = "your load this week is well above your usual; plan some easier days"
+		case training.StatusHighRisk:
+			result["warning"] = "your load this week is far above your usual, which raises the risk of overtraining and injury"
+		}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"training_load": result})
+}
@@ -1,13 +1,12 @@
 package api
 
 import (
-	"encoding/json"
-	"errors"
+	"github.com/oki-irawan/fem_project/internal/middleware"
 	"github.com/oki-irawan/fem_project/internal/store"
 	"github.com/oki-irawan/fem_project/internal/utils"
-	"log"
+	"github.com/oki-irawan/fem_project/internal/validator"
+	"log/slog"
 	"net/http"
-	"regexp"
 )
 
 type registerUserRequest struct {
@@ -17,53 +16,40 @@ type registerUserRequest struct {
 	Bio      string `json:"bio"`
 }
 
+func (req *registerUserRequest) Validate(v *validator.Validator) {
+	v.Required("username", req.Username)
+	v.MaxLength("username", req.Username, 50)
+	v.Required("email", req.Email)
+	v.MaxLength("email", req.Email, 255)
+	v.Email("email", req.Email)
+	v.Required("password", req.Password)
+	v.Check(len(req.Password) <= 72, "password", "too_long", "password must be at most 72 bytes")
+}
+
 type UserHandler struct {
 	userStore store.UserStore
-	logger    *log.Logger
+	logger    *slog.Logger
 }
 
-func NewUserHandler(userStore store.UserStore, logger *log.Logger) *UserHandler {
+func NewUserHandler(userStore store.UserStore, logger *slog.Logger) *UserHandler {
 	return &UserHandler{
 		userStore: userStore,
 		logger:    logger,
 	}
 }
 
-func (uh *UserHandler) validateRegisterUserReq(req *registerUserRequest) error {
-	if req.Username == "" {
-		return errors.New("username is required")
-	}
-
-	if req.Email == "" {
-		return errors.New("email is required")
-	}
-
-	emailRegex := regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
-	if !emailRegex.MatchString(req.Email) {
-		return errors.New("invalid email format")
-	}
-
-	if req.Password == "" {
-		return errors.New("password is required")
-	}
-
-	return nil
-}
-
 func (uh *UserHandler) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
 	var req registerUserRequest
 
-	err := json.NewDecoder(r.Body).Decode(&req)
+	err := utils.ReadJSON(r, &req)
 	if err != nil {
-		uh.logger.Println("ERROR: Decoding Create User: %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Invalid request body"})
+		writeError(uh.logger, w, r, "Decoding Create User", err)
 		return
 	}
 
-	err = uh.validateRegisterUserReq(&req)
+	err = validator.Validate(&req)
 	if err != nil {
-		uh.logger.Println("ERROR: Validating Register User: %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Invalid request body"})
+		writeError(uh.logger, w, r, "Validating Register User", err)
 		return
 	}
 
@@ -78,15 +64,13 @@ func (uh *UserHandler) HandleCreateUser(w http.ResponseWriter, r *http.Request)
 
 	err = user.PasswordHash.Set(req.Password)
 	if err != nil {
-		uh.logger.Println("ERROR: Hashing Password: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		writeError(uh.logger, w, r, "Hashing Password", err)
 		return
 	}
 
-	err = uh.userStore.CreateUser(&user)
+	err = uh.userStore.CreateUser(r.Context(), &user)
 	if err != nil {
-		uh.logger.Println("ERROR: Creating User: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+		writeError(uh.logger, w, r, "Creating User", err)
 		return
 	}
 
@@ -96,5 +80,48 @@ func (uh *UserHandler) HandleCreateUser(w http.ResponseWriter, r *http.Request)
 func (uh *UserHandler) HandleGetUserByUsername(w http.ResponseWriter, r *http.Request) {
 }
 
+// HandleGetCurrentUser returns the user the request is authenticated as.
+func (uh *UserHandler) HandleGetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": middleware.GetUser(r)})
+}
+
+// HandleUpdateUser updates the profile of the current user. Fields left out
+// keep their value. body_weight_kg is a shortcut for today's weigh-in, see
+// /users/me/measurements.
 func (uh *UserHandler) HandleUpdateUser(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Bio          *string  `json:"bio"`
+		BodyWeightKg *float64 `json:"body_weight_kg"`
+	}
+
+	err := utils.ReadJSON(r, &req)
+	if err != nil {
+		writeError(uh.logger, w, r, "Decoding Update User", err)
+		return
+	}
+
+	user := *middleware.GetUser(r)
+	if req.Bio != nil {
+		user.Bio = *req.Bio
+	}
+	if req.BodyWeightKg != nil {
+		user.BodyWeightKg = req.BodyWeightKg
+	}
+
+	v := validator.New()
+	if user.BodyWeightKg != nil {
+		v.Range("body_weight_kg", *user.BodyWeightKg, validator.MinBodyWeight, validator.MaxBodyWeight)
+	}
+	if err = v.Err(); err != nil {
+		writeError(uh.logger, w, r, "Validating Update User", err)
+		return
+	}
+
+	err = uh.userStore.UpdateProfile(r.Context(), &user)
+	if err != nil {
+		writeError(uh.logger, w, r, "UpdateProfile", err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"user": user})
 }
@@ -1,23 +1,23 @@
 package api
 
 import (
-	"database/sql"
-	"encoding/json"
-	"errors"
-	"fmt"
+	"github.com/oki-irawan/fem_project/internal/metrics"
 	"github.com/oki-irawan/fem_project/internal/middleware"
+	"github.com/oki-irawan/fem_project/internal/records"
 	"github.com/oki-irawan/fem_project/internal/store"
 	"github.com/oki-irawan/fem_project/internal/utils"
-	"log"
+	"github.com/oki-irawan/fem_project/internal/validator"
+	"log/slog"
 	"net/http"
+	"time"
 )
 
 type WorkoutHandler struct {
 	workoutStore store.WorkoutStore
-	logger       *log.Logger
+	logger       *slog.Logger
 }
 
-func NewWorkoutHandler(workoutStore store.WorkoutStore, logger *log.Logger) *WorkoutHandler {
+func NewWorkoutHandler(workoutStore store.WorkoutStore, logger *slog.Logger) *WorkoutHandler {
 	return &WorkoutHandler{
 		workoutStore: workoutStore,
 		logger:       logger,
@@ -27,15 +27,30 @@ func NewWorkoutHandler(workoutStore store.WorkoutStore, logger *log.Logger) *Wor
 func (wh *WorkoutHandler) HandleGetWorkoutById(w http.ResponseWriter, r *http.Request) {
 	workoutId, err := utils.ReadIdParameter(r)
 	if err != nil {
-		wh.logger.Println("ERROR: read id parameter %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		wh.logger.WarnContext(r.Context(), "read id parameter", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeInvalidID, "invalid workout id")
 		return
 	}
 
-	workout, err := wh.workoutStore.GetWorkoutByID(workoutId)
+	// checked first, so not even the version of another user's workout
+	// leaks through the ETag
+	err = wh.checkOwner(r, workoutId, middleware.GetUser(r))
 	if err != nil {
-		wh.logger.Println("ERROR: Failed to fetch the workout %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		writeError(wh.logger, w, r, "GetWorkoutOwner", err)
+		return
+	}
+
+	workout, err := wh.workoutStore.GetWorkoutByID(r.Context(), workoutId)
+	if err != nil {
+		writeError(wh.logger, w, r, "Failed to fetch the workout", err)
+		return
+	}
+
+	etag := utils.ETag(workout.Version)
+	w.Header().Set("ETag", etag)
+
+	if match := r.Header.Get("If-None-Match"); match != "" && utils.MatchETag(match, etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
@@ -45,28 +60,39 @@ func (wh *WorkoutHandler) HandleGetWorkoutById(w http.ResponseWriter, r *http.Re
 func (wh *WorkoutHandler) HandleCreateWorkout(w http.ResponseWriter, r *http.Request) {
 	var workout store.Workout
 
-	err := json.NewDecoder(r.Body).Decode(&workout)
+	err := utils.ReadJSON(r, &workout)
 	if err != nil {
-		wh.logger.Println("ERROR: Decoding Create Workout: %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Invalid request body"})
+		writeError(wh.logger, w, r, "Decoding Create Workout", err)
 		return
 	}
 
 	currentUser := middleware.GetUser(r)
 	if currentUser == nil || currentUser == store.AnonymousUser {
-		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "You must be logged in to create a workout"})
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "You must be logged in to create a workout")
+		return
+	}
+
+	v := validator.New()
+	validator.Workout(v, &workout)
+	if err = v.Err(); err != nil {
+		writeError(wh.logger, w, r, "Validating Create Workout", err)
 		return
 	}
 
 	workout.UserID = currentUser.ID
 
-	createdWorkout, err := wh.workoutStore.CreateWorkout(&workout)
+	previous := records.Previous(r.Context(), wh.logger, wh.workoutStore, &workout)
+
+	createdWorkout, err := wh.workoutStore.CreateWorkout(r.Context(), &workout)
 	if err != nil {
-		wh.logger.Println("ERROR: Creating Workout: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Failed to create workout"})
+		writeError(wh.logger, w, r, "Creating Workout", err)
 		return
 	}
 
+	metrics.WorkoutsCreated.Inc()
+	records.Record(previous, createdWorkout)
+
+	w.Header().Set("ETag", utils.ETag(createdWorkout.Version))
 	utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"workout": createdWorkout})
 
 }
@@ -74,37 +100,52 @@ func (wh *WorkoutHandler) HandleCreateWorkout(w http.ResponseWriter, r *http.Req
 func (wh *WorkoutHandler) HandleUpdateWorkoutById(w http.ResponseWriter, r *http.Request) {
 	workoutId, err := utils.ReadIdParameter(r)
 	if err != nil {
-		wh.logger.Println("ERROR: read id parameter %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		wh.logger.WarnContext(r.Context(), "read id parameter", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeInvalidID, "invalid workout id")
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "You must be logged in to update a workout")
 		return
 	}
 
-	existingWorkout, err := wh.workoutStore.GetWorkoutByID(workoutId)
+	// before If-Match, so another user's workout is forbidden rather than
+	// telling its version apart
+	err = wh.checkOwner(r, workoutId, currentUser)
 	if err != nil {
-		wh.logger.Println("ERROR: getWorkoutByID: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		writeError(wh.logger, w, r, "GetWorkoutOwner", err)
 		return
 	}
 
-	if existingWorkout == nil {
-		http.NotFound(w, r)
+	existingWorkout, err := wh.workoutStore.GetWorkoutByID(r.Context(), workoutId)
+	if err != nil {
+		writeError(wh.logger, w, r, "getWorkoutByID", err)
 		return
 	}
 
 	// At this stage, we assume that the existing workout exists
 
+	// without If-Match the update is still checked against the version read
+	// above, so a concurrent write in between is detected either way
+	if match := r.Header.Get("If-Match"); match != "" && !utils.MatchETag(match, utils.ETag(existingWorkout.Version)) {
+		writeError(wh.logger, w, r, "If-Match", store.ErrVersionMismatch)
+		return
+	}
+
 	var updatedWorkoutRequest struct {
 		Title           *string                `json:"title"`
 		Description     *string                `json:"description"`
 		DurationMinutes *int                   `json:"duration_minutes"`
 		CaloriesBurned  *int                   `json:"calories_burned"`
+		PerformedAt     *time.Time             `json:"performed_at"`
 		Entries         []store.WorkoutEntries `json:"entries"`
 	}
 
-	err = json.NewDecoder(r.Body).Decode(&updatedWorkoutRequest)
+	err = utils.ReadJSON(r, &updatedWorkoutRequest)
 	if err != nil {
-		wh.logger.Println("ERROR: Decoding Create Workout: %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "Invalid request body"})
+		writeError(wh.logger, w, r, "Decoding Create Workout", err)
 		return
 	}
 
@@ -120,38 +161,31 @@ func (wh *WorkoutHandler) HandleUpdateWorkoutById(w http.ResponseWriter, r *http
 	if updatedWorkoutRequest.CaloriesBurned != nil {
 		existingWorkout.CaloriesBurned = *updatedWorkoutRequest.CaloriesBurned
 	}
+	if updatedWorkoutRequest.PerformedAt != nil {
+		existingWorkout.PerformedAt = *updatedWorkoutRequest.PerformedAt
+	}
 	if updatedWorkoutRequest.Entries != nil {
 		existingWorkout.Entries = updatedWorkoutRequest.Entries
 	}
 
-	currentUser := middleware.GetUser(r)
-	if currentUser == nil || currentUser == store.AnonymousUser {
-		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "You must be logged in to update a workout"})
+	v := validator.New()
+	validator.Workout(v, existingWorkout)
+	if err = v.Err(); err != nil {
+		writeError(wh.logger, w, r, "Validating Update Workout", err)
 		return
 	}
 
-	workoutOwner, err := wh.workoutStore.GetWorkoutOwner(workoutId)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "Workout does not exist"})
-			return
-		}
+	previous := records.Previous(r.Context(), wh.logger, wh.workoutStore, existingWorkout)
 
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+	err = wh.workoutStore.UpdateWorkout(r.Context(), existingWorkout)
+	if err != nil {
+		writeError(wh.logger, w, r, "UpdateWorkout", err)
 		return
 	}
 
-	if workoutOwner != currentUser.ID {
-		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "You are not allowed to update this workout"})
-		return
-	}
+	records.Record(previous, existingWorkout)
 
-	err = wh.workoutStore.UpdateWorkout(existingWorkout)
-	if err != nil {
-		wh.logger.Println("ERROR: UpdateWorkout: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Failed to update workout"})
-		return
-	}
+	w.Header().Set("ETag", utils.ETag(existingWorkout.Version))
 
 	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": existingWorkout})
 }
@@ -159,46 +193,85 @@ func (wh *WorkoutHandler) HandleUpdateWorkoutById(w http.ResponseWriter, r *http
 func (wh *WorkoutHandler) HandlerDeleteWorkoutById(w http.ResponseWriter, r *http.Request) {
 	workoutId, err := utils.ReadIdParameter(r)
 	if err != nil {
-		wh.logger.Println("ERROR: read id parameter %v", err)
-		utils.WriteJSON(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		wh.logger.WarnContext(r.Context(), "read id parameter", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeInvalidID, "invalid workout id")
 		return
 	}
 
 	currentUser := middleware.GetUser(r)
 	if currentUser == nil || currentUser == store.AnonymousUser {
-		utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "You must be logged in to update a workout"})
+		utils.WriteProblem(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "You must be logged in to delete a workout")
 		return
 	}
 
-	workoutOwner, err := wh.workoutStore.GetWorkoutOwner(workoutId)
+	err = wh.checkOwner(r, workoutId, currentUser)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			utils.WriteJSON(w, http.StatusNotFound, utils.Envelope{"error": "Workout does not exist"})
-			return
-		}
+		writeError(wh.logger, w, r, "GetWorkoutOwner", err)
+		return
+	}
 
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Internal server error"})
+	err = wh.workoutStore.DeleteWorkout(r.Context(), workoutId)
+	if err != nil {
+		writeError(wh.logger, w, r, "DeleteWorkout", err)
 		return
 	}
 
-	if workoutOwner != currentUser.ID {
-		utils.WriteJSON(w, http.StatusForbidden, utils.Envelope{"error": "You are not allowed to delete this workout"})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (wh *WorkoutHandler) HandleListTrash(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	workouts, err := wh.workoutStore.ListTrash(r.Context(), currentUser.ID)
+	if err != nil {
+		writeError(wh.logger, w, r, "ListTrash", err)
 		return
 	}
 
-	err = wh.workoutStore.DeleteWorkout(workoutId)
+	if workouts == nil {
+		workouts = []*store.Workout{}
+	}
 
-	if errors.Is(err, sql.ErrNoRows) {
-		fmt.Println(err)
-		http.Error(w, "Workout not found", http.StatusNotFound)
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workouts": workouts})
+}
+
+func (wh *WorkoutHandler) HandleRestoreWorkout(w http.ResponseWriter, r *http.Request) {
+	workoutId, err := utils.ReadIdParameter(r)
+	if err != nil {
+		wh.logger.WarnContext(r.Context(), "read id parameter", "error", err)
+		utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeInvalidID, "invalid workout id")
 		return
 	}
 
+	currentUser := middleware.GetUser(r)
+
+	// only the owner's trash is searched, so someone else's workout is
+	// simply not found
+	workout, err := wh.workoutStore.RestoreWorkout(r.Context(), currentUser.ID, workoutId)
 	if err != nil {
-		wh.logger.Println("ERROR: DeleteWorkout: %v", err)
-		utils.WriteJSON(w, http.StatusInternalServerError, utils.Envelope{"error": "Failed to delete the workout"})
+		writeError(wh.logger, w, r, "RestoreWorkout", err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("ETag", utils.ETag(workout.Version))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": workout})
+}
+
+// checkOwner returns store.ErrForbidden when the workout belongs to another
+// user.
+func (wh *WorkoutHandler) checkOwner(r *http.Request, workoutId int64, user *store.User) error {
+	return checkWorkoutOwner(r, wh.workoutStore, workoutId, user)
+}
+
+func checkWorkoutOwner(r *http.Request, workoutStore store.WorkoutStore, workoutId int64, user *store.User) error {
+	workoutOwner, err := workoutStore.GetWorkoutOwner(r.Context(), workoutId)
+	if err != nil {
+		return err
+	}
+
+	if workoutOwner != user.ID {
+		return store.ErrForbidden
+	}
+
+	return nil
 }
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"github.com/oki-irawan/fem_project/internal/metrics"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUpdateWorkoutIfMatch(t *testing.T) {
+	user := &store.User{ID: 1}
+
+	edits := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		handle      func(wh *WorkoutHandler) http.HandlerFunc
+	}{
+		{
+			name:        "PUT",
+			method:      http.MethodPut,
+			contentType: "application/json",
+			body:        `{"title":"Push Day Heavy"}`,
+			handle:      func(wh *WorkoutHandler) http.HandlerFunc { return wh.HandleUpdateWorkoutById },
+		},
+		{
+			name:        "PATCH",
+			method:      http.MethodPatch,
+			contentType: mergePatchContentType,
+			body:        `{"title":"Push Day Heavy"}`,
+			handle:      func(wh *WorkoutHandler) http.HandlerFunc { return wh.HandlePatchWorkoutById },
+		},
+	}
+
+	test := []struct {
+		name       string
+		ifMatch    string
+		updateErr  error
+		wantStatus int
+		wantETag   string
+	}{
+		{name: "Matching ETag", ifMatch: `"2"`, wantStatus: http.StatusOK, wantETag: `"3"`},
+		{name: "Weak ETag", ifMatch: `W/"2"`, wantStatus: http.StatusOK, wantETag: `"3"`},
+		{name: "One Of Several", ifMatch: `"1", "2"`, wantStatus: http.StatusOK, wantETag: `"3"`},
+		{name: "Any", ifMatch: "*", wantStatus: http.StatusOK, wantETag: `"3"`},
+		{name: "Stale ETag", ifMatch: `"1"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "Missing", wantStatus: http.StatusOK, wantETag: `"3"`},
+		// the version read by the handler still guards the update
+		{name: "Missing And Lost A Race", updateErr: store.ErrVersionMismatch, wantStatus: http.StatusPreconditionFailed},
+	}
+
+	for _, edit := range edits {
+		for _, tt := range test {
+			t.Run(edit.name+" "+tt.name, func(t *testing.T) {
+				workouts := newFakeWorkoutStore(&store.Workout{UUID: pushDayUUID, UserID: 1, Title: "Push Day", DurationMinutes: 60, Version: 2})
+				workouts.updateErr = tt.updateErr
+				wh := NewWorkoutHandler(workouts, discardLogger())
+
+				r := newUserRequest(edit.method, "/workouts/1", edit.body, user)
+				r.Header.Set("Content-Type", edit.contentType)
+				if tt.ifMatch != "" {
+					r.Header.Set("If-Match", tt.ifMatch)
+				}
+
+				w := httptest.NewRecorder()
+				edit.handle(wh)(w, withURLParam(r, "id", "1"))
+				require.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+
+				saved := workouts.workouts[1]
+				if tt.wantStatus != http.StatusOK {
+					assert.Equal(t, "precondition_failed", problemCode(t, w))
+					assert.Equal(t, "Push Day", saved.Title)
+					assert.Equal(t, 2, saved.Version)
+					return
+				}
+
+				assert.Equal(t, tt.wantETag, w.Header().Get("ETag"))
+				assert.Equal(t, "Push Day Heavy", saved.Title)
+				assert.Equal(t, 3, saved.Version)
+			})
+		}
+	}
+}
+
+func TestCreateWorkoutPersonalRecords(t *testing.T) {
+	reps, weight := 5, 100.0
+	workouts := newFakeWorkoutStore(&store.Workout{
+		UUID: pushDayUUID, UserID: 1, Title: "Leg Day", DurationMinutes: 60,
+		Entries: []store.WorkoutEntries{{ExerciseName: "Squat", Sets: 3, Reps: &reps, Weight: &weight}},
+	})
+	wh := NewWorkoutHandler(workouts, discardLogger())
+
+	test := []struct {
+		name  string
+		body  string
+		wantN float64
+	}{
+		{
+			name:  "Heavier Than Before",
+			body:  `{"title":"Leg Day Heavy","duration_minutes":60,"entries":[{"exercise_name":"squat","sets":3,"reps":5,"weight":105}]}`,
+			wantN: 1,
+		},
+		{
+			name: "Matching And First Lifts",
+			body: `{"title":"Leg Day Light","duration_minutes":60,"entries":[{"exercise_name":"Squat","sets":3,"reps":5,"weight":105},{"exercise_name":"Deadlift","sets":3,"reps":5,"weight":140}]}`,
+		},
+	}
+
+	for _, tt := range test {
+		t.Run(tt.name, func(t *testing.T) {
+			before := testutil.ToFloat64(metrics.PersonalRecords)
+
+			w := httptest.NewRecorder()
+			wh.HandleCreateWorkout(w, newUserRequest(http.MethodPost, "/workouts", tt.body, &store.User{ID: 1}))
+			require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+			assert.Equal(t, tt.wantN, testutil.ToFloat64(metrics.PersonalRecords)-before)
+		})
+	}
+}
+
+func TestWorkoutOfAnotherUser(t *testing.T) {
+	other := &store.User{ID: 2}
+
+	test := []struct {
+		name   string
+		method string
+		header string
+		value  string
+		handle func(wh *WorkoutHandler) http.HandlerFunc
+	}{
+		{
+			name:   "GET Matching ETag",
+			method: http.MethodGet,
+			header: "If-None-Match",
+			value:  `"2"`,
+			handle: func(wh *WorkoutHandler) http.HandlerFunc { return wh.HandleGetWorkoutById },
+		},
+		{
+			name:   "PUT Stale ETag",
+			method: http.MethodPut,
+			header: "If-Match",
+			value:  `"1"`,
+			handle: func(wh *WorkoutHandler) http.HandlerFunc { return wh.HandleUpdateWorkoutById },
+		},
+		{
+			name:   "PATCH Stale ETag",
+			method: http.MethodPatch,
+			header: "If-Match",
+			value:  `"1"`,
+			handle: func(wh *WorkoutHandler) http.HandlerFunc { return wh.HandlePatchWorkoutById },
+		},
+	}
+
+	for _, tt := range test {
+		t.Run(tt.name, func(t *testing.T) {
+			workouts := newFakeWorkoutStore(&store.Workout{UUID: pushDayUUID, UserID: 1, Title: "Push Day", DurationMinutes: 60, Version: 2})
+			wh := NewWorkoutHandler(workouts, discardLogger())
+
+			r := newUserRequest(tt.method, "/workouts/1", `{"title":"Push Day Heavy"}`, other)
+			r.Header.Set("Content-Type", mergePatchContentType)
+			r.Header.Set(tt.header, tt.value)
+
+			w := httptest.NewRecorder()
+			tt.handle(wh)(w, withURLParam(r, "id", "1"))
+
+			// forbidden before any precondition, so the version stays hidden
+			require.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+			assert.Equal(t, "forbidden", problemCode(t, w))
+			assert.Empty(t, w.Header().Get("ETag"))
+			assert.Equal(t, "Push Day", workouts.workouts[1].Title)
+		})
+	}
+}
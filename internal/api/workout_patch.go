@@ -0,0 +1,135 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/oki-irawan/fem_project/internal/records"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/utils"
+	"github.com/oki-irawan/fem_project/internal/validator"
+	"io"
+	"mime"
+	"net/http"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// errPatchFailed is returned when a well formed patch cannot be applied,
+// e.g. a "test" operation fails or a path does not exist.
+var errPatchFailed = errors.New("patch could not be applied")
+
+// applyWorkoutPatch applies a merge patch (RFC 7396) or JSON patch
+// (RFC 6902) to the JSON form of workout and returns the patched copy.
+// Paths are the JSON field names, e.g. /entries/0/weight. Members the
+// workout does not have are rejected rather than dropped.
+func applyWorkoutPatch(workout *store.Workout, contentType string, patch []byte) (*store.Workout, error) {
+	doc, err := json.Marshal(workout)
+	if err != nil {
+		return nil, err
+	}
+
+	switch contentType {
+	case mergePatchContentType:
+		doc, err = jsonpatch.MergePatch(doc, patch)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", utils.ErrInvalidRequestBody, err)
+		}
+	case jsonPatchContentType:
+		ops, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", utils.ErrInvalidRequestBody, err)
+		}
+
+		doc, err = ops.Apply(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errPatchFailed, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported patch content type %q", contentType)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+
+	patched := &store.Workout{}
+	err = dec.Decode(patched)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", utils.ErrInvalidRequestBody, err)
+	}
+
+	return patched, nil
+}
+
+// validatePatchedWorkout checks that a patch left the server managed fields
+// alone, on top of the usual workout rules.
+func validatePatchedWorkout(v *validator.Validator, existing, patched *store.Workout) {
+	v.Check(patched.ID == existing.ID, "id", "read_only", "id cannot be changed")
+	v.Check(patched.UUID == existing.UUID, "uuid", "read_only", "uuid cannot be changed")
+	v.Check(patched.UserID == existing.UserID, "user_id", "read_only", "user_id cannot be changed")
+	v.Check(patched.Version == existing.Version, "version", "read_only", "version cannot be changed")
+
+	validator.Workout(v, patched)
+}
+
+// HandlePatchWorkoutById updates a workout with a merge patch or a JSON
+// patch, depending on the Content-Type. Entries keep their IDs where the
+// patch keeps them, like with PUT.
+func (wh *WorkoutHandler) HandlePatchWorkoutById(w http.ResponseWriter, r *http.Request) {
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (contentType != mergePatchContentType && contentType != jsonPatchContentType) {
+		w.Header().Set("Accept-Patch", mergePatchContentType+", "+jsonPatchContentType)
+		utils.WriteProblem(w, r, http.StatusUnsupportedMediaType, utils.CodeUnsupportedMediaType,
+			"PATCH requires Content-Type "+mergePatchContentType+" or "+jsonPatchContentType)
+		return
+	}
+
+	workout, ok := wh.loadWorkoutForEdit(w, r)
+	if !ok {
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(wh.logger, w, r, "Reading Patch", err)
+		return
+	}
+
+	patched, err := applyWorkoutPatch(workout, contentType, patch)
+	if errors.Is(err, errPatchFailed) {
+		wh.logger.WarnContext(r.Context(), "Applying Patch", "error", err)
+		utils.WriteProblem(w, r, http.StatusUnprocessableEntity, utils.CodePatchFailed, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(wh.logger, w, r, "Applying Patch", err)
+		return
+	}
+
+	v := validator.New()
+	validatePatchedWorkout(v, workout, patched)
+	if err = v.Err(); err != nil {
+		writeError(wh.logger, w, r, "Validating Patched Workout", err)
+		return
+	}
+
+	patched.DeletedAt = nil
+
+	previous := records.Previous(r.Context(), wh.logger, wh.workoutStore, patched)
+
+	err = wh.workoutStore.UpdateWorkout(r.Context(), patched)
+	if err != nil {
+		writeError(wh.logger, w, r, "UpdateWorkout", err)
+		return
+	}
+
+	records.Record(previous, patched)
+
+	w.Header().Set("ETag", utils.ETag(patched.Version))
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"workout": patched})
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"errors"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/utils"
+	"github.com/oki-irawan/fem_project/internal/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func patchTestWorkout() *store.Workout {
+	reps, weight := 5, 100.0
+	return &store.Workout{
+		ID: 1, UUID: pushDayUUID, UserID: 1, Title: "Leg Day", DurationMinutes: 60, Version: 2,
+		Entries: []store.WorkoutEntries{
+			{ID: 10, ExerciseName: "Squat", Sets: 3, Reps: &reps, Weight: &weight},
+			{ID: 11, ExerciseName: "Lunge", Sets: 3, Reps: &reps, OrderIndex: 1},
+		},
+	}
+}
+
+func TestApplyWorkoutPatch(t *testing.T) {
+	test := []struct {
+		name        string
+		contentType string
+		patch       string
+		wantErr     error
+		check       func(t *testing.T, patched *store.Workout)
+	}{
+		{
+			name:        "Merge Patch",
+			contentType: mergePatchContentType,
+			patch:       `{"title":"Leg Day Heavy","description":"Deload week"}`,
+			check: func(t *testing.T, patched *store.Workout) {
+				assert.Equal(t, "Leg Day Heavy", patched.Title)
+				assert.Equal(t, "Deload week", patched.Description)
+				assert.Equal(t, 60, patched.DurationMinutes)
+				assert.Len(t, patched.Entries, 2)
+			},
+		},
+		{
+			name:        "Merge Patch Replaces Entries",
+			contentType: mergePatchContentType,
+			patch:       `{"entries":[{"id":11,"exercise_name":"Lunge","sets":4,"reps":8}]}`,
+			check: func(t *testing.T, patched *store.Workout) {
+				require.Len(t, patched.Entries, 1)
+				assert.Equal(t, 11, patched.Entries[0].ID)
+				assert.Equal(t, 4, patched.Entries[0].Sets)
+			},
+		},
+		{
+			name:        "Merge Patch Null Removes A Field",
+			contentType: mergePatchContentType,
+			patch:       `{"title":null}`,
+			check: func(t *testing.T, patched *store.Workout) {
+				assert.Empty(t, patched.Title)
+			},
+		},
+		{
+			name:        "Merge Patch Unknown Field",
+			contentType: mergePatchContentType,
+			patch:       `{"titel":"Leg Day Heavy"}`,
+			wantErr:     utils.ErrInvalidRequestBody,
+		},
+		{
+			name:        "Merge Patch Unknown Entry Field",
+			contentType: mergePatchContentType,
+			patch:       `{"entries":[{"exercise_name":"Squat","sets":3,"reps":5,"rpe":8}]}`,
+			wantErr:     utils.ErrInvalidRequestBody,
+		},
+		{
+			name:        "Merge Patch Wrong Type",
+			contentType: mergePatchContentType,
+			patch:       `{"duration_minutes":"an hour"}`,
+			wantErr:     utils.ErrInvalidRequestBody,
+		},
+		{
+			name:        "Merge Patch Malformed",
+			contentType: mergePatchContentType,
+			patch:       `{"title":`,
+			wantErr:     utils.ErrInvalidRequestBody,
+		},
+		{
+			name:        "JSON Patch",
+			contentType: jsonPatchContentType,
+			patch:       `[{"op":"test","path":"/version","value":2},{"op":"replace","path":"/entries/0/weight","value":110},{"op":"remove","path":"/entries/1"}]`,
+			check: func(t *testing.T, patched *store.Workout) {
+				require.Len(t, patched.Entries, 1)
+				assert.Equal(t, 10, patched.Entries[0].ID)
+				assert.Equal(t, 110.0, *patched.Entries[0].Weight)
+			},
+		},
+		{
+			name:        "JSON Patch Adds An Entry",
+			contentType: jsonPatchContentType,
+			patch:       `[{"op":"add","path":"/entries/-","value":{"exercise_name":"Calf Raise","sets":3,"reps":12,"order_index":2}}]`,
+			check: func(t *testing.T, patched *store.Workout) {
+				require.Len(t, patched.Entries, 3)
+				assert.Equal(t, 0, patched.Entries[2].ID)
+				assert.Equal(t, "Calf Raise", patched.Entries[2].ExerciseName)
+			},
+		},
+		{
+			name:        "JSON Patch Unknown Field",
+			contentType: jsonPatchContentType,
+			patch:       `[{"op":"add","path":"/mood","value":"tired"}]`,
+			wantErr:     utils.ErrInvalidRequestBody,
+		},
+		{
+			name:        "JSON Patch Failed Test",
+			contentType: jsonPatchContentType,
+			patch:       `[{"op":"test","path":"/version","value":1},{"op":"replace","path":"/title","value":"Leg Day Heavy"}]`,
+			wantErr:     errPatchFailed,
+		},
+		{
+			name:        "JSON Patch Missing Path",
+			contentType: jsonPatchContentType,
+			patch:       `[{"op":"replace","path":"/entries/5/weight","value":110}]`,
+			wantErr:     errPatchFailed,
+		},
+		{
+			name:        "JSON Patch Malformed",
+			contentType: jsonPatchContentType,
+			patch:       `{"op":"replace"}`,
+			wantErr:     utils.ErrInvalidRequestBody,
+		},
+	}
+
+	for _, tt := range test {
+		t.Run(tt.name, func(t *testing.T) {
+			workout := patchTestWorkout()
+
+			patched, err := applyWorkoutPatch(workout, tt.contentType, []byte(tt.patch))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			tt.check(t, patched)
+			// the workout the patch was applied to is left alone
+			assert.Equal(t, patchTestWorkout(), workout)
+		})
+	}
+}
+
+func TestValidatePatchedWorkout(t *testing.T) {
+	test := []struct {
+		name       string
+		patch      func(w *store.Workout)
+		wantFields []string
+	}{
+		{name: "Valid", patch: func(w *store.Workout) { w.Title = "Leg Day Heavy" }},
+		{
+			name: "Read Only Fields",
+			patch: func(w *store.Workout) {
+				w.ID, w.UUID, w.UserID, w.Version = 2, legDayUUID, 2, 3
+			},
+			wantFields: []string{"id", "uuid", "user_id", "version"},
+		},
+		{
+			name: "Workout Rules",
+			patch: func(w *store.Workout) {
+				w.Title = ""
+				w.Entries[1].Reps = nil
+			},
+			wantFields: []string{"title", "entries[1].reps"},
+		},
+	}
+
+	for _, tt := range test {
+		t.Run(tt.name, func(t *testing.T) {
+			patched := patchTestWorkout()
+			tt.patch(patched)
+
+			v := validator.New()
+			validatePatchedWorkout(v, patchTestWorkout(), patched)
+
+			err := v.Err()
+			if len(tt.wantFields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			var validationErr *store.ValidationError
+			require.True(t, errors.As(err, &validationErr))
+			fields := make([]string, 0, len(validationErr.Fields))
+			for _, f := range validationErr.Fields {
+				fields = append(fields, f.Field)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+		})
+	}
+}
+
+func TestHandlePatchWorkoutUnknownField(t *testing.T) {
+	workouts := newFakeWorkoutStore(patchTestWorkout())
+	wh := NewWorkoutHandler(workouts, discardLogger())
+
+	r := newUserRequest(http.MethodPatch, "/workouts/1", `{"titel":"Leg Day Heavy"}`, &store.User{ID: 1})
+	r.Header.Set("Content-Type", mergePatchContentType)
+
+	w := httptest.NewRecorder()
+	wh.HandlePatchWorkoutById(w, withURLParam(r, "id", "1"))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "invalid_request_body", problemCode(t, w))
+	assert.Equal(t, 2, workouts.workouts[1].Version)
+}
@@ -1,28 +1,56 @@
 package app
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/oki-irawan/fem_project/internal/api"
+	"github.com/oki-irawan/fem_project/internal/catalog"
+	"github.com/oki-irawan/fem_project/internal/events"
+	"github.com/oki-irawan/fem_project/internal/goals"
+	"github.com/oki-irawan/fem_project/internal/health"
+	"github.com/oki-irawan/fem_project/internal/imports"
+	"github.com/oki-irawan/fem_project/internal/logging"
+	"github.com/oki-irawan/fem_project/internal/metrics"
 	"github.com/oki-irawan/fem_project/internal/middleware"
+	"github.com/oki-irawan/fem_project/internal/ratelimit"
 	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/tracing"
+	"github.com/oki-irawan/fem_project/internal/training"
 	"github.com/oki-irawan/fem_project/migrations"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
+	"time"
 )
 
 type Application struct {
-	Logger         *log.Logger
-	WorkoutHandler *api.WorkoutHandler
-	UserHandler    *api.UserHandler
-	TokenHandler   *api.TokenHandler
-	Middleware     middleware.UserMiddleware
-	DB             *sql.DB
+	Config             Config
+	Logger             *slog.Logger
+	WorkoutHandler     *api.WorkoutHandler
+	UserHandler        *api.UserHandler
+	TokenHandler       *api.TokenHandler
+	SyncHandler        *api.SyncHandler
+	RevisionHandler    *api.RevisionHandler
+	ImportHandler      *api.ImportHandler
+	ActivityHandler    *api.ActivityHandler
+	AnalyticsHandler   *api.AnalyticsHandler
+	TrainingHandler    *api.TrainingHandler
+	MeasurementHandler *api.MeasurementHandler
+	GoalHandler        *api.GoalHandler
+	Middleware         middleware.UserMiddleware
+	Idempotency        *middleware.IdempotencyMiddleware
+	Lifecycle          *Lifecycle
+	Health             *health.Registry
+	RateLimiter        ratelimit.Limiter
+	DB                 *sql.DB
+	// Events is where notifications subscribe to domain events such as
+	// goals.GoalAchieved.
+	Events *events.Bus
 }
 
-func NewApplication() (*Application, error) {
-	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+func NewApplication(cfg Config) (*Application, error) {
+	logger := logging.New(os.Stdout, cfg.LogLevel)
 
 	pgDB, err := store.Open()
 	if err != nil {
@@ -34,30 +62,135 @@ func NewApplication() (*Application, error) {
 		panic(err)
 	}
 
+	metrics.RegisterDB(pgDB, "postgres")
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TraceExporter, "workout-api")
+	if err != nil {
+		return nil, err
+	}
+
 	//store
-	workoutStore := store.NewPostgresWorkoutStore(pgDB)
-	userStore := store.NewPostgresUserStore(pgDB)
-	tokenStore := store.NewPostgresTokenStore(pgDB)
+	workoutStore := store.NewPostgresWorkoutStore(pgDB, cfg.QueryTimeouts)
+	userStore := store.NewPostgresUserStore(pgDB, cfg.QueryTimeouts)
+	tokenStore := store.NewPostgresTokenStore(pgDB, cfg.QueryTimeouts)
+	idempotencyStore := store.NewPostgresIdempotencyStore(pgDB, cfg.QueryTimeouts)
+	syncStore := store.NewPostgresSyncStore(pgDB, cfg.QueryTimeouts)
+	revisionStore := store.NewPostgresRevisionStore(pgDB, cfg.QueryTimeouts)
+	importStore := store.NewPostgresImportStore(pgDB, cfg.QueryTimeouts)
+	trackStore := store.NewPostgresTrackStore(pgDB, cfg.QueryTimeouts)
+	analyticsStore := store.NewPostgresAnalyticsStore(pgDB, cfg.QueryTimeouts)
+	trainingStore := store.NewPostgresTrainingStore(pgDB, cfg.QueryTimeouts)
+	measurementStore := store.NewPostgresMeasurementStore(pgDB, cfg.QueryTimeouts)
+	goalStore := store.NewPostgresGoalStore(pgDB, cfg.QueryTimeouts)
 
 	//api
 	workoutHandler := api.NewWorkoutHandler(workoutStore, logger)
 	userHandler := api.NewUserHandler(userStore, logger)
 	tokenHandler := api.NewTokenHandler(tokenStore, userStore, logger)
+	syncHandler := api.NewSyncHandler(syncStore, workoutStore, logger)
+	revisionHandler := api.NewRevisionHandler(revisionStore, workoutStore, logger)
+	importHandler := api.NewImportHandler(importStore, logger)
+	activityHandler := api.NewActivityHandler(trackStore, workoutStore, logger)
+	analyticsHandler := api.NewAnalyticsHandler(analyticsStore, logger)
+	trainingHandler := api.NewTrainingHandler(trainingStore, workoutStore, logger)
+	measurementHandler := api.NewMeasurementHandler(measurementStore, logger)
+	goalHandler := api.NewGoalHandler(goalStore, logger)
 
 	middlewareHandler := middleware.UserMiddleware{UserStore: userStore}
+	// the lease outlasts the request timeout, so only a request that died
+	// without releasing its key loses it to a retry
+	idempotencyMiddleware := &middleware.IdempotencyMiddleware{
+		Store:  idempotencyStore,
+		TTL:    cfg.IdempotencyTTL,
+		Lease:  cfg.RequestTimeout + 5*time.Second,
+		Logger: logger,
+	}
+
+	lifecycle := NewLifecycle()
+	lifecycle.Register(NewHook("tracing", nil, shutdownTracing))
+	healthRegistry := health.NewRegistry(cfg.HealthCheckTimeout)
+	registerHealthChecks(healthRegistry, pgDB, migrations.FS, lifecycle)
+
+	lifecycle.Register(NewPeriodicJob("idempotency-purge", 10*time.Minute, func(ctx context.Context) error {
+		_, err := idempotencyStore.DeleteExpired(ctx)
+		return err
+	}, logger))
+
+	lifecycle.Register(NewPeriodicJob("trash-purge", time.Hour, func(ctx context.Context) error {
+		_, err := workoutStore.PurgeTrash(ctx, time.Now().Add(-cfg.TrashRetention))
+		return err
+	}, logger))
+
+	lifecycle.Register(NewPeriodicJob("tombstone-purge", time.Hour, func(ctx context.Context) error {
+		_, err := syncStore.PruneTombstones(ctx, time.Now().Add(-cfg.TombstoneRetention))
+		return err
+	}, logger))
+
+	importer := imports.NewImporter(importStore, workoutStore, catalog.Default(), logger)
+	lifecycle.Register(NewPeriodicJob("import-worker", 5*time.Second, importer.RunPending, logger))
+
+	calculator := training.NewCalculator(trainingStore, workoutStore, trackStore, logger)
+	lifecycle.Register(NewPeriodicJob("training-load", time.Minute, calculator.RunPending, logger))
+
+	eventBus := events.NewBus(logger)
+	tracker := goals.NewTracker(goalStore, eventBus, logger)
+	lifecycle.Register(NewPeriodicJob("goal-tracker", time.Minute, tracker.RunPending, logger))
+
+	rateLimiter, err := newRateLimiter(cfg.RateLimit, pgDB, lifecycle, logger)
+	if err != nil {
+		return nil, err
+	}
 
 	app := &Application{
-		Logger:         logger,
-		WorkoutHandler: workoutHandler,
-		UserHandler:    userHandler,
-		TokenHandler:   tokenHandler,
-		Middleware:     middlewareHandler,
-		DB:             pgDB,
+		Config:             cfg,
+		Logger:             logger,
+		WorkoutHandler:     workoutHandler,
+		UserHandler:        userHandler,
+		TokenHandler:       tokenHandler,
+		SyncHandler:        syncHandler,
+		RevisionHandler:    revisionHandler,
+		ImportHandler:      importHandler,
+		ActivityHandler:    activityHandler,
+		AnalyticsHandler:   analyticsHandler,
+		TrainingHandler:    trainingHandler,
+		MeasurementHandler: measurementHandler,
+		GoalHandler:        goalHandler,
+		Middleware:         middlewareHandler,
+		Idempotency:        idempotencyMiddleware,
+		Lifecycle:          lifecycle,
+		Events:             eventBus,
+		Health:             healthRegistry,
+		RateLimiter:        rateLimiter,
+		DB:                 pgDB,
 	}
 
 	return app, nil
 }
 
-func (a *Application) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, "Status is availaible\n")
+func newRateLimiter(cfg RateLimitConfig, db *sql.DB, lifecycle *Lifecycle, logger *slog.Logger) (ratelimit.Limiter, error) {
+	switch cfg.Backend {
+	case "memory":
+		return ratelimit.NewMemoryLimiter(), nil
+	case "postgres":
+		limiter := ratelimit.NewPostgresLimiter(db)
+		lifecycle.Register(NewPeriodicJob("rate-limit-purge", 10*time.Minute, func(ctx context.Context) error {
+			return limiter.Purge(ctx, time.Hour)
+		}, logger))
+		return limiter, nil
+	}
+
+	return nil, fmt.Errorf("unknown rate limit backend %q", cfg.Backend)
+}
+
+// Start starts the registered background components.
+func (a *Application) Start(ctx context.Context) error {
+	return a.Lifecycle.Start(ctx)
+}
+
+// Shutdown stops the background components and closes the database last,
+// once nothing can use it anymore.
+func (a *Application) Shutdown(ctx context.Context) error {
+	err := a.Lifecycle.Stop(ctx)
+
+	return errors.Join(err, a.DB.Close())
 }
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"github.com/oki-irawan/fem_project/internal/middleware"
+	"github.com/oki-irawan/fem_project/internal/ratelimit"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"log/slog"
+	"time"
+)
+
+type Config struct {
+	Port            int
+	ShutdownTimeout time.Duration
+	DrainDelay      time.Duration
+	LogLevel        slog.Level
+
+	HealthCheckTimeout time.Duration
+
+	MetricsToken  string
+	TraceExporter string
+
+	// RequestTimeout is the deadline put on every request context. It should
+	// be below the server's WriteTimeout so queries are cancelled before the
+	// connection is cut.
+	RequestTimeout time.Duration
+	QueryTimeouts  store.QueryTimeouts
+
+	// TrustProxy makes the client IP come from X-Forwarded-For / X-Real-IP.
+	// Only enable it behind a proxy that overwrites those headers.
+	TrustProxy bool
+	RateLimit  RateLimitConfig
+
+	CORS         middleware.CORSConfig
+	HSTS         bool
+	MaxBodyBytes int64
+	// ImportMaxBytes replaces MaxBodyBytes for file uploads to /imports and
+	// /activities.
+	ImportMaxBytes int64
+
+	IdempotencyTTL time.Duration
+
+	// TrashRetention is how long deleted workouts can be restored before
+	// they are purged for good.
+	TrashRetention time.Duration
+	// TombstoneRetention is how long sync tombstones are kept. Clients that
+	// have not synced for longer have to sync from scratch.
+	TombstoneRetention time.Duration
+}
+
+type RateLimitConfig struct {
+	Enabled bool
+	// Backend is "memory" or "postgres".
+	Backend string
+	Auth    ratelimit.Policy
+	// IP limits each client IP on the authenticated routes, before the
+	// Read and Write limits per user. It should allow a few users behind
+	// one address.
+	IP    ratelimit.Policy
+	Read  ratelimit.Policy
+	Write ratelimit.Policy
+}
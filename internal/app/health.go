@@ -0,0 +1,87 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/health"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/utils"
+	"io/fs"
+	"net/http"
+)
+
+type poolStats struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+}
+
+func registerHealthChecks(registry *health.Registry, db *sql.DB, migrationFS fs.FS, lifecycle *Lifecycle) {
+	registry.Register("database", func(ctx context.Context) (any, error) {
+		stats := db.Stats()
+		details := poolStats{
+			MaxOpenConnections: stats.MaxOpenConnections,
+			OpenConnections:    stats.OpenConnections,
+			InUse:              stats.InUse,
+			Idle:               stats.Idle,
+			WaitCount:          stats.WaitCount,
+			WaitDuration:       stats.WaitDuration.String(),
+		}
+
+		return details, db.PingContext(ctx)
+	})
+
+	registry.Register("migrations", func(ctx context.Context) (any, error) {
+		current, target, err := store.MigrationVersions(ctx, db, migrationFS)
+		if err != nil {
+			return nil, err
+		}
+
+		details := map[string]int64{"current": current, "target": target}
+		if current < target {
+			return details, fmt.Errorf("database is at version %d, expected %d", current, target)
+		}
+
+		return details, nil
+	})
+
+	registry.Register("workers", func(ctx context.Context) (any, error) {
+		statuses := lifecycle.Statuses()
+		for _, status := range statuses {
+			if !status.Running {
+				return statuses, errors.New(status.Name + " is not running")
+			}
+		}
+
+		return statuses, nil
+	})
+}
+
+// HandleLiveness reports whether the process is able to serve requests at all.
+// It deliberately checks no dependencies so a database outage doesn't get the
+// pod restarted.
+func (a *Application) HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, utils.Envelope{"status": health.StatusUp})
+}
+
+// HandleReadiness reports whether the instance should receive traffic.
+func (a *Application) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	report := a.Health.Run(r.Context())
+
+	if !a.Lifecycle.Ready() {
+		report.Status = health.StatusDown
+		report.Checks["lifecycle"] = health.Result{Status: health.StatusDown, Error: "application is draining"}
+	}
+
+	status := http.StatusOK
+	if report.Status != health.StatusUp {
+		status = http.StatusServiceUnavailable
+	}
+
+	utils.WriteJSON(w, status, utils.Envelope{"status": report.Status, "checks": report.Checks})
+}
@@ -0,0 +1,205 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Component is a background part of the application (scheduler, hub, worker)
+// that is started after the database is ready and stopped before it is closed.
+type Component interface {
+	Name() string
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+type ComponentStatus struct {
+	Name    string `json:"name"`
+	Running bool   `json:"running"`
+}
+
+type Lifecycle struct {
+	mu         sync.Mutex
+	components []Component
+	running    map[string]bool
+	ready      atomic.Bool
+}
+
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{
+		running: make(map[string]bool),
+	}
+}
+
+func (l *Lifecycle) Register(c Component) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.components = append(l.components, c)
+}
+
+// Start starts the components in registration order. If one fails, the ones
+// already started are stopped again in reverse order.
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, c := range l.components {
+		err := c.Start(ctx)
+		if err != nil {
+			l.stopLocked(ctx, l.components[:i])
+			return fmt.Errorf("start %s: %w", c.Name(), err)
+		}
+		l.running[c.Name()] = true
+	}
+
+	l.ready.Store(true)
+	return nil
+}
+
+// Stop stops the components in reverse registration order.
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.ready.Store(false)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.stopLocked(ctx, l.components)
+}
+
+func (l *Lifecycle) stopLocked(ctx context.Context, components []Component) error {
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		if !l.running[c.Name()] {
+			continue
+		}
+
+		err := c.Stop(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stop %s: %w", c.Name(), err))
+		}
+		l.running[c.Name()] = false
+	}
+
+	return errors.Join(errs...)
+}
+
+func (l *Lifecycle) Ready() bool {
+	return l.ready.Load()
+}
+
+// Drain marks the application as not ready so that load balancers stop
+// routing new traffic while in-flight requests finish.
+func (l *Lifecycle) Drain() {
+	l.ready.Store(false)
+}
+
+func (l *Lifecycle) Statuses() []ComponentStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	statuses := make([]ComponentStatus, 0, len(l.components))
+	for _, c := range l.components {
+		statuses = append(statuses, ComponentStatus{Name: c.Name(), Running: l.running[c.Name()]})
+	}
+
+	return statuses
+}
+
+type hook struct {
+	name  string
+	start func(ctx context.Context) error
+	stop  func(ctx context.Context) error
+}
+
+// NewHook wraps a pair of functions as a Component. Either may be nil.
+func NewHook(name string, start, stop func(ctx context.Context) error) Component {
+	return &hook{name: name, start: start, stop: stop}
+}
+
+func (h *hook) Name() string {
+	return h.name
+}
+
+func (h *hook) Start(ctx context.Context) error {
+	if h.start == nil {
+		return nil
+	}
+	return h.start(ctx)
+}
+
+func (h *hook) Stop(ctx context.Context) error {
+	if h.stop == nil {
+		return nil
+	}
+	return h.stop(ctx)
+}
+
+// PeriodicJob runs fn every interval until it is stopped. Errors are logged
+// and the job keeps running.
+type PeriodicJob struct {
+	name     string
+	interval time.Duration
+	fn       func(ctx context.Context) error
+	logger   *slog.Logger
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewPeriodicJob(name string, interval time.Duration, fn func(ctx context.Context) error, logger *slog.Logger) *PeriodicJob {
+	return &PeriodicJob{
+		name:     name,
+		interval: interval,
+		fn:       fn,
+		logger:   logger,
+	}
+}
+
+func (j *PeriodicJob) Name() string {
+	return j.name
+}
+
+func (j *PeriodicJob) Start(ctx context.Context) error {
+	// the job outlives the start context, it only ends with Stop
+	ctx, j.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	j.done = make(chan struct{})
+
+	go func() {
+		defer close(j.done)
+
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := j.fn(ctx)
+				if err != nil && ctx.Err() == nil {
+					j.logger.ErrorContext(ctx, "periodic job failed", "job", j.name, "error", err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (j *PeriodicJob) Stop(ctx context.Context) error {
+	j.cancel()
+
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
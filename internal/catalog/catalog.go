@@ -0,0 +1,158 @@
+// Package catalog is the list of known exercises. Free text exercise names,
+// e.g. from imports, are matched against it to get one canonical name per
+// exercise. The data lives in exercises.json, the fallback MET values in
+// met.json.
+package catalog
+
+import (
+	_ "embed"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+type Exercise struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Muscle   string `json:"muscle"`
+	// MET is the energy cost of the exercise as a multiple of resting, as
+	// listed in the Compendium of Physical Activities. Zero means unknown.
+	MET     float64  `json:"met"`
+	Aliases []string `json:"aliases"`
+}
+
+// METTable holds the MET values used for exercises without one of their
+// own: per category, and Default for anything else.
+type METTable struct {
+	Default    float64            `json:"default"`
+	Categories map[string]float64 `json:"categories"`
+}
+
+// Catalog looks up exercises by name or alias, ignoring case, punctuation
+// and equipment qualifiers such as "(Barbell)".
+type Catalog struct {
+	exercises []Exercise
+	index     map[string]int
+	mets      METTable
+}
+
+var (
+	//go:embed exercises.json
+	exercisesJSON []byte
+	//go:embed met.json
+	metJSON []byte
+)
+
+// Default returns the catalog built from the embedded exercise data.
+var Default = sync.OnceValue(func() *Catalog {
+	var exercises []Exercise
+	if err := json.Unmarshal(exercisesJSON, &exercises); err != nil {
+		panic("catalog: invalid exercises.json: " + err.Error())
+	}
+
+	var mets METTable
+	if err := json.Unmarshal(metJSON, &mets); err != nil {
+		panic("catalog: invalid met.json: " + err.Error())
+	}
+
+	return New(exercises, mets)
+})
+
+func New(exercises []Exercise, mets METTable) *Catalog {
+	c := &Catalog{
+		exercises: exercises,
+		index:     make(map[string]int),
+		mets:      mets,
+	}
+
+	for i, exercise := range exercises {
+		for _, name := range append([]string{exercise.Name}, exercise.Aliases...) {
+			key := normalize(name)
+			if _, ok := c.index[key]; !ok {
+				c.index[key] = i
+			}
+		}
+	}
+
+	return c
+}
+
+func (c *Catalog) Exercises() []Exercise {
+	return c.exercises
+}
+
+// Match finds the exercise called name.
+func (c *Catalog) Match(name string) (Exercise, bool) {
+	for _, key := range candidates(name) {
+		if i, ok := c.index[key]; ok {
+			return c.exercises[i], true
+		}
+	}
+
+	return Exercise{}, false
+}
+
+// MET returns the MET value of the exercise called name. Exercises without
+// one get the value of their category; for names that are not in the
+// catalog category is used instead.
+func (c *Catalog) MET(name, category string) float64 {
+	if exercise, ok := c.Match(name); ok {
+		if exercise.MET > 0 {
+			return exercise.MET
+		}
+		category = exercise.Category
+	}
+
+	if met, ok := c.mets.Categories[category]; ok {
+		return met
+	}
+	return c.mets.Default
+}
+
+var (
+	nonAlnumRX     = regexp.MustCompile(`[^a-z0-9]+`)
+	parenthesisRX  = regexp.MustCompile(`\(([^)]*)\)`)
+	equipmentWords = map[string]bool{
+		"barbell": true, "dumbbell": true, "dumbbells": true, "cable": true, "machine": true,
+		"smith": true, "kettlebell": true, "band": true, "bodyweight": true, "weighted": true,
+		"assisted": true, "ez": true, "bar": true,
+	}
+)
+
+func normalize(name string) string {
+	name = strings.ToLower(strings.ReplaceAll(name, "&", " and "))
+	return strings.TrimSpace(nonAlnumRX.ReplaceAllString(name, " "))
+}
+
+// candidates returns the keys to try for name, most specific first:
+// "Bench Press (Dumbbell)" is tried as "dumbbell bench press", then
+// "bench press dumbbell", then "bench press".
+func candidates(name string) []string {
+	keys := []string{normalize(name)}
+
+	if m := parenthesisRX.FindStringSubmatch(name); m != nil {
+		base := normalize(parenthesisRX.ReplaceAllString(name, " "))
+		qualifier := normalize(m[1])
+		keys = append(keys, qualifier+" "+base, base+" "+qualifier, base)
+	}
+
+	var words []string
+	for _, word := range strings.Fields(keys[len(keys)-1]) {
+		if !equipmentWords[word] {
+			words = append(words, word)
+		}
+	}
+	if len(words) > 0 {
+		keys = append(keys, strings.Join(words, " "))
+
+		// plural of the last word, e.g. "pull ups"
+		last := words[len(words)-1]
+		if len(last) > 2 && strings.HasSuffix(last, "s") && !strings.HasSuffix(last, "ss") {
+			words[len(words)-1] = strings.TrimSuffix(last, "s")
+			keys = append(keys, strings.Join(words, " "))
+		}
+	}
+
+	return keys
+}
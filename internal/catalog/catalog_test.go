@@ -0,0 +1,50 @@
+package catalog
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	test := []struct {
+		name string
+		want string
+	}{
+		{name: "Bench Press", want: "Bench Press"},
+		{name: "bench press (barbell)", want: "Bench Press"},
+		{name: "Bench Press (Dumbbell)", want: "Dumbbell Bench Press"},
+		{name: "Squat (Barbell)", want: "Squat"},
+		{name: "Lat Pulldown (Cable)", want: "Lat Pulldown"},
+		{name: "Pull Ups", want: "Pull Up"},
+		{name: "Pull-up (Weighted)", want: "Pull Up"},
+		{name: "RDL", want: "Romanian Deadlift"},
+		{name: "Running (Treadmill)", want: "Treadmill"},
+		{name: "Clean & Jerk", want: "Clean and Jerk"},
+		{name: "Underwater Basket Weaving", want: ""},
+	}
+
+	for _, tt := range test {
+		t.Run(tt.name, func(t *testing.T) {
+			exercise, ok := Default().Match(tt.name)
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, exercise.Name)
+		})
+	}
+}
+
+func TestMET(t *testing.T) {
+	c := New([]Exercise{
+		{Name: "Squat", Category: "strength", MET: 6},
+		{Name: "Farmer Carry", Category: "strength"},
+	}, METTable{Default: 4, Categories: map[string]float64{"strength": 5, "cardio": 7}})
+
+	assert.Equal(t, 6.0, c.MET("Squat (Barbell)", "cardio"))
+	// known exercises fall back to their own category
+	assert.Equal(t, 5.0, c.MET("Farmer Carry", "cardio"))
+	assert.Equal(t, 7.0, c.MET("Hiking", "cardio"))
+	assert.Equal(t, 4.0, c.MET("Hiking", ""))
+
+	for _, exercise := range Default().Exercises() {
+		assert.Positive(t, exercise.MET, exercise.Name)
+	}
+}
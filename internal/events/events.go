@@ -0,0 +1,54 @@
+// Package events passes domain events from the code that raises them to the
+// code that reacts to them, such as notifications, without either knowing
+// the other.
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Event is something that happened in the domain. Name identifies the kind
+// of event handlers subscribe to, e.g. "goal.achieved".
+type Event interface {
+	Name() string
+}
+
+type Handler func(ctx context.Context, event Event) error
+
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+	logger   *slog.Logger
+}
+
+func NewBus(logger *slog.Logger) *Bus {
+	return &Bus{
+		handlers: make(map[string][]Handler),
+		logger:   logger,
+	}
+}
+
+// Subscribe registers h for the events called name.
+func (b *Bus) Subscribe(name string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers[name] = append(b.handlers[name], h)
+}
+
+// Publish passes event to its handlers in the order they subscribed and
+// returns once they are done. A handler that fails is logged and does not
+// keep the event from the others.
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	b.mu.RLock()
+	handlers := b.handlers[event.Name()]
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		if err := h(ctx, event); err != nil {
+			b.logger.ErrorContext(ctx, "event handler failed", "event", event.Name(), "error", err)
+		}
+	}
+}
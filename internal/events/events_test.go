@@ -0,0 +1,44 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"testing"
+)
+
+type testEvent string
+
+func (e testEvent) Name() string {
+	return string(e)
+}
+
+func TestBus(t *testing.T) {
+	var logs bytes.Buffer
+	bus := NewBus(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	var calls []string
+	bus.Subscribe("goal.achieved", func(ctx context.Context, event Event) error {
+		calls = append(calls, "first")
+		return errors.New("mail server down")
+	})
+	bus.Subscribe("goal.achieved", func(ctx context.Context, event Event) error {
+		calls = append(calls, "second")
+		return nil
+	})
+	bus.Subscribe("other", func(ctx context.Context, event Event) error {
+		calls = append(calls, "other")
+		return nil
+	})
+
+	bus.Publish(context.Background(), testEvent("goal.achieved"))
+
+	// a failing handler does not keep the event from the next one
+	assert.Equal(t, []string{"first", "second"}, calls)
+	assert.Contains(t, logs.String(), "mail server down")
+
+	bus.Publish(context.Background(), testEvent("nobody.listens"))
+	assert.Len(t, calls, 2)
+}
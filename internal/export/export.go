@@ -0,0 +1,249 @@
+// Package export writes workouts as CSV, JSON Lines or a Markdown training
+// log. Writers take one workout at a time, so an export never has to hold
+// more than the workout being written.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatCSV      Format = "csv"
+	FormatJSONL    Format = "jsonl"
+	FormatMarkdown Format = "md"
+)
+
+var Formats = []string{string(FormatCSV), string(FormatJSONL), string(FormatMarkdown)}
+
+// Writer writes workouts in one format. Close writes whatever the format
+// needs at the end and flushes; it does not close the underlying writer.
+type Writer interface {
+	Write(workout *store.Workout) error
+	Close() error
+}
+
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatJSONL:
+		return &jsonlWriter{enc: json.NewEncoder(w)}, nil
+	case FormatMarkdown:
+		return &markdownWriter{w: bufio.NewWriter(w)}, nil
+	}
+
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// ContentType returns the media type of format.
+func ContentType(format Format) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSONL:
+		return "application/jsonl; charset=utf-8"
+	default:
+		return "text/markdown; charset=utf-8"
+	}
+}
+
+// csvHeader matches the generic import format, so an export can be imported
+// again.
+var csvHeader = []string{
+	"date", "title", "exercise", "sets", "reps", "weight", "duration_seconds", "notes",
+	"duration_minutes", "calories_burned", "description", "distance", "distance_unit",
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	return cw, cw.w.Write(csvHeader)
+}
+
+// Write writes one row per entry. A workout without entries still gets a row
+// so it is not lost.
+func (cw *csvWriter) Write(workout *store.Workout) error {
+	row := func(entry *store.WorkoutEntries) []string {
+		record := []string{
+			workout.PerformedAt.UTC().Format(time.RFC3339),
+			csvText(workout.Title),
+			"", "", "", "", "", "",
+			strconv.Itoa(workout.DurationMinutes),
+			optionalInt(workout.CaloriesBurned),
+			csvText(workout.Description),
+			"", "",
+		}
+		if entry != nil {
+			record[2] = csvText(entry.ExerciseName)
+			record[3] = strconv.Itoa(entry.Sets)
+			record[4] = formatInt(entry.Reps)
+			record[5] = formatFloat(entry.Weight)
+			record[6] = formatInt(entry.DurationSeconds)
+			record[7] = csvText(entry.Notes)
+			record[11] = formatFloat(entry.Distance)
+			record[12] = entry.DistanceUnit
+		}
+		return record
+	}
+
+	if len(workout.Entries) == 0 {
+		return cw.w.Write(row(nil))
+	}
+	for i := range workout.Entries {
+		if err := cw.w.Write(row(&workout.Entries[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// csvText keeps spreadsheets from running user text that looks like a
+// formula.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+// Write writes the workout as it is returned by GET /workouts/{id}, on one
+// line.
+func (jw *jsonlWriter) Write(workout *store.Workout) error {
+	return jw.enc.Encode(workout)
+}
+
+func (jw *jsonlWriter) Close() error {
+	return nil
+}
+
+type markdownWriter struct {
+	w       *bufio.Writer
+	started bool
+}
+
+func (mw *markdownWriter) Write(workout *store.Workout) error {
+	if !mw.started {
+		mw.started = true
+		fmt.Fprint(mw.w, "# Training log\n")
+	}
+
+	fmt.Fprintf(mw.w, "\n## %s · %s\n\n", workout.PerformedAt.UTC().Format("Mon 2 Jan 2006, 15:04"), markdownText(workout.Title))
+
+	details := []string{fmt.Sprintf("%d min", workout.DurationMinutes)}
+	switch {
+	case workout.CaloriesBurned > 0 && workout.CaloriesSource == store.CaloriesEstimated:
+		details = append(details, fmt.Sprintf("~%d kcal (estimated)", workout.CaloriesBurned))
+	case workout.CaloriesBurned > 0:
+		details = append(details, fmt.Sprintf("%d kcal", workout.CaloriesBurned))
+	}
+	fmt.Fprintf(mw.w, "%s\n", strings.Join(details, " · "))
+
+	if workout.Description != "" {
+		fmt.Fprintf(mw.w, "\n%s\n", markdownText(workout.Description))
+	}
+
+	if len(workout.Entries) > 0 {
+		fmt.Fprint(mw.w, "\n| Exercise | Sets | Reps / time | Weight | Distance | Notes |\n|---|---|---|---|---|---|\n")
+		for _, entry := range workout.Entries {
+			amount := formatInt(entry.Reps)
+			if entry.DurationSeconds != nil {
+				amount = formatSeconds(*entry.DurationSeconds)
+			}
+
+			distance := ""
+			if entry.Distance != nil {
+				distance = formatFloat(entry.Distance) + " " + entry.DistanceUnit
+				if entry.AvgPace != nil {
+					distance += " @ " + formatSeconds(int(*entry.AvgPace)) + "/km"
+				}
+			}
+
+			fmt.Fprintf(mw.w, "| %s | %d | %s | %s | %s | %s |\n",
+				markdownCell(entry.ExerciseName), entry.Sets, amount, formatFloat(entry.Weight), distance, markdownCell(entry.Notes))
+		}
+	}
+
+	// the buffer only holds this workout, so flushing here keeps the
+	// response streaming
+	return mw.w.Flush()
+}
+
+func (mw *markdownWriter) Close() error {
+	if !mw.started {
+		fmt.Fprint(mw.w, "# Training log\n\nNo workouts.\n")
+	}
+	return mw.w.Flush()
+}
+
+// markdownText keeps user text from turning into headings or HTML.
+func markdownText(s string) string {
+	s = strings.NewReplacer("<", "&lt;", ">", "&gt;").Replace(s)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			lines[i] = `\` + strings.TrimSpace(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markdownCell keeps text on one table row and out of the cell borders.
+func markdownCell(s string) string {
+	s = strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ").Replace(s)
+	return markdownText(s)
+}
+
+func formatInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+func optionalInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+func formatFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+// formatSeconds formats a set duration as 45s, 2m or 1m30s.
+func formatSeconds(seconds int) string {
+	minutes, seconds := seconds/60, seconds%60
+	switch {
+	case minutes == 0:
+		return fmt.Sprintf("%ds", seconds)
+	case seconds == 0:
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dm%ds", minutes, seconds)
+}
@@ -0,0 +1,110 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func floatPtr(f float64) *float64 { return &f }
+func intPtr(i int) *int           { return &i }
+
+func testWorkouts() []*store.Workout {
+	return []*store.Workout{
+		{
+			ID:              1,
+			Title:           "Push Day",
+			Description:     "felt strong",
+			DurationMinutes: 60,
+			CaloriesBurned:  350,
+			PerformedAt:     time.Date(2024, 1, 22, 18, 0, 0, 0, time.UTC),
+			Entries: []store.WorkoutEntries{
+				{ExerciseName: "Bench Press", Sets: 3, Reps: intPtr(10), Weight: floatPtr(80.5)},
+				{ExerciseName: "Plank", Sets: 3, DurationSeconds: intPtr(90), Notes: "a | b"},
+				{Type: store.EntryCardio, ExerciseName: "Running", Sets: 1, DurationSeconds: intPtr(1500), Distance: floatPtr(5), DistanceUnit: store.UnitKilometers, AvgPace: floatPtr(300)},
+			},
+		},
+		{
+			ID:              2,
+			Title:           "=HYPERLINK(\"x\")",
+			DurationMinutes: 20,
+			CaloriesBurned:  90,
+			CaloriesSource:  store.CaloriesEstimated,
+			PerformedAt:     time.Date(2024, 1, 23, 7, 0, 0, 0, time.UTC),
+			Entries:         []store.WorkoutEntries{},
+		},
+	}
+}
+
+func write(t *testing.T, format Format) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, format)
+	require.NoError(t, err)
+	for _, workout := range testWorkouts() {
+		require.NoError(t, writer.Write(workout))
+	}
+	require.NoError(t, writer.Close())
+
+	return buf.String()
+}
+
+func TestCSV(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(write(t, FormatCSV)), "\n")
+
+	require.Len(t, lines, 5)
+	assert.Equal(t, "date,title,exercise,sets,reps,weight,duration_seconds,notes,duration_minutes,calories_burned,description,distance,distance_unit", lines[0])
+	assert.Equal(t, "2024-01-22T18:00:00Z,Push Day,Bench Press,3,10,80.5,,,60,350,felt strong,,", lines[1])
+	assert.Equal(t, "2024-01-22T18:00:00Z,Push Day,Plank,3,,,90,a | b,60,350,felt strong,,", lines[2])
+	assert.Equal(t, "2024-01-22T18:00:00Z,Push Day,Running,1,,,1500,,60,350,felt strong,5,km", lines[3])
+	// formulas are neutralised and a workout without entries keeps its row
+	assert.Equal(t, `2024-01-23T07:00:00Z,"'=HYPERLINK(""x"")",,,,,,,20,90,,,`, lines[4])
+}
+
+func TestCSVText(t *testing.T) {
+	for _, s := range []string{"=1+1", "+1", "-1", "@SUM(A1)", "\tx", "\rx"} {
+		assert.Equal(t, "'"+s, csvText(s), s)
+	}
+	for _, s := range []string{"", "Push Day", "1-2", "a=b"} {
+		assert.Equal(t, s, csvText(s), s)
+	}
+}
+
+func TestJSONL(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(write(t, FormatJSONL)), "\n")
+	require.Len(t, lines, 2)
+
+	var workout store.Workout
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &workout))
+	assert.Equal(t, "Push Day", workout.Title)
+	assert.Len(t, workout.Entries, 3)
+}
+
+func TestMarkdown(t *testing.T) {
+	out := write(t, FormatMarkdown)
+
+	assert.True(t, strings.HasPrefix(out, "# Training log\n"))
+	assert.Contains(t, out, "## Mon 22 Jan 2024, 18:00 · Push Day\n")
+	assert.Contains(t, out, "60 min · 350 kcal\n")
+	assert.Contains(t, out, "20 min · ~90 kcal (estimated)\n")
+	assert.Contains(t, out, "| Bench Press | 3 | 10 | 80.5 |  |  |\n")
+	assert.Contains(t, out, `| Plank | 3 | 1m30s |  |  | a \| b |`+"\n")
+	assert.Contains(t, out, "| Running | 1 | 25m |  | 5 km @ 5m/km |  |\n")
+
+	var buf bytes.Buffer
+	writer, err := NewWriter(&buf, FormatMarkdown)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.Equal(t, "# Training log\n\nNo workouts.\n", buf.String())
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := NewWriter(&bytes.Buffer{}, "xml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,158 @@
+// Package goals measures the progress of goals and reports the ones that
+// get achieved.
+package goals
+
+import (
+	"github.com/oki-irawan/fem_project/internal/store"
+	"math"
+	"time"
+)
+
+// Statuses of a goal.
+const (
+	StatusOnTrack  = "on_track"
+	StatusBehind   = "behind"
+	StatusAchieved = "achieved"
+)
+
+// Progress is how far a goal has come in its current window.
+type Progress struct {
+	// From and To are the first and last day that count, To is nil when
+	// the goal has no end.
+	From store.Date  `json:"from"`
+	To   *store.Date `json:"to"`
+	// Current is what the window measures, see store.GoalStore.MeasureGoal.
+	Current *float64 `json:"current"`
+	// Percent is how far Current is from the baseline to the target.
+	Percent float64 `json:"percent"`
+	// ExpectedPercent is what a steady pace would have reached by now. It is
+	// nil when the goal has no end.
+	ExpectedPercent *float64 `json:"expected_percent"`
+	Status          string   `json:"status"`
+}
+
+// Window returns the time that counts towards goal at now: everything from
+// its start to its deadline, cut down to the current period for a
+// repeating goal. Before the goal starts or after its deadline, the window
+// is that of its first or last day.
+func Window(goal *store.Goal, now time.Time) store.TimeRange {
+	r := store.TimeRange{From: goal.StartsOn.Time}
+	if goal.Deadline != nil {
+		r.To = goal.Deadline.AddDate(0, 0, 1)
+	}
+	if goal.Period == nil {
+		return r
+	}
+
+	at := now
+	if at.Before(r.From) {
+		at = r.From
+	}
+	if !r.To.IsZero() && !at.Before(r.To) {
+		at = goal.Deadline.Time
+	}
+
+	start, end := periodOf(*goal.Period, at)
+	if start.After(r.From) {
+		r.From = start
+	}
+	if r.To.IsZero() || end.Before(r.To) {
+		r.To = end
+	}
+	return r
+}
+
+// periodOf returns the start and end of the UTC calendar period containing t.
+func periodOf(period string, t time.Time) (time.Time, time.Time) {
+	day := store.NewDate(t).Time
+	year, month, _ := day.Date()
+
+	switch period {
+	case store.PeriodWeek:
+		// weeks start on Monday
+		start := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		return start, start.AddDate(0, 0, 7)
+	case store.PeriodMonth:
+		start := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	case store.PeriodQuarter:
+		start := time.Date(year, (month-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 3, 0)
+	default:
+		start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(1, 0, 0)
+	}
+}
+
+// Reached reports whether value meets the target of goal. A body weight
+// goal below its baseline is reached by going down to the target, any other
+// goal by going up to it.
+func Reached(goal *store.Goal, value float64) bool {
+	if goal.Type == store.GoalBodyWeight && (goal.Baseline == nil || *goal.Baseline >= goal.Target) {
+		return value <= goal.Target
+	}
+	return value >= goal.Target
+}
+
+// Evaluate returns the progress of goal at now, given what its window at
+// now measures. A goal stays achieved once it was recorded as achieved in
+// the window, even if current has dropped below the target since.
+func Evaluate(goal *store.Goal, current *float64, now time.Time) Progress {
+	window := Window(goal, now)
+	progress := Progress{From: store.NewDate(window.From), Status: StatusOnTrack}
+	if !window.To.IsZero() {
+		to := store.NewDate(window.To.AddDate(0, 0, -1))
+		progress.To = &to
+	}
+
+	reached := false
+	if current != nil {
+		value := round(*current, 2)
+		progress.Current = &value
+		reached = Reached(goal, *current)
+		progress.Percent = percent(goal, *current, reached)
+	}
+
+	if reached || (goal.AchievedAt != nil && !goal.AchievedAt.Before(window.From)) {
+		progress.Status = StatusAchieved
+		return progress
+	}
+
+	if !window.To.IsZero() {
+		total := window.To.Sub(window.From)
+		elapsed := min(max(now.Sub(window.From), 0), total)
+		expected := round(float64(elapsed)/float64(total)*100, 1)
+		progress.ExpectedPercent = &expected
+
+		if progress.Percent < expected {
+			progress.Status = StatusBehind
+		}
+	}
+
+	return progress
+}
+
+// percent returns how far value is on the way from the baseline of goal
+// to its target, from 0 to 100. Counts start from 0, and so does a lift
+// goal whose baseline already meets the target.
+func percent(goal *store.Goal, value float64, reached bool) float64 {
+	if reached {
+		return 100
+	}
+
+	base := 0.0
+	if goal.Baseline != nil && (goal.Type == store.GoalBodyWeight || *goal.Baseline < goal.Target) {
+		base = *goal.Baseline
+	}
+	if base == goal.Target {
+		return 0
+	}
+
+	p := (value - base) / (goal.Target - base) * 100
+	return round(min(max(p, 0), 100), 1)
+}
+
+func round(f float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(f*scale) / scale
+}
@@ -0,0 +1,123 @@
+package goals
+
+import (
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func day(month time.Month, d int) time.Time {
+	return time.Date(2024, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func datePtr(t time.Time) *store.Date {
+	d := store.NewDate(t)
+	return &d
+}
+
+func TestWindow(t *testing.T) {
+	weekly := &store.Goal{Type: store.GoalWorkouts, Target: 4, Period: stringPtr(store.PeriodWeek), StartsOn: store.NewDate(day(3, 6))}
+	quarterly := &store.Goal{
+		Type: store.GoalDistance, Target: 200, Period: stringPtr(store.PeriodQuarter),
+		StartsOn: store.NewDate(day(1, 10)), Deadline: datePtr(day(5, 15)),
+	}
+	lift := &store.Goal{Type: store.GoalLift, Target: 100, StartsOn: store.NewDate(day(3, 1)), Deadline: datePtr(day(3, 31))}
+
+	test := []struct {
+		name     string
+		goal     *store.Goal
+		now      time.Time
+		wantFrom time.Time
+		wantTo   time.Time
+	}{
+		{name: "Current Week", goal: weekly, now: day(3, 13).Add(15 * time.Hour), wantFrom: day(3, 11), wantTo: day(3, 18)},
+		{name: "First Week Starts With The Goal", goal: weekly, now: day(3, 1), wantFrom: day(3, 6), wantTo: day(3, 11)},
+		{name: "Quarter Ends At The Deadline", goal: quarterly, now: day(5, 20), wantFrom: day(4, 1), wantTo: day(5, 16)},
+		{name: "Quarter Before The Deadline", goal: quarterly, now: day(2, 29), wantFrom: day(1, 10), wantTo: day(4, 1)},
+		{name: "Whole Goal", goal: lift, now: day(3, 16), wantFrom: day(3, 1), wantTo: day(4, 1)},
+	}
+
+	for _, tt := range test {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Window(tt.goal, tt.now)
+			assert.Equal(t, tt.wantFrom, r.From)
+			assert.Equal(t, tt.wantTo, r.To)
+		})
+	}
+
+	r := Window(&store.Goal{Type: store.GoalBodyWeight, StartsOn: store.NewDate(day(3, 1))}, day(6, 1))
+	assert.True(t, r.To.IsZero())
+}
+
+func TestReached(t *testing.T) {
+	losing := &store.Goal{Type: store.GoalBodyWeight, Target: 75, Baseline: floatPtr(80)}
+	assert.False(t, Reached(losing, 76))
+	assert.True(t, Reached(losing, 74.9))
+
+	gaining := &store.Goal{Type: store.GoalBodyWeight, Target: 75, Baseline: floatPtr(70)}
+	assert.False(t, Reached(gaining, 74))
+	assert.True(t, Reached(gaining, 76))
+
+	assert.True(t, Reached(&store.Goal{Type: store.GoalWorkouts, Target: 4}, 4))
+}
+
+func TestEvaluate(t *testing.T) {
+	weekly := &store.Goal{Type: store.GoalWorkouts, Target: 4, Period: stringPtr(store.PeriodWeek), StartsOn: store.NewDate(day(3, 1))}
+	// Wednesday noon, 2.5 of 7 days into the week
+	wednesday := day(3, 13).Add(12 * time.Hour)
+
+	progress := Evaluate(weekly, floatPtr(1), wednesday)
+	assert.Equal(t, store.NewDate(day(3, 11)), progress.From)
+	require.NotNil(t, progress.To)
+	assert.Equal(t, store.NewDate(day(3, 17)), *progress.To)
+	assert.Equal(t, 25.0, progress.Percent)
+	assert.Equal(t, 35.7, *progress.ExpectedPercent)
+	assert.Equal(t, StatusBehind, progress.Status)
+
+	assert.Equal(t, StatusOnTrack, Evaluate(weekly, floatPtr(3), wednesday).Status)
+
+	progress = Evaluate(weekly, floatPtr(4), wednesday)
+	assert.Equal(t, StatusAchieved, progress.Status)
+	assert.Equal(t, 100.0, progress.Percent)
+
+	// recorded this week, so a deleted workout does not take it back
+	achievedAt := day(3, 12)
+	weekly.AchievedAt = &achievedAt
+	assert.Equal(t, StatusAchieved, Evaluate(weekly, floatPtr(2), wednesday).Status)
+	// recorded last week
+	achievedAt = day(3, 8)
+	assert.Equal(t, StatusBehind, Evaluate(weekly, floatPtr(1), wednesday).Status)
+
+	lift := &store.Goal{
+		Type: store.GoalLift, ExerciseName: stringPtr("Bench Press"), Target: 100, Baseline: floatPtr(90),
+		StartsOn: store.NewDate(day(3, 1)), Deadline: datePtr(day(3, 31)),
+	}
+	// 15 of 31 days gone, half way from 90 to 100
+	progress = Evaluate(lift, floatPtr(95), day(3, 16))
+	assert.Equal(t, 50.0, progress.Percent)
+	assert.Equal(t, 48.4, *progress.ExpectedPercent)
+	assert.Equal(t, StatusOnTrack, progress.Status)
+
+	progress = Evaluate(lift, nil, day(3, 16))
+	assert.Nil(t, progress.Current)
+	assert.Equal(t, 0.0, progress.Percent)
+	assert.Equal(t, StatusBehind, progress.Status)
+
+	weight := &store.Goal{Type: store.GoalBodyWeight, Target: 75, Baseline: floatPtr(80), StartsOn: store.NewDate(day(3, 1))}
+	progress = Evaluate(weight, floatPtr(77), day(3, 16))
+	assert.Equal(t, 60.0, progress.Percent)
+	assert.Nil(t, progress.ExpectedPercent)
+	assert.Nil(t, progress.To)
+	assert.Equal(t, StatusOnTrack, progress.Status)
+	assert.Equal(t, StatusAchieved, Evaluate(weight, floatPtr(74.9), day(3, 16)).Status)
+}
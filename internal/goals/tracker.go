@@ -0,0 +1,105 @@
+package goals
+
+import (
+	"context"
+	"github.com/oki-irawan/fem_project/internal/events"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"log/slog"
+	"time"
+)
+
+const (
+	// batchSize is how many goals a run checks.
+	batchSize = 100
+	// deadlineGrace is how long goals stay open after their deadline, so
+	// workouts logged late still count towards them.
+	deadlineGrace = 7 * 24 * time.Hour
+)
+
+// EventGoalAchieved is the name of GoalAchieved events.
+const EventGoalAchieved = "goal.achieved"
+
+// GoalAchieved is published when a goal is reached, once per period for a
+// repeating goal. Value is what reached it.
+type GoalAchieved struct {
+	Goal  *store.Goal
+	Value float64
+}
+
+func (GoalAchieved) Name() string {
+	return EventGoalAchieved
+}
+
+// Tracker records the goals that get achieved and publishes a GoalAchieved
+// event for each.
+type Tracker struct {
+	goals  store.GoalStore
+	events *events.Bus
+	logger *slog.Logger
+}
+
+func NewTracker(goals store.GoalStore, bus *events.Bus, logger *slog.Logger) *Tracker {
+	return &Tracker{
+		goals:  goals,
+		events: bus,
+		logger: logger,
+	}
+}
+
+// RunPending checks one batch of open goals. The ones checked least
+// recently go first, so every goal gets its turn. A goal that fails is
+// logged and marked checked like the others, so it does not hold up the
+// rest.
+func (t *Tracker) RunPending(ctx context.Context) error {
+	now := time.Now()
+	open, err := t.goals.ListOpenGoals(ctx, now.Add(-deadlineGrace), batchSize)
+	if err != nil {
+		return err
+	}
+
+	achieved := 0
+	for _, goal := range open {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		ok, err := t.check(ctx, goal, now)
+		if err != nil {
+			t.logger.ErrorContext(ctx, "checking goal", "goal_id", goal.ID, "error", err)
+			if err := t.goals.MarkGoalChecked(ctx, goal.ID); err != nil {
+				t.logger.ErrorContext(ctx, "marking goal checked", "goal_id", goal.ID, "error", err)
+			}
+			continue
+		}
+		if ok {
+			achieved++
+		}
+	}
+
+	if achieved > 0 {
+		t.logger.InfoContext(ctx, "goals achieved", "goals", achieved)
+	}
+	return nil
+}
+
+// check measures goal and reports whether this call recorded it as
+// achieved.
+func (t *Tracker) check(ctx context.Context, goal *store.Goal, now time.Time) (bool, error) {
+	window := Window(goal, now)
+	current, err := t.goals.MeasureGoal(ctx, goal, window)
+	if err != nil {
+		return false, err
+	}
+	if current == nil || !Reached(goal, *current) {
+		return false, t.goals.MarkGoalChecked(ctx, goal.ID)
+	}
+
+	achievedAt, err := t.goals.MarkGoalAchieved(ctx, goal.ID, window.From)
+	if err != nil || achievedAt == nil {
+		return false, err
+	}
+
+	goal.AchievedAt = achievedAt
+	t.events.Publish(ctx, GoalAchieved{Goal: goal, Value: *current})
+	return true, nil
+}
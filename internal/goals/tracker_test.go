@@ -0,0 +1,87 @@
+package goals
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/oki-irawan/fem_project/internal/events"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type fakeGoalStore struct {
+	store.GoalStore
+	open          []*store.Goal
+	deadlineSince time.Time
+	values        map[int64]float64
+	errs          map[int64]error
+	checked       []int64
+	achieved      []int64
+}
+
+func (s *fakeGoalStore) ListOpenGoals(ctx context.Context, deadlineSince time.Time, limit int) ([]*store.Goal, error) {
+	s.deadlineSince = deadlineSince
+	return s.open, nil
+}
+
+func (s *fakeGoalStore) MeasureGoal(ctx context.Context, goal *store.Goal, r store.TimeRange) (*float64, error) {
+	if err := s.errs[goal.ID]; err != nil {
+		return nil, err
+	}
+	value, ok := s.values[goal.ID]
+	if !ok {
+		return nil, nil
+	}
+	return &value, nil
+}
+
+func (s *fakeGoalStore) MarkGoalChecked(ctx context.Context, id int64) error {
+	s.checked = append(s.checked, id)
+	return nil
+}
+
+func (s *fakeGoalStore) MarkGoalAchieved(ctx context.Context, id int64, since time.Time) (*time.Time, error) {
+	s.achieved = append(s.achieved, id)
+	now := time.Now()
+	return &now, nil
+}
+
+func TestTrackerRunPending(t *testing.T) {
+	start := store.NewDate(time.Now().AddDate(0, -1, 0))
+	goal := func(id int64) *store.Goal {
+		return &store.Goal{ID: id, UserID: 1, Type: store.GoalWorkouts, Target: 4, StartsOn: start}
+	}
+
+	goals := &fakeGoalStore{
+		open:   []*store.Goal{goal(1), goal(2), goal(3)},
+		values: map[int64]float64{2: 2, 3: 5},
+		errs:   map[int64]error{1: errors.New("statement timeout")},
+	}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	bus := events.NewBus(logger)
+
+	var published []int64
+	bus.Subscribe(EventGoalAchieved, func(ctx context.Context, event events.Event) error {
+		published = append(published, event.(GoalAchieved).Goal.ID)
+		return nil
+	})
+
+	before := time.Now()
+	err := NewTracker(goals, bus, logger).RunPending(context.Background())
+	require.NoError(t, err)
+
+	// the failing goal is logged and moves to the back like the others
+	assert.Contains(t, logs.String(), "statement timeout")
+	assert.Equal(t, []int64{1, 2}, goals.checked)
+	assert.Equal(t, []int64{3}, goals.achieved)
+	assert.Equal(t, []int64{3}, published)
+
+	// goals whose deadline passed recently are still checked
+	assert.WithinDuration(t, before.Add(-deadlineGrace), goals.deadlineSince, time.Minute)
+}
@@ -0,0 +1,150 @@
+// Package history compares revisions of a workout.
+package history
+
+import (
+	"github.com/oki-irawan/fem_project/internal/store"
+	"reflect"
+)
+
+// Entry diff operations.
+const (
+	EntryAdded   = "added"
+	EntryRemoved = "removed"
+	EntryChanged = "changed"
+)
+
+// FieldChange is a field whose value differs between two revisions.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// EntryDiff describes what happened to one entry. Entries are matched by
+// UUID, so an entry that was replaced by a new one shows up as removed and
+// added.
+type EntryDiff struct {
+	UUID         string        `json:"uuid"`
+	Op           string        `json:"op"`
+	ExerciseName string        `json:"exercise_name"`
+	Changes      []FieldChange `json:"changes,omitempty"`
+}
+
+type Diff struct {
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Changes []FieldChange `json:"changes"`
+	Entries []EntryDiff   `json:"entries"`
+}
+
+// Compare returns the changes that turn from into to.
+func Compare(from, to *store.Workout) Diff {
+	diff := Diff{
+		From:    from.Version,
+		To:      to.Version,
+		Changes: []FieldChange{},
+		Entries: []EntryDiff{},
+	}
+
+	diff.Changes = appendChange(diff.Changes, "title", from.Title, to.Title)
+	diff.Changes = appendChange(diff.Changes, "description", from.Description, to.Description)
+	diff.Changes = appendChange(diff.Changes, "duration_minutes", from.DurationMinutes, to.DurationMinutes)
+	diff.Changes = appendChange(diff.Changes, "calories_burned", from.CaloriesBurned, to.CaloriesBurned)
+	diff.Changes = appendChange(diff.Changes, "calories_source", caloriesSource(from), caloriesSource(to))
+	if !from.PerformedAt.Equal(to.PerformedAt) {
+		diff.Changes = append(diff.Changes, FieldChange{Field: "performed_at", From: from.PerformedAt, To: to.PerformedAt})
+	}
+
+	old := make(map[string]*store.WorkoutEntries, len(from.Entries))
+	for i := range from.Entries {
+		old[from.Entries[i].UUID] = &from.Entries[i]
+	}
+
+	for i := range to.Entries {
+		entry := &to.Entries[i]
+
+		before, ok := old[entry.UUID]
+		if !ok {
+			diff.Entries = append(diff.Entries, EntryDiff{UUID: entry.UUID, Op: EntryAdded, ExerciseName: entry.ExerciseName})
+			continue
+		}
+		delete(old, entry.UUID)
+
+		if changes := compareEntries(before, entry); len(changes) > 0 {
+			diff.Entries = append(diff.Entries, EntryDiff{UUID: entry.UUID, Op: EntryChanged, ExerciseName: entry.ExerciseName, Changes: changes})
+		}
+	}
+
+	// keep removed entries in their original order
+	for i := range from.Entries {
+		entry := &from.Entries[i]
+		if _, ok := old[entry.UUID]; ok {
+			diff.Entries = append(diff.Entries, EntryDiff{UUID: entry.UUID, Op: EntryRemoved, ExerciseName: entry.ExerciseName})
+		}
+	}
+
+	return diff
+}
+
+func compareEntries(from, to *store.WorkoutEntries) []FieldChange {
+	var changes []FieldChange
+
+	changes = appendChange(changes, "type", entryType(from), entryType(to))
+	changes = appendChange(changes, "exercise_name", from.ExerciseName, to.ExerciseName)
+	changes = appendChange(changes, "sets", from.Sets, to.Sets)
+	changes = appendChange(changes, "reps", from.Reps, to.Reps)
+	changes = appendChange(changes, "duration_seconds", from.DurationSeconds, to.DurationSeconds)
+	changes = appendChange(changes, "weight", from.Weight, to.Weight)
+	changes = appendChange(changes, "notes", from.Notes, to.Notes)
+	changes = appendChange(changes, "order_index", from.OrderIndex, to.OrderIndex)
+	changes = appendChange(changes, "distance", from.Distance, to.Distance)
+	changes = appendChange(changes, "distance_unit", from.DistanceUnit, to.DistanceUnit)
+	changes = appendChange(changes, "elevation_gain_meters", from.ElevationGainMeters, to.ElevationGainMeters)
+	changes = appendChange(changes, "avg_heart_rate", from.AvgHeartRate, to.AvgHeartRate)
+	changes = appendChange(changes, "max_heart_rate", from.MaxHeartRate, to.MaxHeartRate)
+	if len(from.Intervals) > 0 || len(to.Intervals) > 0 {
+		changes = appendChange(changes, "intervals", from.Intervals, to.Intervals)
+	}
+
+	return changes
+}
+
+// entryType returns the type of entry. Snapshots from before entry types
+// have none, and those entries are strength entries.
+func entryType(entry *store.WorkoutEntries) string {
+	if entry.Type == "" {
+		return store.EntryStrength
+	}
+	return entry.Type
+}
+
+// caloriesSource returns where the calories of workout come from. Snapshots
+// from before estimates were reported by the client.
+func caloriesSource(workout *store.Workout) string {
+	if workout.CaloriesSource == "" {
+		return store.CaloriesReported
+	}
+	return workout.CaloriesSource
+}
+
+// appendChange adds a change for field unless from and to are equal. Pointer
+// values are compared and reported by what they point to.
+func appendChange(changes []FieldChange, field string, from, to any) []FieldChange {
+	from, to = deref(from), deref(to)
+	if reflect.DeepEqual(from, to) {
+		return changes
+	}
+
+	return append(changes, FieldChange{Field: field, From: from, To: to})
+}
+
+func deref(v any) any {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer {
+		return v
+	}
+	if rv.IsNil() {
+		return nil
+	}
+	return rv.Elem().Interface()
+}
@@ -0,0 +1,60 @@
+package history
+
+import (
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestCompare(t *testing.T) {
+	from := &store.Workout{
+		Version:         1,
+		Title:           "Push Day",
+		DurationMinutes: 60,
+		Entries: []store.WorkoutEntries{
+			{UUID: "a", ExerciseName: "Bench Press", Sets: 3, Reps: intPtr(10), Weight: floatPtr(80), OrderIndex: 0},
+			{UUID: "b", ExerciseName: "Dips", Sets: 3, Reps: intPtr(12), OrderIndex: 1},
+		},
+	}
+	to := &store.Workout{
+		Version:         2,
+		Title:           "Push Day",
+		DurationMinutes: 75,
+		Entries: []store.WorkoutEntries{
+			{UUID: "a", ExerciseName: "Bench Press", Sets: 3, Reps: intPtr(10), Weight: floatPtr(85), OrderIndex: 0},
+			{UUID: "c", ExerciseName: "Plank", Sets: 3, DurationSeconds: intPtr(60), OrderIndex: 1},
+		},
+	}
+
+	diff := Compare(from, to)
+
+	assert.Equal(t, 1, diff.From)
+	assert.Equal(t, 2, diff.To)
+	assert.Equal(t, []FieldChange{{Field: "duration_minutes", From: 60, To: 75}}, diff.Changes)
+	assert.Equal(t, []EntryDiff{
+		{UUID: "a", Op: EntryChanged, ExerciseName: "Bench Press", Changes: []FieldChange{{Field: "weight", From: 80.0, To: 85.0}}},
+		{UUID: "c", Op: EntryAdded, ExerciseName: "Plank"},
+		{UUID: "b", Op: EntryRemoved, ExerciseName: "Dips"},
+	}, diff.Entries)
+}
+
+func TestCompareIdentical(t *testing.T) {
+	workout := &store.Workout{
+		Version: 3,
+		Title:   "Legs",
+		Entries: []store.WorkoutEntries{{UUID: "a", ExerciseName: "Squat", Sets: 5, Reps: intPtr(5)}},
+	}
+
+	diff := Compare(workout, workout)
+
+	assert.Empty(t, diff.Changes)
+	assert.Empty(t, diff.Entries)
+}
@@ -0,0 +1,207 @@
+package imports
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/catalog"
+	"github.com/oki-irawan/fem_project/internal/metrics"
+	"github.com/oki-irawan/fem_project/internal/records"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/validator"
+	"log/slog"
+	"time"
+)
+
+const (
+	// staleAfter is how long a running import may go without progress
+	// before another worker takes it over.
+	staleAfter = 5 * time.Minute
+	// progressEvery is how many workouts are processed between progress
+	// updates.
+	progressEvery = 25
+)
+
+// Importer runs queued import jobs.
+type Importer struct {
+	imports  store.ImportStore
+	workouts store.WorkoutStore
+	catalog  *catalog.Catalog
+	logger   *slog.Logger
+}
+
+func NewImporter(imports store.ImportStore, workouts store.WorkoutStore, exercises *catalog.Catalog, logger *slog.Logger) *Importer {
+	return &Importer{
+		imports:  imports,
+		workouts: workouts,
+		catalog:  exercises,
+		logger:   logger,
+	}
+}
+
+// RunPending runs queued imports one after another until none are left.
+func (im *Importer) RunPending(ctx context.Context) error {
+	for ctx.Err() == nil {
+		job, err := im.imports.ClaimImport(ctx, staleAfter)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = im.run(ctx, job)
+		if err != nil {
+			return fmt.Errorf("import %d: %w", job.ID, err)
+		}
+	}
+
+	return ctx.Err()
+}
+
+// run processes a claimed job. A job interrupted by shutdown stays running
+// and is picked up again once it is stale; workouts created before are
+// then found as duplicates.
+func (im *Importer) run(ctx context.Context, job *store.ImportJob) error {
+	logger := im.logger.With("import_id", job.ID, "user_id", job.UserID)
+	logger.InfoContext(ctx, "import started", "format", job.Format, "dry_run", job.DryRun)
+
+	result, err := Parse(bytes.NewReader(job.Data), Format(job.Format), im.catalog)
+	if err != nil {
+		job.Status = store.ImportFailed
+		job.Error = err.Error()
+		return im.imports.FinishImport(ctx, job)
+	}
+
+	job.Format = string(result.Format)
+	job.TotalWorkouts = len(result.Workouts)
+	job.ProcessedWorkouts, job.CreatedWorkouts, job.DuplicateWorkouts, job.FailedWorkouts = 0, 0, 0, 0
+	job.Report = &store.ImportReport{
+		Errors:             result.Errors,
+		UnmatchedExercises: result.UnmatchedExercises,
+		Workouts:           make([]store.ImportWorkout, 0, len(result.Workouts)),
+	}
+	if job.Report.Errors == nil {
+		job.Report.Errors = []store.ImportRowError{}
+	}
+
+	seen := make(map[time.Time]bool)
+	for i := range result.Workouts {
+		parsed := &result.Workouts[i]
+
+		outcome, err := im.importWorkout(ctx, job, parsed, seen)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			job.Status = store.ImportFailed
+			job.Error = err.Error()
+			logger.ErrorContext(ctx, "import failed", "error", err)
+			return im.imports.FinishImport(context.WithoutCancel(ctx), job)
+		}
+
+		job.Report.Workouts = append(job.Report.Workouts, outcome)
+		job.ProcessedWorkouts++
+		switch outcome.Status {
+		case store.ImportWorkoutCreated:
+			job.CreatedWorkouts++
+		case store.ImportWorkoutDuplicate:
+			job.DuplicateWorkouts++
+		case store.ImportWorkoutInvalid, store.ImportWorkoutConflict:
+			job.FailedWorkouts++
+		}
+
+		if job.ProcessedWorkouts%progressEvery == 0 {
+			err = im.imports.UpdateImportProgress(ctx, job)
+			if err != nil {
+				logger.WarnContext(ctx, "update import progress", "error", err)
+			}
+		}
+	}
+
+	job.Status = store.ImportCompleted
+	logger.InfoContext(ctx, "import completed", "created", job.CreatedWorkouts, "duplicates", job.DuplicateWorkouts, "failed", job.FailedWorkouts)
+	return im.imports.FinishImport(ctx, job)
+}
+
+// importWorkout checks one parsed workout and, unless the job is a dry run,
+// saves it. Only unexpected errors are returned; invalid, duplicate and
+// conflicting workouts are reported in the outcome.
+func (im *Importer) importWorkout(ctx context.Context, job *store.ImportJob, parsed *ParsedWorkout, seen map[time.Time]bool) (store.ImportWorkout, error) {
+	workout := &parsed.Workout
+	workout.UserID = job.UserID
+
+	outcome := store.ImportWorkout{
+		Title:       workout.Title,
+		PerformedAt: workout.PerformedAt,
+		Entries:     len(workout.Entries),
+		Rows:        parsed.Rows,
+	}
+
+	v := validator.New()
+	validator.Workout(v, workout)
+
+	var validationErr *store.ValidationError
+	if err := v.Err(); errors.As(err, &validationErr) {
+		outcome.Status = store.ImportWorkoutInvalid
+		outcome.Errors = validationErr.Fields
+		return outcome, nil
+	}
+
+	minute := workout.PerformedAt.Truncate(time.Minute)
+	duplicate := seen[minute]
+	seen[minute] = true
+	if !duplicate {
+		var err error
+		duplicate, err = im.workouts.HasWorkoutAt(ctx, job.UserID, workout.PerformedAt)
+		if err != nil {
+			return outcome, err
+		}
+	}
+	if duplicate {
+		outcome.Status = store.ImportWorkoutDuplicate
+		return outcome, nil
+	}
+
+	if job.DryRun {
+		outcome.Status = store.ImportWorkoutNew
+		return outcome, nil
+	}
+
+	previous := records.Previous(ctx, im.logger, im.workouts, workout)
+
+	created, err := im.create(ctx, workout)
+	if errors.As(err, &validationErr) {
+		outcome.Status = store.ImportWorkoutInvalid
+		outcome.Errors = validationErr.Fields
+		return outcome, nil
+	}
+	var conflictErr *store.ConflictError
+	if errors.As(err, &conflictErr) {
+		outcome.Status = store.ImportWorkoutConflict
+		outcome.Errors = []store.FieldError{{Field: conflictErr.Field, Code: "conflict", Message: conflictErr.Error()}}
+		return outcome, nil
+	}
+	if err != nil {
+		return outcome, err
+	}
+
+	metrics.WorkoutsCreated.Inc()
+	records.Record(previous, created)
+	outcome.Status = store.ImportWorkoutCreated
+	outcome.Title = created.Title
+	outcome.WorkoutID = created.ID
+	return outcome, nil
+}
+
+// create saves workout, numbering its title when another workout has it.
+func (im *Importer) create(ctx context.Context, workout *store.Workout) (*store.Workout, error) {
+	var created *store.Workout
+	err := store.SaveWithNumberedTitle(workout, store.TitleAttempts, func() error {
+		var err error
+		created, err = im.workouts.CreateWorkout(ctx, workout)
+		return err
+	})
+	return created, err
+}
@@ -0,0 +1,114 @@
+package imports
+
+import (
+	"context"
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/catalog"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// fakeWorkoutStore keeps titles unique per user, like workouts_title_key.
+type fakeWorkoutStore struct {
+	store.WorkoutStore
+	titles map[int]map[string]bool
+	nextID int
+}
+
+func (s *fakeWorkoutStore) CreateWorkout(ctx context.Context, workout *store.Workout) (*store.Workout, error) {
+	if s.titles[workout.UserID][workout.Title] {
+		return nil, &store.ConflictError{Constraint: "workouts_title_key", Field: "title"}
+	}
+	if s.titles[workout.UserID] == nil {
+		s.titles[workout.UserID] = map[string]bool{}
+	}
+	s.titles[workout.UserID][workout.Title] = true
+
+	s.nextID++
+	created := *workout
+	created.ID = s.nextID
+	return &created, nil
+}
+
+func (s *fakeWorkoutStore) HasWorkoutAt(ctx context.Context, userID int, performedAt time.Time) (bool, error) {
+	return false, nil
+}
+
+func (s *fakeWorkoutStore) BestWeights(ctx context.Context, userID int, exercises []string) (map[string]float64, error) {
+	return nil, nil
+}
+
+type fakeImportStore struct {
+	store.ImportStore
+	finished *store.ImportJob
+}
+
+func (s *fakeImportStore) UpdateImportProgress(ctx context.Context, job *store.ImportJob) error {
+	return nil
+}
+
+func (s *fakeImportStore) FinishImport(ctx context.Context, job *store.ImportJob) error {
+	s.finished = job
+	return nil
+}
+
+func TestImporterTitleConflicts(t *testing.T) {
+	csv := `date,title,exercise,sets,reps,weight,duration_seconds,notes,duration_minutes,calories_burned,description
+2024-01-22 18:00,Push Day,Bench Press,3,10,80,,,60,350,
+2024-01-23 18:00,Legs,Squat,3,5,100,,,60,350,
+2024-01-24 18:00,Pull Day,Deadlift,3,5,140,,,60,350,
+`
+	taken := func(title string, numbers int) map[string]bool {
+		titles := map[string]bool{title: true}
+		for n := 2; n <= numbers; n++ {
+			titles[fmt.Sprintf("%s #%d", title, n)] = true
+		}
+		return titles
+	}
+
+	// Push Day is taken store.TitleAttempts times over, Legs once and Pull Day
+	// only by another user
+	workouts := &fakeWorkoutStore{titles: map[int]map[string]bool{
+		1: merge(taken("Push Day (2024-01-22 18:00)", store.TitleAttempts), taken("Legs (2024-01-23 18:00)", 1)),
+		2: taken("Pull Day (2024-01-24 18:00)", 1),
+	}}
+	imports := &fakeImportStore{}
+	importer := NewImporter(imports, workouts, catalog.Default(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	err := importer.run(context.Background(), &store.ImportJob{ID: 1, UserID: 1, Format: string(FormatAuto), Data: []byte(csv)})
+	require.NoError(t, err)
+
+	job := imports.finished
+	require.NotNil(t, job)
+	assert.Equal(t, store.ImportCompleted, job.Status)
+	assert.Equal(t, 3, job.ProcessedWorkouts)
+	assert.Equal(t, 2, job.CreatedWorkouts)
+	assert.Equal(t, 1, job.FailedWorkouts)
+
+	outcomes := job.Report.Workouts
+	require.Len(t, outcomes, 3)
+	assert.Equal(t, store.ImportWorkoutConflict, outcomes[0].Status)
+	require.Len(t, outcomes[0].Errors, 1)
+	assert.Equal(t, "title", outcomes[0].Errors[0].Field)
+
+	assert.Equal(t, store.ImportWorkoutCreated, outcomes[1].Status)
+	assert.Equal(t, "Legs (2024-01-23 18:00) #2", outcomes[1].Title)
+
+	assert.Equal(t, store.ImportWorkoutCreated, outcomes[2].Status)
+	assert.Equal(t, "Pull Day (2024-01-24 18:00)", outcomes[2].Title)
+}
+
+func merge(sets ...map[string]bool) map[string]bool {
+	merged := map[string]bool{}
+	for _, set := range sets {
+		for k := range set {
+			merged[k] = true
+		}
+	}
+	return merged
+}
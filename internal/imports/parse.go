@@ -0,0 +1,582 @@
+// Package imports turns CSV exports into workouts.
+//
+// Three layouts are understood, detected from the header row:
+//
+// The generic format has one row per entry. Rows with the same date and
+// title form one workout:
+//
+//	date,title,exercise,sets,reps,weight,duration_seconds,notes,duration_minutes,calories_burned,description
+//	2024-01-22 18:00,Push Day,Bench Press,3,10,80,,,60,350,
+//	2024-01-22 18:00,Push Day,Plank,3,,,60,,60,350,
+//
+// date, title and exercise are required; date is RFC 3339, "2006-01-02 15:04"
+// or "2006-01-02". sets defaults to 1. Exactly one of reps and
+// duration_seconds must be set. The optional distance and distance_unit (m,
+// km or mi, km by default) columns make a timed row a cardio entry.
+//
+// The Strong and Hevy app exports have one row per set. Consecutive sets of
+// an exercise with the same reps, weight and duration become one entry.
+//
+// Times without a zone are read as UTC. Weights are imported as given,
+// except the weight_lbs column of Hevy exports set to pounds, which is
+// converted to kilograms.
+package imports
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/catalog"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format string
+
+// kilogramsPerPound converts the pounds of weight_lbs columns.
+const kilogramsPerPound = 0.45359237
+
+const (
+	FormatAuto    Format = "auto"
+	FormatGeneric Format = "generic"
+	FormatStrong  Format = "strong"
+	FormatHevy    Format = "hevy"
+)
+
+// ErrUnknownFormat is returned for a CSV whose header matches no layout.
+var ErrUnknownFormat = errors.New("unrecognized CSV layout")
+
+// ParsedWorkout is a workout read from the file, with the rows it came from.
+type ParsedWorkout struct {
+	Workout store.Workout
+	Rows    []int
+}
+
+type Result struct {
+	Format             Format
+	Workouts           []ParsedWorkout
+	Errors             []store.ImportRowError
+	UnmatchedExercises []string
+}
+
+// setRow is one CSV row in a layout independent form.
+type setRow struct {
+	when            time.Time
+	workout         string
+	description     string
+	durationMinutes int
+	calories        int
+	exercise        string
+	sets            int
+	reps            *int
+	seconds         *int
+	weight          *float64
+	distance        *float64
+	distanceUnit    string
+	notes           string
+}
+
+// layout reads the rows of one export format. get returns the trimmed value
+// of a column by its lower case header name. A nil row with no error is
+// skipped silently.
+type layout struct {
+	required []string
+	parse    func(get func(string) string) (*setRow, error)
+}
+
+var layouts = map[Format]layout{
+	FormatGeneric: {
+		required: []string{"date", "title", "exercise"},
+		parse:    parseGenericRow,
+	},
+	FormatStrong: {
+		required: []string{"date", "workout name", "exercise name"},
+		parse:    parseStrongRow,
+	},
+	FormatHevy: {
+		required: []string{"title", "start_time", "exercise_title"},
+		parse:    parseHevyRow,
+	},
+}
+
+// detectFormat picks the layout whose required columns are all present.
+// Strong and Hevy are checked first, their headers are the most specific.
+func detectFormat(columns map[string]int) (Format, error) {
+	for _, format := range []Format{FormatStrong, FormatHevy, FormatGeneric} {
+		if hasColumns(columns, layouts[format].required) {
+			return format, nil
+		}
+	}
+	return "", ErrUnknownFormat
+}
+
+func hasColumns(columns map[string]int, names []string) bool {
+	for _, name := range names {
+		if _, ok := columns[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse reads a CSV export in format, or the detected one for FormatAuto.
+// Exercise names are replaced by their catalog name when they match. Rows
+// that cannot be read are reported in Result.Errors and skipped; only an
+// unreadable file is an error.
+func Parse(r io.Reader, format Format, exercises *catalog.Catalog) (*Result, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+
+	if format == FormatAuto {
+		format, err = detectFormat(columns)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	l, ok := layouts[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+	if !hasColumns(columns, l.required) {
+		return nil, fmt.Errorf("%s CSV must have the columns %s", format, strings.Join(l.required, ", "))
+	}
+
+	result := &Result{Format: format}
+	builder := newWorkoutBuilder(exercises)
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			result.Errors = append(result.Errors, store.ImportRowError{Row: parseErr.Line, Message: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		get := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		row, err := l.parse(get)
+		if err != nil {
+			result.Errors = append(result.Errors, store.ImportRowError{Row: line, Message: err.Error()})
+			continue
+		}
+		if row == nil {
+			continue
+		}
+
+		builder.add(line, row)
+	}
+
+	result.Workouts = builder.workouts()
+	result.UnmatchedExercises = builder.unmatchedExercises()
+
+	return result, nil
+}
+
+func parseGenericRow(get func(string) string) (*setRow, error) {
+	row := &setRow{
+		workout:     get("title"),
+		exercise:    get("exercise"),
+		notes:       get("notes"),
+		description: get("description"),
+		sets:        1,
+	}
+
+	var err error
+	if row.when, err = parseTime(get("date")); err != nil {
+		return nil, err
+	}
+	if row.sets, err = parseInt("sets", get("sets"), 1); err != nil {
+		return nil, err
+	}
+	if row.durationMinutes, err = parseInt("duration_minutes", get("duration_minutes"), 0); err != nil {
+		return nil, err
+	}
+	if row.calories, err = parseInt("calories_burned", get("calories_burned"), 0); err != nil {
+		return nil, err
+	}
+	if row.reps, err = parseOptionalInt("reps", get("reps")); err != nil {
+		return nil, err
+	}
+	if row.seconds, err = parseOptionalInt("duration_seconds", get("duration_seconds")); err != nil {
+		return nil, err
+	}
+	if row.weight, err = parseOptionalFloat("weight", get("weight")); err != nil {
+		return nil, err
+	}
+	if row.distance, err = parseOptionalFloat("distance", get("distance")); err != nil {
+		return nil, err
+	}
+	row.distanceUnit = strings.ToLower(get("distance_unit"))
+
+	return row, row.check()
+}
+
+// Strong: Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Reps,
+// Distance,Seconds,Notes,Workout Notes,RPE
+func parseStrongRow(get func(string) string) (*setRow, error) {
+	// rest timers and notes are exported as rows of their own
+	if order := get("set order"); order == "Rest Timer" || order == "Note" {
+		return nil, nil
+	}
+
+	row := &setRow{
+		workout:     get("workout name"),
+		exercise:    get("exercise name"),
+		notes:       get("notes"),
+		description: get("workout notes"),
+		sets:        1,
+	}
+
+	var err error
+	if row.when, err = parseTime(get("date")); err != nil {
+		return nil, err
+	}
+	if row.durationMinutes, err = parseStrongDuration(get("duration")); err != nil {
+		return nil, err
+	}
+	if row.reps, err = parseOptionalInt("reps", get("reps")); err != nil {
+		return nil, err
+	}
+	if row.seconds, err = parseOptionalInt("seconds", get("seconds")); err != nil {
+		return nil, err
+	}
+	if row.weight, err = parseOptionalFloat("weight", get("weight")); err != nil {
+		return nil, err
+	}
+	if row.distance, err = parseOptionalFloat("distance", get("distance")); err != nil {
+		return nil, err
+	}
+	// newer exports name the unit, older ones use the app setting, km by
+	// default
+	row.distanceUnit = strings.ToLower(get("distance unit"))
+
+	return row, row.check()
+}
+
+// parseStrongDuration reads "1h 5m" style durations, or plain seconds in
+// newer exports, as minutes.
+func parseStrongDuration(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	if seconds, err := strconv.Atoi(s); err == nil {
+		return int(math.Round(float64(seconds) / 60)), nil
+	}
+
+	d, err := time.ParseDuration(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return int(math.Round(d.Minutes())), nil
+}
+
+// Hevy: title,start_time,end_time,description,exercise_title,superset_id,
+// exercise_notes,set_index,set_type,weight_kg,reps,distance_km,
+// duration_seconds,rpe
+func parseHevyRow(get func(string) string) (*setRow, error) {
+	row := &setRow{
+		workout:     get("title"),
+		exercise:    get("exercise_title"),
+		notes:       get("exercise_notes"),
+		description: get("description"),
+		sets:        1,
+	}
+
+	var err error
+	if row.when, err = parseTime(get("start_time")); err != nil {
+		return nil, err
+	}
+	if end := get("end_time"); end != "" {
+		endTime, err := parseTime(end)
+		if err != nil {
+			return nil, err
+		}
+		row.durationMinutes = int(math.Round(endTime.Sub(row.when).Minutes()))
+	}
+	if row.reps, err = parseOptionalInt("reps", get("reps")); err != nil {
+		return nil, err
+	}
+	if row.seconds, err = parseOptionalInt("duration_seconds", get("duration_seconds")); err != nil {
+		return nil, err
+	}
+
+	if row.weight, err = parseOptionalFloat("weight_kg", get("weight_kg")); err != nil {
+		return nil, err
+	}
+	if row.weight == nil {
+		if row.weight, err = parseOptionalFloat("weight_lbs", get("weight_lbs")); err != nil {
+			return nil, err
+		}
+		if row.weight != nil {
+			*row.weight = math.Round(*row.weight*kilogramsPerPound*100) / 100
+		}
+	}
+
+	distance, unit := get("distance_km"), store.UnitKilometers
+	if distance == "" {
+		distance, unit = get("distance_miles"), store.UnitMiles
+	}
+	if row.distance, err = parseOptionalFloat("distance", distance); err != nil {
+		return nil, err
+	}
+	row.distanceUnit = unit
+
+	return row, row.check()
+}
+
+// check applies the rules every layout shares.
+func (row *setRow) check() error {
+	switch {
+	case row.workout == "":
+		return errors.New("workout title is missing")
+	case row.exercise == "":
+		return errors.New("exercise name is missing")
+	case row.sets < 1:
+		return errors.New("sets must be at least 1")
+	}
+
+	// a distance covered in a time is cardio, other distances, e.g. of a
+	// loaded carry done for reps, are dropped
+	if row.distance != nil && row.seconds != nil {
+		row.reps = nil
+		switch row.distanceUnit {
+		case "", "kilometers":
+			row.distanceUnit = store.UnitKilometers
+		case "meters":
+			row.distanceUnit = store.UnitMeters
+		case "miles":
+			row.distanceUnit = store.UnitMiles
+		}
+		if _, ok := store.DistanceMeters(*row.distance, row.distanceUnit); !ok {
+			return fmt.Errorf("unknown distance unit %q", row.distanceUnit)
+		}
+	} else {
+		row.distance, row.distanceUnit = nil, ""
+	}
+
+	// sets with both, e.g. timed reps, keep the reps
+	if row.reps != nil {
+		row.seconds = nil
+	}
+	if row.reps == nil && row.seconds == nil {
+		return errors.New("set has neither reps nor a duration")
+	}
+
+	return nil
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2 Jan 2006, 15:04",
+	"2 Jan 2006 15:04",
+}
+
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+func parseInt(field, s string, fallback int) (int, error) {
+	n, err := parseOptionalInt(field, s)
+	if err != nil || n == nil {
+		return fallback, err
+	}
+	return *n, nil
+}
+
+// parseOptionalInt returns nil for empty or zero values. Apps export whole
+// numbers as "10.0" too.
+func parseOptionalInt(field, s string) (*int, error) {
+	f, err := parseOptionalFloat(field, s)
+	if err != nil || f == nil {
+		return nil, err
+	}
+	if *f != math.Trunc(*f) {
+		return nil, fmt.Errorf("%s must be a whole number, got %q", field, s)
+	}
+
+	n := int(*f)
+	return &n, nil
+}
+
+func parseOptionalFloat(field, s string) (*float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number, got %q", field, s)
+	}
+	if f == 0 {
+		return nil, nil
+	}
+	if f < 0 {
+		return nil, fmt.Errorf("%s must not be negative, got %q", field, s)
+	}
+	return &f, nil
+}
+
+// workoutBuilder groups rows into workouts in the order they first appear.
+type workoutBuilder struct {
+	exercises *catalog.Catalog
+	byKey     map[string]*ParsedWorkout
+	order     []*ParsedWorkout
+	unmatched map[string]bool
+}
+
+func newWorkoutBuilder(exercises *catalog.Catalog) *workoutBuilder {
+	return &workoutBuilder{
+		exercises: exercises,
+		byKey:     make(map[string]*ParsedWorkout),
+		unmatched: make(map[string]bool),
+	}
+}
+
+func (b *workoutBuilder) add(line int, row *setRow) {
+	key := row.when.Format(time.RFC3339) + "\x00" + row.workout
+
+	parsed, ok := b.byKey[key]
+	if !ok {
+		parsed = &ParsedWorkout{
+			Workout: store.Workout{
+				Title:           title(row.workout, row.when),
+				Description:     row.description,
+				DurationMinutes: row.durationMinutes,
+				CaloriesBurned:  row.calories,
+				PerformedAt:     row.when,
+				Entries:         []store.WorkoutEntries{},
+			},
+		}
+		b.byKey[key] = parsed
+		b.order = append(b.order, parsed)
+	}
+	parsed.Rows = append(parsed.Rows, line)
+
+	name := row.exercise
+	if exercise, ok := b.exercises.Match(name); ok {
+		name = exercise.Name
+	} else {
+		b.unmatched[name] = true
+	}
+
+	entries := parsed.Workout.Entries
+	if n := len(entries); n > 0 {
+		last := &entries[n-1]
+		if last.ExerciseName == name && last.Notes == row.notes && equal(last.Reps, row.reps) &&
+			equal(last.DurationSeconds, row.seconds) && equal(last.Weight, row.weight) && row.distance == nil && last.Distance == nil {
+			last.Sets += row.sets
+			return
+		}
+	}
+
+	entryType := store.EntryStrength
+	if row.distance != nil {
+		entryType = store.EntryCardio
+	}
+
+	parsed.Workout.Entries = append(entries, store.WorkoutEntries{
+		Type:            entryType,
+		ExerciseName:    name,
+		Sets:            row.sets,
+		Reps:            row.reps,
+		DurationSeconds: row.seconds,
+		Weight:          row.weight,
+		Distance:        row.distance,
+		DistanceUnit:    row.distanceUnit,
+		Notes:           row.notes,
+		OrderIndex:      len(entries),
+	})
+}
+
+func (b *workoutBuilder) workouts() []ParsedWorkout {
+	workouts := make([]ParsedWorkout, 0, len(b.order))
+	for _, parsed := range b.order {
+		workout := &parsed.Workout
+
+		// exports without a duration get at least the time of their timed sets
+		if workout.DurationMinutes < 1 {
+			seconds := 0
+			for _, entry := range workout.Entries {
+				if entry.DurationSeconds != nil {
+					seconds += *entry.DurationSeconds * entry.Sets
+				}
+			}
+			workout.DurationMinutes = max(1, int(math.Ceil(float64(seconds)/60)))
+		}
+
+		workouts = append(workouts, *parsed)
+	}
+	return workouts
+}
+
+func (b *workoutBuilder) unmatchedExercises() []string {
+	names := make([]string, 0, len(b.unmatched))
+	for name := range b.unmatched {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// title makes the title unique per workout: apps reuse names like
+// "Push Day", while titles are unique here.
+func title(name string, when time.Time) string {
+	suffix := " (" + when.Format("2006-01-02 15:04") + ")"
+
+	runes := []rune(name)
+	if limit := 255 - len(suffix); len(runes) > limit {
+		runes = runes[:limit]
+	}
+	return string(runes) + suffix
+}
+
+func equal[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,140 @@
+package imports
+
+import (
+	"github.com/oki-irawan/fem_project/internal/catalog"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGeneric(t *testing.T) {
+	csv := `date,title,exercise,sets,reps,weight,duration_seconds,notes,duration_minutes,calories_burned,description
+2024-01-22 18:00,Push Day,bench press (barbell),3,10,80,,,60,350,
+2024-01-22 18:00,Push Day,Plank,3,,,60,,60,350,
+2024-01-23,Legs,Squat,abc,5,100,,,,,
+2024-01-24,Legs,Squat,,,,,,,,
+`
+
+	result, err := Parse(strings.NewReader(csv), FormatAuto, catalog.Default())
+	require.NoError(t, err)
+
+	assert.Equal(t, FormatGeneric, result.Format)
+	require.Len(t, result.Workouts, 1)
+	require.Len(t, result.Errors, 2)
+	assert.Equal(t, 4, result.Errors[0].Row)
+	assert.Equal(t, 5, result.Errors[1].Row)
+
+	parsed := result.Workouts[0]
+	assert.Equal(t, []int{2, 3}, parsed.Rows)
+	assert.Equal(t, "Push Day (2024-01-22 18:00)", parsed.Workout.Title)
+	assert.Equal(t, time.Date(2024, 1, 22, 18, 0, 0, 0, time.UTC), parsed.Workout.PerformedAt)
+	assert.Equal(t, 60, parsed.Workout.DurationMinutes)
+	assert.Equal(t, 350, parsed.Workout.CaloriesBurned)
+
+	require.Len(t, parsed.Workout.Entries, 2)
+	assert.Equal(t, "Bench Press", parsed.Workout.Entries[0].ExerciseName)
+	assert.Equal(t, 3, parsed.Workout.Entries[0].Sets)
+	assert.Equal(t, 10, *parsed.Workout.Entries[0].Reps)
+	assert.Equal(t, 80.0, *parsed.Workout.Entries[0].Weight)
+	assert.Equal(t, 60, *parsed.Workout.Entries[1].DurationSeconds)
+	assert.Equal(t, 1, parsed.Workout.Entries[1].OrderIndex)
+}
+
+func TestParseStrong(t *testing.T) {
+	csv := "\ufeffDate,Workout Name,Duration,Exercise Name,Set Order,Weight,Reps,Distance,Seconds,Notes,Workout Notes,RPE\n" +
+		"2023-05-01 07:30:00,Morning,1h 5m,Squat (Barbell),1,100,5,0,0,,,\n" +
+		"2023-05-01 07:30:00,Morning,1h 5m,Squat (Barbell),2,100,5,0,0,,,\n" +
+		"2023-05-01 07:30:00,Morning,1h 5m,Squat (Barbell),Rest Timer,0,0,0,90,,,\n" +
+		"2023-05-01 07:30:00,Morning,1h 5m,Squat (Barbell),3,105,3,0,0,,,\n" +
+		"2023-05-01 07:30:00,Morning,1h 5m,Zercher Carry,1,60,0,0,30,,,\n"
+
+	result, err := Parse(strings.NewReader(csv), FormatAuto, catalog.Default())
+	require.NoError(t, err)
+
+	assert.Equal(t, FormatStrong, result.Format)
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, []string{"Zercher Carry"}, result.UnmatchedExercises)
+	require.Len(t, result.Workouts, 1)
+
+	workout := result.Workouts[0].Workout
+	assert.Equal(t, 65, workout.DurationMinutes)
+	require.Len(t, workout.Entries, 3)
+	assert.Equal(t, "Squat", workout.Entries[0].ExerciseName)
+	assert.Equal(t, 2, workout.Entries[0].Sets)
+	assert.Equal(t, 105.0, *workout.Entries[1].Weight)
+	assert.Nil(t, workout.Entries[2].Reps)
+	assert.Equal(t, 30, *workout.Entries[2].DurationSeconds)
+}
+
+func TestParseHevy(t *testing.T) {
+	csv := `title,start_time,end_time,description,exercise_title,superset_id,exercise_notes,set_index,set_type,weight_kg,reps,distance_km,duration_seconds,rpe
+Pull,2023-06-10T17:00:00Z,2023-06-10T17:45:00Z,,Lat Pulldown (Cable),,,0,normal,55,12,,,
+Pull,2023-06-10T17:00:00Z,2023-06-10T17:45:00Z,,Lat Pulldown (Cable),,,1,normal,55,12,,,
+`
+
+	result, err := Parse(strings.NewReader(csv), FormatHevy, catalog.Default())
+	require.NoError(t, err)
+
+	require.Len(t, result.Workouts, 1)
+	workout := result.Workouts[0].Workout
+	assert.Equal(t, 45, workout.DurationMinutes)
+	require.Len(t, workout.Entries, 1)
+	assert.Equal(t, "Lat Pulldown", workout.Entries[0].ExerciseName)
+	assert.Equal(t, 2, workout.Entries[0].Sets)
+}
+
+func TestParseHevyPounds(t *testing.T) {
+	csv := `title,start_time,end_time,description,exercise_title,superset_id,exercise_notes,set_index,set_type,weight_lbs,reps,distance_miles,duration_seconds,rpe
+Pull,2023-06-10T17:00:00Z,2023-06-10T17:45:00Z,,Lat Pulldown (Cable),,,0,normal,120,12,,,
+Pull,2023-06-10T17:00:00Z,2023-06-10T17:45:00Z,,Lat Pulldown (Cable),,,1,normal,135,10,,,
+`
+
+	result, err := Parse(strings.NewReader(csv), FormatHevy, catalog.Default())
+	require.NoError(t, err)
+
+	require.Len(t, result.Workouts, 1)
+	workout := result.Workouts[0].Workout
+	require.Len(t, workout.Entries, 2)
+	// 120 lbs and 135 lbs in kilograms
+	assert.Equal(t, 54.43, *workout.Entries[0].Weight)
+	assert.Equal(t, 61.23, *workout.Entries[1].Weight)
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	_, err := Parse(strings.NewReader("a,b,c\n1,2,3\n"), FormatAuto, catalog.Default())
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+
+	_, err = Parse(strings.NewReader("a,b,c\n1,2,3\n"), FormatStrong, catalog.Default())
+	assert.Error(t, err)
+}
+
+func TestParseCardio(t *testing.T) {
+	csv := `date,title,exercise,sets,reps,weight,duration_seconds,distance,distance_unit
+2024-02-01 07:00,Easy Run,Running,1,,,1800,5,
+2024-02-01 07:00,Easy Run,Farmer Carry,3,1,40,,20,m
+2024-02-02 07:00,Track,Running,1,,,600,3,mi
+2024-02-03 07:00,Track,Running,1,,,600,3,yd
+`
+
+	result, err := Parse(strings.NewReader(csv), FormatGeneric, catalog.Default())
+	require.NoError(t, err)
+
+	require.Len(t, result.Errors, 1)
+	assert.Equal(t, 5, result.Errors[0].Row)
+	require.Len(t, result.Workouts, 2)
+
+	run := result.Workouts[0].Workout.Entries[0]
+	assert.Equal(t, store.EntryCardio, run.Type)
+	assert.Equal(t, 5.0, *run.Distance)
+	assert.Equal(t, store.UnitKilometers, run.DistanceUnit)
+
+	// a distance without a duration is not cardio
+	carry := result.Workouts[0].Workout.Entries[1]
+	assert.Equal(t, store.EntryStrength, carry.Type)
+	assert.Nil(t, carry.Distance)
+
+	assert.Equal(t, store.UnitMiles, result.Workouts[1].Workout.Entries[0].DistanceUnit)
+}
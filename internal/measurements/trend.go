@@ -0,0 +1,78 @@
+// Package measurements smooths body measurements into trends. Day to day
+// scale readings swing with water and food; the trend shows where they are
+// heading.
+package measurements
+
+import (
+	"github.com/oki-irawan/fem_project/internal/store"
+	"math"
+)
+
+// Smoothing is the weight of a new reading in the exponential moving
+// average, per day since the last one. 0.1 follows The Hacker's Diet.
+const Smoothing = 0.1
+
+// Point is a measurement with the trend of each value it records and the
+// change of that trend over a week.
+type Point struct {
+	*store.BodyMeasurement
+	Trend        store.MeasurementValues `json:"trend"`
+	WeeklyChange store.MeasurementValues `json:"weekly_change"`
+}
+
+// history is what the trend of one value looked like on a day.
+type history struct {
+	day   int
+	trend float64
+}
+
+// Trend returns a point for each of measurements, which must be sorted by
+// day. Each value is smoothed separately, so a measurement only has the
+// trends of the values it records. The weekly change compares a trend with
+// the last trend at least 7 days older and is scaled to 7 days.
+func Trend(measurements []*store.BodyMeasurement) []Point {
+	points := make([]Point, len(measurements))
+	histories := map[string][]history{}
+
+	for i, m := range measurements {
+		points[i].BodyMeasurement = m
+		day := int(m.MeasuredOn.Unix() / 86400)
+
+		values := m.MeasurementValues
+		trends := points[i].Trend.Fields()
+		changes := points[i].WeeklyChange.Fields()
+
+		for j, field := range values.Fields() {
+			if *field.Value == nil {
+				continue
+			}
+			value := **field.Value
+
+			past := histories[field.Name]
+			trend := value
+			if len(past) > 0 {
+				last := past[len(past)-1]
+				alpha := 1 - math.Pow(1-Smoothing, float64(day-last.day))
+				trend = last.trend + alpha*(value-last.trend)
+			}
+			histories[field.Name] = append(past, history{day: day, trend: trend})
+
+			rounded := round(trend)
+			*trends[j].Value = &rounded
+
+			for k := len(past) - 1; k >= 0; k-- {
+				if days := day - past[k].day; days >= 7 {
+					change := round((trend - past[k].trend) / float64(days) * 7)
+					*changes[j].Value = &change
+					break
+				}
+			}
+		}
+	}
+
+	return points
+}
+
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
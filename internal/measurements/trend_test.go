@@ -0,0 +1,52 @@
+package measurements
+
+import (
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func measurement(day int, weight, waist *float64) *store.BodyMeasurement {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	return &store.BodyMeasurement{
+		MeasuredOn:        store.NewDate(start.AddDate(0, 0, day)),
+		MeasurementValues: store.MeasurementValues{BodyWeightKg: weight, WaistCm: waist},
+	}
+}
+
+func TestTrend(t *testing.T) {
+	points := Trend([]*store.BodyMeasurement{
+		measurement(0, floatPtr(80), floatPtr(90)),
+		measurement(1, floatPtr(81), nil),
+		// two days later, so the reading weighs 1 - 0.9² = 0.19
+		measurement(3, floatPtr(79), nil),
+		measurement(10, floatPtr(78), floatPtr(88)),
+	})
+	require.Len(t, points, 4)
+
+	// the first reading is the trend
+	assert.Equal(t, 80.0, *points[0].Trend.BodyWeightKg)
+	assert.Equal(t, 90.0, *points[0].Trend.WaistCm)
+	assert.Nil(t, points[0].WeeklyChange.BodyWeightKg)
+
+	assert.Equal(t, 80.1, *points[1].Trend.BodyWeightKg)
+	assert.Nil(t, points[1].Trend.WaistCm)
+
+	// 80.1 + 0.19 * (79 - 80.1)
+	assert.Equal(t, 79.89, *points[2].Trend.BodyWeightKg)
+
+	// 79.891 + 0.5217 * (78 - 79.891), compared with day 3, 7 days before
+	last := points[3]
+	assert.Equal(t, 78.9, *last.Trend.BodyWeightKg)
+	assert.Equal(t, -0.99, *last.WeeklyChange.BodyWeightKg)
+	// 90 + 0.6513 * (88 - 90) over 10 days, scaled to a week
+	assert.Equal(t, 88.7, *last.Trend.WaistCm)
+	assert.Equal(t, -0.91, *last.WeeklyChange.WaistCm)
+	assert.Nil(t, last.Trend.ChestCm)
+}
@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/utils"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// replayedHeaders are the response headers stored with an idempotent
+// response. Headers set by the middleware around it, such as the request id
+// or rate limits, belong to the retry and are left out.
+var replayedHeaders = []string{"Content-Type", "ETag", "Last-Modified", "Location"}
+
+type IdempotencyMiddleware struct {
+	Store store.IdempotencyStore
+	TTL   time.Duration
+	// Lease is how long a request holds its key before a retry may take it
+	// over. It should be a little above the request timeout, so only
+	// requests that died without releasing their key lose it.
+	Lease  time.Duration
+	Logger *slog.Logger
+}
+
+// Handle makes mutating requests that carry an Idempotency-Key safe to retry.
+// The first response is stored per user and key and replayed for identical
+// retries. It must run after Authenticate.
+func (im *IdempotencyMiddleware) Handle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || !isMutating(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, ok := r.Context().Value(UserContextKey).(*store.User)
+		if !ok || user.IsAnonymous() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > 255 {
+			utils.WriteProblem(w, r, http.StatusBadRequest, utils.CodeBadRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			utils.WriteError(w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := time.Now()
+		record := &store.IdempotencyRecord{
+			UserID:      user.ID,
+			Key:         key,
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			RequestHash: requestHash(r, body),
+			ExpiresAt:   now.Add(im.TTL),
+			LockedUntil: now.Add(im.Lease),
+		}
+
+		existing, err := im.Store.Reserve(r.Context(), record)
+		if err != nil {
+			im.Logger.ErrorContext(r.Context(), "reserving idempotency key", "error", err)
+			utils.WriteError(w, r, err)
+			return
+		}
+
+		if existing != nil {
+			im.replay(w, r, record, existing)
+			return
+		}
+
+		im.serve(w, r, next, record)
+	})
+}
+
+func (im *IdempotencyMiddleware) replay(w http.ResponseWriter, r *http.Request, record, existing *store.IdempotencyRecord) {
+	if existing.Method != record.Method || existing.Path != record.Path || !bytes.Equal(existing.RequestHash, record.RequestHash) {
+		utils.WriteProblem(w, r, http.StatusUnprocessableEntity, utils.CodeIdempotencyKeyReused, "The Idempotency-Key was already used for a different request")
+		return
+	}
+
+	if !existing.Completed {
+		w.Header().Set("Retry-After", "1")
+		utils.WriteProblem(w, r, http.StatusConflict, utils.CodeIdempotencyInFlight, "A request with this Idempotency-Key is still being processed")
+		return
+	}
+
+	for name, values := range existing.Header {
+		w.Header()[http.CanonicalHeaderKey(name)] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(existing.StatusCode)
+	w.Write(existing.Body)
+}
+
+func (im *IdempotencyMiddleware) serve(w http.ResponseWriter, r *http.Request, next http.Handler, record *store.IdempotencyRecord) {
+	var buf bytes.Buffer
+	ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+	ww.Tee(&buf)
+
+	// the request context may already be cancelled or timed out here, the
+	// bookkeeping still has to happen
+	ctx := context.WithoutCancel(r.Context())
+	completed := false
+	defer func() {
+		if completed {
+			return
+		}
+		err := im.Store.Release(ctx, record.UserID, record.Key)
+		if err != nil {
+			im.Logger.ErrorContext(ctx, "releasing idempotency key", "error", err)
+		}
+	}()
+
+	next.ServeHTTP(ww, r)
+
+	status := ww.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	// server errors are not final, the client should be able to retry them
+	if status >= http.StatusInternalServerError {
+		return
+	}
+
+	record.StatusCode = status
+	record.Header = http.Header{}
+	for _, name := range replayedHeaders {
+		if values := ww.Header().Values(name); len(values) > 0 {
+			record.Header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	record.Body = buf.Bytes()
+
+	err := im.Store.Complete(ctx, record)
+	if err != nil {
+		im.Logger.ErrorContext(ctx, "storing idempotent response", "error", err)
+		return
+	}
+	completed = true
+}
+
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func requestHash(r *http.Request, body []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write([]byte(r.Header.Get("Content-Type") + "\n"))
+	h.Write([]byte(strconv.Itoa(len(body)) + "\n"))
+	h.Write(body)
+	return h.Sum(nil)
+}
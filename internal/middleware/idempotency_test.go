@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeIdempotencyStore keeps records in memory, one per user and key.
+type fakeIdempotencyStore struct {
+	records map[string]*store.IdempotencyRecord
+}
+
+func (s *fakeIdempotencyStore) Reserve(ctx context.Context, record *store.IdempotencyRecord) (*store.IdempotencyRecord, error) {
+	if existing, ok := s.records[record.Key]; ok && (existing.Completed || existing.LockedUntil.After(time.Now())) {
+		copied := *existing
+		return &copied, nil
+	}
+
+	reserved := *record
+	s.records[record.Key] = &reserved
+	return nil, nil
+}
+
+func (s *fakeIdempotencyStore) Complete(ctx context.Context, record *store.IdempotencyRecord) error {
+	completed := *record
+	completed.Completed = true
+	s.records[record.Key] = &completed
+	return nil
+}
+
+func (s *fakeIdempotencyStore) Release(ctx context.Context, userID int, key string) error {
+	delete(s.records, key)
+	return nil
+}
+
+func (s *fakeIdempotencyStore) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func TestIdempotency(t *testing.T) {
+	idempotencyStore := &fakeIdempotencyStore{records: map[string]*store.IdempotencyRecord{}}
+	im := &IdempotencyMiddleware{
+		Store:  idempotencyStore,
+		TTL:    time.Hour,
+		Lease:  time.Minute,
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	calls := 0
+	status := http.StatusCreated
+	handler := im.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"1"`)
+		w.Header().Set("Location", "/workouts/1")
+		w.Header().Set("X-Request-Id", "first")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"workout":{"id":1}}`))
+	}))
+
+	send := func(method, key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/workouts", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			r.Header.Set(IdempotencyKeyHeader, key)
+		}
+		r = SetUser(r, &store.User{ID: 1})
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	first := send(http.MethodPost, "create-push-day", `{"title":"Push Day"}`)
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, 1, calls)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	t.Run("Replays The Stored Response", func(t *testing.T) {
+		w := send(http.MethodPost, "create-push-day", `{"title":"Push Day"}`)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, first.Body.String(), w.Body.String())
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+		assert.Equal(t, "/workouts/1", w.Header().Get("Location"))
+		assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+		// not a header of the response itself
+		assert.Empty(t, w.Header().Get("X-Request-Id"))
+	})
+
+	t.Run("Rejects A Different Request", func(t *testing.T) {
+		w := send(http.MethodPost, "create-push-day", `{"title":"Leg Day"}`)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, "idempotency_key_reused", problemCode(t, w))
+
+		w = send(http.MethodPut, "create-push-day", `{"title":"Push Day"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("Request In Flight", func(t *testing.T) {
+		calls = 0
+		idempotencyStore.records["in-flight"] = &store.IdempotencyRecord{
+			UserID:      1,
+			Key:         "in-flight",
+			Method:      http.MethodPost,
+			Path:        "/workouts",
+			RequestHash: requestHash(httptest.NewRequest(http.MethodPost, "/workouts", nil), []byte(`{}`)),
+			LockedUntil: time.Now().Add(time.Minute),
+		}
+
+		r := httptest.NewRequest(http.MethodPost, "/workouts", strings.NewReader(`{}`))
+		r.Header.Set(IdempotencyKeyHeader, "in-flight")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, SetUser(r, &store.User{ID: 1}))
+
+		assert.Equal(t, 0, calls)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "idempotency_key_in_flight", problemCode(t, w))
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("Abandoned Request Is Taken Over", func(t *testing.T) {
+		calls = 0
+		// a request that died without completing or releasing its key
+		idempotencyStore.records["abandoned"] = &store.IdempotencyRecord{
+			UserID:      1,
+			Key:         "abandoned",
+			Method:      http.MethodPost,
+			Path:        "/workouts",
+			RequestHash: requestHash(httptest.NewRequest(http.MethodPost, "/workouts", nil), []byte(`{}`)),
+			LockedUntil: time.Now().Add(-time.Second),
+		}
+
+		before := time.Now()
+		w := send(http.MethodPost, "abandoned", `{"title":"Push Day"}`)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		record := idempotencyStore.records["abandoned"]
+		assert.True(t, record.Completed)
+		assert.WithinDuration(t, before.Add(time.Minute), record.LockedUntil, time.Second)
+
+		// the retry's response is the one replayed from now on
+		w = send(http.MethodPost, "abandoned", `{"title":"Push Day"}`)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	})
+
+	t.Run("Server Errors Can Be Retried", func(t *testing.T) {
+		calls = 0
+		status = http.StatusInternalServerError
+		defer func() { status = http.StatusCreated }()
+
+		send(http.MethodPost, "create-leg-day", `{"title":"Leg Day"}`)
+		assert.NotContains(t, idempotencyStore.records, "create-leg-day")
+		send(http.MethodPost, "create-leg-day", `{"title":"Leg Day"}`)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("Passes Through", func(t *testing.T) {
+		calls = 0
+		send(http.MethodPost, "", `{"title":"Push Day"}`)
+		send(http.MethodPost, "", `{"title":"Push Day"}`)
+		send(http.MethodGet, "create-push-day", "")
+		assert.Equal(t, 3, calls)
+	})
+}
+
+func problemCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	var problem struct {
+		Code string `json:"code"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	return problem.Code
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/oki-irawan/fem_project/internal/logging"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID reuses the X-Request-ID sent by the client or a proxy, or
+// generates a new one, and echoes it in the response.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := logging.WithRequestID(r.Context(), requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// accessInfo collects what inner handlers learn about the request, such as
+// the authenticated user, for the access log line.
+type accessInfo struct {
+	userID int
+}
+
+const accessInfoKey = contextKey("access_info")
+
+func setAccessUser(r *http.Request, userID int) {
+	if info, ok := r.Context().Value(accessInfoKey).(*accessInfo); ok {
+		info.userID = userID
+	}
+}
+
+// AccessLog writes one log line per request once it has been served.
+func AccessLog(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			info := &accessInfo{}
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), accessInfoKey, info)))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("route", chi.RouteContext(r.Context()).RoutePattern()),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Duration("latency", time.Since(start)),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.String("remote_addr", r.RemoteAddr),
+			}
+			if info.userID != 0 {
+				attrs = append(attrs, slog.Int("user_id", info.userID))
+			}
+
+			logger.LogAttrs(r.Context(), slog.LevelInfo, "request", attrs...)
+		})
+	}
+}
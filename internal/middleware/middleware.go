@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"github.com/oki-irawan/fem_project/internal/metrics"
 	"github.com/oki-irawan/fem_project/internal/store"
 	"github.com/oki-irawan/fem_project/internal/tokens"
 	"github.com/oki-irawan/fem_project/internal/utils"
@@ -18,6 +19,7 @@ type contextKey string
 const UserContextKey = contextKey("user")
 
 func SetUser(r *http.Request, user *store.User) *http.Request {
+	setAccessUser(r, user.ID)
 	ctx := context.WithValue(r.Context(), UserContextKey, user)
 	return r.WithContext(ctx)
 }
@@ -39,6 +41,7 @@ func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 
 		// not exist Authorization header
 		if authHeader == "" {
+			metrics.AuthAttempts.WithLabelValues(metrics.AuthAnonymous).Inc()
 			r = SetUser(r, store.AnonymousUser)
 			next.ServeHTTP(w, r)
 			return
@@ -47,23 +50,27 @@ func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 		splitToken := strings.Split(authHeader, " ") // Bearer <token> ---> need to split to get token
 
 		if len(splitToken) != 2 || splitToken[0] != "Bearer" {
-			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "Invalid authorization header"})
+			metrics.AuthAttempts.WithLabelValues(metrics.AuthFailure).Inc()
+			utils.WriteProblem(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "Invalid authorization header")
 			return
 		}
 
 		token := splitToken[1]
-		user, err := um.UserStore.GetUserToken(tokens.ScopeAuth, token)
+		user, err := um.UserStore.GetUserToken(r.Context(), tokens.ScopeAuth, token)
 
 		if err != nil {
-			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "Invalid token or user"})
+			metrics.AuthAttempts.WithLabelValues(metrics.AuthFailure).Inc()
+			utils.WriteProblem(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "Invalid token or user")
 			return
 		}
 
 		if user == nil {
-			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "Invalid token or user"})
+			metrics.AuthAttempts.WithLabelValues(metrics.AuthFailure).Inc()
+			utils.WriteProblem(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "Invalid token or user")
 			return
 		}
 
+		metrics.AuthAttempts.WithLabelValues(metrics.AuthSuccess).Inc()
 		r = SetUser(r, user)
 		next.ServeHTTP(w, r)
 		return
@@ -75,7 +82,7 @@ func (um *UserMiddleware) RequireUser(next http.HandlerFunc) http.HandlerFunc {
 		user := GetUser(r)
 
 		if user.IsAnonymous() {
-			utils.WriteJSON(w, http.StatusUnauthorized, utils.Envelope{"error": "You mush logged in to access this resource"})
+			utils.WriteProblem(w, r, http.StatusUnauthorized, utils.CodeUnauthorized, "You must be logged in to access this resource")
 			return
 		}
 
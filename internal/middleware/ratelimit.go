@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"github.com/oki-irawan/fem_project/internal/ratelimit"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/utils"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimit limits requests per authenticated user, or per client IP for
+// anonymous requests. When the limiter itself fails the request is let
+// through, an unavailable limiter shouldn't take the API down with it.
+func RateLimit(limiter ratelimit.Limiter, policy ratelimit.Policy, logger *slog.Logger) func(http.Handler) http.Handler {
+	return rateLimit(limiter, policy, logger, rateLimitKey)
+}
+
+// RateLimitIP limits requests per client IP whoever makes them. It goes
+// before Authenticate, so a flood of requests with made up tokens is turned
+// away before each of them costs a token lookup.
+func RateLimitIP(limiter ratelimit.Limiter, policy ratelimit.Policy, logger *slog.Logger) func(http.Handler) http.Handler {
+	return rateLimit(limiter, policy, logger, clientIPKey)
+}
+
+func rateLimit(limiter ratelimit.Limiter, policy ratelimit.Policy, logger *slog.Logger, key func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := limiter.Allow(r.Context(), key(r), policy)
+			if err != nil {
+				logger.ErrorContext(r.Context(), "rate limiter failed", "policy", policy.Name, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				utils.WriteProblem(w, r, http.StatusTooManyRequests, utils.CodeRateLimited, "Too many requests, retry later")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func rateLimitKey(r *http.Request) string {
+	if user, ok := r.Context().Value(UserContextKey).(*store.User); ok && !user.IsAnonymous() {
+		return "user:" + strconv.Itoa(user.ID)
+	}
+	return clientIPKey(r)
+}
+
+func clientIPKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"github.com/oki-irawan/fem_project/internal/ratelimit"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitKeys(t *testing.T) {
+	policy := ratelimit.Policy{Name: "test", Limit: 1, Period: time.Minute}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	send := func(handler http.Handler, remoteAddr string, user *store.User) int {
+		r := httptest.NewRequest(http.MethodGet, "/workouts/1", nil)
+		r.RemoteAddr = remoteAddr
+		if user != nil {
+			r = SetUser(r, user)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Run("Per User", func(t *testing.T) {
+		handler := RateLimit(ratelimit.NewMemoryLimiter(), policy, logger)(ok)
+
+		assert.Equal(t, http.StatusOK, send(handler, "192.0.2.1:1234", &store.User{ID: 1}))
+		// another user behind the same address has a bucket of their own
+		assert.Equal(t, http.StatusOK, send(handler, "192.0.2.1:1234", &store.User{ID: 2}))
+		assert.Equal(t, http.StatusTooManyRequests, send(handler, "192.0.2.9:1234", &store.User{ID: 1}))
+		// anonymous requests fall back to the address
+		assert.Equal(t, http.StatusOK, send(handler, "192.0.2.1:1234", nil))
+		assert.Equal(t, http.StatusTooManyRequests, send(handler, "192.0.2.1:4321", nil))
+	})
+
+	t.Run("Per IP", func(t *testing.T) {
+		handler := RateLimitIP(ratelimit.NewMemoryLimiter(), policy, logger)(ok)
+
+		assert.Equal(t, http.StatusOK, send(handler, "192.0.2.1:1234", &store.User{ID: 1}))
+		assert.Equal(t, http.StatusTooManyRequests, send(handler, "192.0.2.1:1234", &store.User{ID: 2}))
+		assert.Equal(t, http.StatusOK, send(handler, "192.0.2.9:1234", &store.User{ID: 1}))
+	})
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"github.com/oki-irawan/fem_project/internal/utils"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type CORSConfig struct {
+	// AllowedOrigins lists the exact origins allowed to call the API. "*"
+	// allows any origin, but then credentials are never allowed.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           int
+}
+
+// CORS answers preflight requests and adds the CORS headers for allowed
+// origins. Requests from other origins are served without them, so browsers
+// block the response.
+func CORS(cfg CORSConfig) func(http.Handler) http.Handler {
+	allowAny := slices.Contains(cfg.AllowedOrigins, "*")
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			if !allowAny && !slices.Contains(cfg.AllowedOrigins, origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if allowAny && !cfg.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if cfg.AllowCredentials && !allowAny {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			isPreflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if !isPreflight {
+				if exposed != "" {
+					w.Header().Set("Access-Control-Expose-Headers", exposed)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			w.Header().Set("Access-Control-Allow-Headers", headers)
+			if cfg.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// SecureHeaders sets the standard hardening headers. The API never serves
+// HTML, so the CSP forbids everything.
+func SecureHeaders(hsts bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			h.Set("Referrer-Policy", "no-referrer")
+			h.Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+			if hsts {
+				h.Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitedBody is a body capped by LimitBody. It keeps the original body so a
+// LimitBody further down the chain can replace the cap instead of adding a
+// second, smaller one.
+type limitedBody struct {
+	io.ReadCloser
+	original io.ReadCloser
+}
+
+// LimitBody caps request bodies at maxBytes. Reading past the limit fails
+// with *http.MaxBytesError, which utils.WriteError renders as 413. When
+// routes are nested, the innermost LimitBody wins, so a route such as an
+// upload can allow more than the server wide default.
+func LimitBody(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil {
+				body := r.Body
+				if limited, ok := body.(*limitedBody); ok {
+					body = limited.original
+				}
+				r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, body, maxBytes), original: body}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Recoverer turns a panic in a handler, such as GetUser called outside of
+// Authenticate, into a logged 500 instead of a dropped connection.
+func Recoverer(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					// the client is gone, let net/http handle it
+					panic(rec)
+				}
+
+				logger.ErrorContext(r.Context(), "panic serving request", "panic", rec, "stack", string(debug.Stack()))
+				utils.WriteProblem(w, r, http.StatusInternalServerError, utils.CodeInternalError, "Internal server error")
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// MemoryLimiter keeps the buckets in process memory. Limits are per replica,
+// so use the Postgres limiter when running more than one.
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (m *MemoryLimiter) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	key = policy.Name + ":" + key
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(policy.Limit), updated: now, period: policy.Period}
+		m.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(policy.Limit), b.tokens+elapsed*policy.ratePerSecond())
+	b.updated = now
+
+	if b.tokens < 1 {
+		return newResult(policy, false, b.tokens), nil
+	}
+
+	b.tokens--
+	return newResult(policy, true, b.tokens), nil
+}
+
+// sweep drops buckets that have been idle long enough to be full again, so
+// the map doesn't grow with every client ever seen.
+func (m *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+
+	for key, b := range m.buckets {
+		if now.Sub(b.updated) > b.period {
+			delete(m.buckets, key)
+		}
+	}
+}
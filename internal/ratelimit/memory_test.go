@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewMemoryLimiter()
+	limiter.now = func() time.Time { return now }
+
+	policy := Policy{Name: "test", Limit: 3, Period: 3 * time.Second}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Allow(ctx, "ip:1.2.3.4", policy)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 2-i, result.Remaining)
+	}
+
+	result, err := limiter.Allow(ctx, "ip:1.2.3.4", policy)
+	require.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Second, result.RetryAfter)
+
+	// other clients have their own bucket
+	result, err = limiter.Allow(ctx, "ip:5.6.7.8", policy)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	now = now.Add(time.Second)
+	result, err = limiter.Allow(ctx, "ip:1.2.3.4", policy)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+}
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("auth", "10/1m")
+	require.NoError(t, err)
+	assert.Equal(t, Policy{Name: "auth", Limit: 10, Period: time.Minute}, policy)
+
+	for _, s := range []string{"10", "0/1m", "ten/1m", "10/soon", "10/-1s"} {
+		_, err := ParsePolicy("auth", s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// PostgresLimiter keeps the buckets in the rate_limit_buckets table so all
+// replicas share the same limits. Each call is a single atomic upsert.
+type PostgresLimiter struct {
+	db *sql.DB
+}
+
+func NewPostgresLimiter(db *sql.DB) *PostgresLimiter {
+	return &PostgresLimiter{
+		db: db,
+	}
+}
+
+func (p *PostgresLimiter) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	// every expression in SET sees the old row, so the refilled amount is
+	// computed the same way for tokens and allowed
+	query := `
+		INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+		VALUES ($1, $2 - 1, TRUE, CURRENT_TIMESTAMP)
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = CASE
+				WHEN LEAST($2, b.tokens + EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - b.updated_at) * $3) >= 1
+				THEN LEAST($2, b.tokens + EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - b.updated_at) * $3) - 1
+				ELSE LEAST($2, b.tokens + EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - b.updated_at) * $3)
+			END,
+			allowed = LEAST($2, b.tokens + EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - b.updated_at) * $3) >= 1,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING tokens, allowed
+	`
+
+	var tokens float64
+	var allowed bool
+	err := p.db.QueryRowContext(ctx, query, policy.Name+":"+key, float64(policy.Limit), policy.ratePerSecond()).Scan(&tokens, &allowed)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return newResult(policy, allowed, tokens), nil
+}
+
+// Purge removes buckets that haven't been touched for olderThan. Such
+// buckets are full again, so dropping them doesn't change any limit.
+func (p *PostgresLimiter) Purge(ctx context.Context, olderThan time.Duration) error {
+	query := `DELETE FROM rate_limit_buckets WHERE updated_at < $1`
+
+	_, err := p.db.ExecContext(ctx, query, time.Now().Add(-olderThan))
+	return err
+}
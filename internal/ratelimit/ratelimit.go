@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy is a token bucket that holds Limit tokens and refills them all over
+// Period, e.g. 60 requests per minute with bursts of up to 60.
+type Policy struct {
+	Name   string
+	Limit  int
+	Period time.Duration
+}
+
+func (p Policy) ratePerSecond() float64 {
+	return float64(p.Limit) / p.Period.Seconds()
+}
+
+// resetAfter is how long an empty bucket takes to refill from tokens.
+func (p Policy) resetAfter(tokens float64) time.Duration {
+	missing := float64(p.Limit) - tokens
+	if missing <= 0 {
+		return 0
+	}
+	return time.Duration(missing / p.ratePerSecond() * float64(time.Second))
+}
+
+// retryAfter is how long it takes until the next token is available.
+func (p Policy) retryAfter(tokens float64) time.Duration {
+	if tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - tokens) / p.ratePerSecond() * float64(time.Second))
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+func newResult(policy Policy, allowed bool, tokens float64) Result {
+	return Result{
+		Allowed:    allowed,
+		Limit:      policy.Limit,
+		Remaining:  int(math.Max(0, math.Floor(tokens))),
+		Reset:      policy.resetAfter(tokens),
+		RetryAfter: policy.retryAfter(tokens),
+	}
+}
+
+type Limiter interface {
+	// Allow takes one token from the bucket of key under policy.
+	Allow(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// ParsePolicy parses a limit in the form "<requests>/<period>", e.g. "10/1m".
+func ParsePolicy(name, s string) (Policy, error) {
+	limit, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("invalid rate limit %q, want <requests>/<period>", s)
+	}
+
+	n, err := strconv.Atoi(limit)
+	if err != nil || n <= 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit %q: requests must be a positive number", s)
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", s)
+	}
+
+	return Policy{Name: name, Limit: n, Period: d}, nil
+}
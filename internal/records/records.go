@@ -0,0 +1,65 @@
+// Package records spots personal records, workouts lifting more on an
+// exercise than the user ever did before.
+package records
+
+import (
+	"context"
+	"github.com/oki-irawan/fem_project/internal/metrics"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"log/slog"
+	"strings"
+)
+
+// Previous returns the best weights the owner of workout lifted on its
+// exercises, as they stand before workout is saved. It runs outside the
+// transaction saving the workout, and a failed lookup is only logged: no
+// records are counted then rather than failing the save.
+func Previous(ctx context.Context, logger *slog.Logger, workouts store.WorkoutStore, workout *store.Workout) map[string]float64 {
+	exercises := make([]string, 0, len(workout.Entries))
+	for _, entry := range workout.Entries {
+		if entry.Weight != nil {
+			exercises = append(exercises, entry.ExerciseName)
+		}
+	}
+	if len(exercises) == 0 {
+		return nil
+	}
+
+	best, err := workouts.BestWeights(ctx, workout.UserID, exercises)
+	if err != nil {
+		logger.WarnContext(ctx, "looking up best weights", "user_id", workout.UserID, "error", err)
+		return nil
+	}
+	return best
+}
+
+// Count returns how many exercises of workout beat their previous best. The
+// first time an exercise is lifted is not a record.
+func Count(previous map[string]float64, workout *store.Workout) int {
+	heaviest := make(map[string]float64)
+	for _, entry := range workout.Entries {
+		if entry.Weight == nil {
+			continue
+		}
+		name := strings.ToLower(entry.ExerciseName)
+		if weight, ok := heaviest[name]; !ok || *entry.Weight > weight {
+			heaviest[name] = *entry.Weight
+		}
+	}
+
+	count := 0
+	for name, weight := range heaviest {
+		if best, ok := previous[name]; ok && weight > best {
+			count++
+		}
+	}
+	return count
+}
+
+// Record counts the personal records of the saved workout in
+// metrics.PersonalRecords.
+func Record(previous map[string]float64, workout *store.Workout) {
+	if count := Count(previous, workout); count > 0 {
+		metrics.PersonalRecords.Add(float64(count))
+	}
+}
@@ -0,0 +1,95 @@
+package records
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"testing"
+)
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+type fakeWorkoutStore struct {
+	store.WorkoutStore
+	best      map[string]float64
+	err       error
+	exercises []string
+}
+
+func (s *fakeWorkoutStore) BestWeights(ctx context.Context, userID int, exercises []string) (map[string]float64, error) {
+	s.exercises = exercises
+	return s.best, s.err
+}
+
+func TestCount(t *testing.T) {
+	previous := map[string]float64{"bench press": 80, "squat": 120}
+
+	test := []struct {
+		name    string
+		entries []store.WorkoutEntries
+		want    int
+	}{
+		{
+			name: "Heavier",
+			entries: []store.WorkoutEntries{
+				{ExerciseName: "Bench Press", Weight: floatPtr(82.5)},
+				{ExerciseName: "Squat", Weight: floatPtr(125)},
+			},
+			want: 2,
+		},
+		{
+			name:    "Equal Is Not A Record",
+			entries: []store.WorkoutEntries{{ExerciseName: "Squat", Weight: floatPtr(120)}},
+		},
+		{
+			name: "Counted Once Per Exercise",
+			entries: []store.WorkoutEntries{
+				{ExerciseName: "bench press", Weight: floatPtr(85)},
+				{ExerciseName: "Bench Press", Weight: floatPtr(90)},
+			},
+			want: 1,
+		},
+		{
+			name:    "First Lift",
+			entries: []store.WorkoutEntries{{ExerciseName: "Deadlift", Weight: floatPtr(140)}},
+		},
+		{
+			name:    "No Weight",
+			entries: []store.WorkoutEntries{{ExerciseName: "Squat"}},
+		},
+	}
+
+	for _, tt := range test {
+		t.Run(tt.name, func(t *testing.T) {
+			workout := &store.Workout{UserID: 1, Entries: tt.entries}
+			assert.Equal(t, tt.want, Count(previous, workout))
+		})
+	}
+}
+
+func TestPrevious(t *testing.T) {
+	workout := &store.Workout{
+		UserID: 1,
+		Entries: []store.WorkoutEntries{
+			{ExerciseName: "Squat", Weight: floatPtr(125)},
+			{ExerciseName: "Plank"},
+		},
+	}
+
+	workouts := &fakeWorkoutStore{best: map[string]float64{"squat": 120}}
+	previous := Previous(context.Background(), slog.Default(), workouts, workout)
+	assert.Equal(t, []string{"Squat"}, workouts.exercises)
+	assert.Equal(t, 1, Count(previous, workout))
+
+	// a failed lookup is logged and counts no records
+	var logs bytes.Buffer
+	workouts = &fakeWorkoutStore{err: errors.New("statement timeout")}
+	previous = Previous(context.Background(), slog.New(slog.NewTextHandler(&logs, nil)), workouts, workout)
+	assert.Nil(t, previous)
+	assert.Contains(t, logs.String(), "statement timeout")
+}
@@ -2,26 +2,132 @@ package routes
 
 import (
 	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/oki-irawan/fem_project/internal/app"
+	"github.com/oki-irawan/fem_project/internal/metrics"
+	"github.com/oki-irawan/fem_project/internal/middleware"
+	"github.com/oki-irawan/fem_project/internal/ratelimit"
+	"net/http"
 )
 
 func SetupRoutes(app *app.Application) *chi.Mux {
 	r := chi.NewRouter()
+	if app.Config.TrustProxy {
+		r.Use(chimiddleware.RealIP)
+	}
+	r.Use(middleware.RequestID)
+	r.Use(middleware.Tracing)
+	r.Use(middleware.AccessLog(app.Logger))
+	r.Use(middleware.Metrics)
+	r.Use(middleware.Recoverer(app.Logger))
+	r.Use(middleware.SecureHeaders(app.Config.HSTS))
+	r.Use(middleware.CORS(app.Config.CORS))
+	r.Use(middleware.LimitBody(app.Config.MaxBodyBytes))
 
+	rateLimit := func(policy ratelimit.Policy) func(http.Handler) http.Handler {
+		if !app.Config.RateLimit.Enabled {
+			return func(next http.Handler) http.Handler { return next }
+		}
+		return middleware.RateLimit(app.RateLimiter, policy, app.Logger)
+	}
+	// the per IP limit runs before Authenticate, the per user limits after
+	rateLimitIP := func(next http.Handler) http.Handler {
+		if !app.Config.RateLimit.Enabled {
+			return next
+		}
+		return middleware.RateLimitIP(app.RateLimiter, app.Config.RateLimit.IP, app.Logger)(next)
+	}
+
+	// an export streams for as long as it takes, so it gets no request
+	// timeout; it extends its write deadline batch by batch instead
 	r.Group(func(r chi.Router) {
+		r.Use(rateLimitIP)
 		r.Use(app.Middleware.Authenticate)
+		r.Use(rateLimit(app.Config.RateLimit.Read))
+
+		r.Get("/workouts/export", app.Middleware.RequireUser(app.WorkoutHandler.HandleExportWorkouts))
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(chimiddleware.Timeout(app.Config.RequestTimeout))
+		r.Use(rateLimitIP)
+		r.Use(app.Middleware.Authenticate)
+
+		r.Group(func(r chi.Router) {
+			r.Use(rateLimit(app.Config.RateLimit.Read))
 
-		r.Get("/workouts/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandleGetWorkoutById))
-		r.Post("/workouts", app.Middleware.RequireUser(app.WorkoutHandler.HandleCreateWorkout))
-		r.Put("/workouts/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandleUpdateWorkoutById))
-		r.Delete("/workouts/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandlerDeleteWorkoutById))
+			r.Get("/workouts/trash", app.Middleware.RequireUser(app.WorkoutHandler.HandleListTrash))
+			r.Get("/workouts/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandleGetWorkoutById))
+			r.Get("/workouts/{id}/track", app.Middleware.RequireUser(app.ActivityHandler.HandleGetTrack))
+			r.Get("/workouts/{id}/training-load", app.Middleware.RequireUser(app.TrainingHandler.HandleGetWorkoutTrainingLoad))
+			r.Get("/workouts/{id}/revisions", app.Middleware.RequireUser(app.RevisionHandler.HandleListRevisions))
+			r.Get("/workouts/{id}/revisions/diff", app.Middleware.RequireUser(app.RevisionHandler.HandleDiffRevisions))
+			r.Get("/workouts/{id}/revisions/{rev}", app.Middleware.RequireUser(app.RevisionHandler.HandleGetRevision))
+			r.Get("/sync", app.Middleware.RequireUser(app.SyncHandler.HandleGetChanges))
+			r.Get("/imports", app.Middleware.RequireUser(app.ImportHandler.HandleListImports))
+			r.Get("/imports/{id}", app.Middleware.RequireUser(app.ImportHandler.HandleGetImport))
+			r.Get("/analytics/distance", app.Middleware.RequireUser(app.AnalyticsHandler.HandleWeeklyDistance))
+			r.Get("/analytics/training-load", app.Middleware.RequireUser(app.TrainingHandler.HandleTrainingLoad))
+			r.Get("/analytics/relative-strength", app.Middleware.RequireUser(app.AnalyticsHandler.HandleRelativeStrength))
+			r.Get("/users/me", app.Middleware.RequireUser(app.UserHandler.HandleGetCurrentUser))
+			r.Get("/users/me/heart-rate-zones", app.Middleware.RequireUser(app.TrainingHandler.HandleGetHeartRateZones))
+			r.Get("/users/me/measurements", app.Middleware.RequireUser(app.MeasurementHandler.HandleListMeasurements))
+			r.Get("/users/me/measurements/{id}", app.Middleware.RequireUser(app.MeasurementHandler.HandleGetMeasurement))
+			r.Get("/goals", app.Middleware.RequireUser(app.GoalHandler.HandleListGoals))
+			r.Get("/goals/{id}", app.Middleware.RequireUser(app.GoalHandler.HandleGetGoal))
+		})
 
+		r.Group(func(r chi.Router) {
+			r.Use(rateLimit(app.Config.RateLimit.Write))
+			r.Use(app.Idempotency.Handle)
+
+			r.Post("/workouts", app.Middleware.RequireUser(app.WorkoutHandler.HandleCreateWorkout))
+			r.Put("/workouts/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandleUpdateWorkoutById))
+			r.Patch("/workouts/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandlePatchWorkoutById))
+			r.Delete("/workouts/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandlerDeleteWorkoutById))
+			r.Post("/workouts/{id}/restore", app.Middleware.RequireUser(app.WorkoutHandler.HandleRestoreWorkout))
+			r.Post("/workouts/{id}/entries", app.Middleware.RequireUser(app.WorkoutHandler.HandleCreateEntry))
+			r.Post("/workouts/{id}/entries/reorder", app.Middleware.RequireUser(app.WorkoutHandler.HandleReorderEntries))
+			r.Patch("/workouts/{id}/entries/{entryId}", app.Middleware.RequireUser(app.WorkoutHandler.HandleUpdateEntry))
+			r.Delete("/workouts/{id}/entries/{entryId}", app.Middleware.RequireUser(app.WorkoutHandler.HandleDeleteEntry))
+			r.Post("/workouts/{id}/revisions/{rev}/revert", app.Middleware.RequireUser(app.RevisionHandler.HandleRevertRevision))
+			r.Post("/sync", app.Middleware.RequireUser(app.SyncHandler.HandlePushChanges))
+			r.Patch("/users/me", app.Middleware.RequireUser(app.UserHandler.HandleUpdateUser))
+			r.Put("/users/me/heart-rate-zones", app.Middleware.RequireUser(app.TrainingHandler.HandlePutHeartRateZones))
+			r.Post("/users/me/measurements", app.Middleware.RequireUser(app.MeasurementHandler.HandleCreateMeasurement))
+			r.Patch("/users/me/measurements/{id}", app.Middleware.RequireUser(app.MeasurementHandler.HandleUpdateMeasurement))
+			r.Delete("/users/me/measurements/{id}", app.Middleware.RequireUser(app.MeasurementHandler.HandleDeleteMeasurement))
+			r.Post("/goals", app.Middleware.RequireUser(app.GoalHandler.HandleCreateGoal))
+			r.Patch("/goals/{id}", app.Middleware.RequireUser(app.GoalHandler.HandleUpdateGoal))
+			r.Delete("/goals/{id}", app.Middleware.RequireUser(app.GoalHandler.HandleDeleteGoal))
+		})
+
+		// uploads get a larger body limit than the rest of the API
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.LimitBody(app.Config.ImportMaxBytes))
+			r.Use(rateLimit(app.Config.RateLimit.Write))
+			r.Use(app.Idempotency.Handle)
+
+			r.Post("/imports", app.Middleware.RequireUser(app.ImportHandler.HandleCreateImport))
+			r.Post("/activities", app.Middleware.RequireUser(app.ActivityHandler.HandleCreateActivity))
+		})
 	})
 
-	r.Get("/health", app.HealthCheck)
+	r.Group(func(r chi.Router) {
+		r.Use(chimiddleware.Timeout(app.Config.RequestTimeout))
 
-	r.Post("/users", app.UserHandler.HandleCreateUser)
-	r.Post("/token/authentication", app.TokenHandler.HandlerCreateToken)
+		r.Get("/health", app.HandleLiveness)
+		r.Get("/health/live", app.HandleLiveness)
+		r.Get("/health/ready", app.HandleReadiness)
+		r.Method(http.MethodGet, "/metrics", metrics.Handler(app.Config.MetricsToken))
+
+		r.Group(func(r chi.Router) {
+			r.Use(rateLimit(app.Config.RateLimit.Auth))
+
+			r.Post("/users", app.UserHandler.HandleCreateUser)
+			r.Post("/token/authentication", app.TokenHandler.HandlerCreateToken)
+		})
+	})
 
 	return r
 }
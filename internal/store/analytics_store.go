@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+)
+
+// WeeklyDistance is the cardio done in the ISO week (Monday, UTC) starting
+// at WeekStart.
+type WeeklyDistance struct {
+	WeekStart       time.Time `json:"week_start"`
+	DistanceMeters  float64   `json:"distance_meters"`
+	DurationSeconds int       `json:"duration_seconds"`
+	Entries         int       `json:"entries"`
+}
+
+// RelativeStrength is the heaviest set of an exercise against the body
+// weight of the day it was lifted.
+type RelativeStrength struct {
+	ExerciseName string    `json:"exercise_name"`
+	Weight       float64   `json:"weight"`
+	Reps         *int      `json:"reps"`
+	PerformedAt  time.Time `json:"performed_at"`
+	BodyWeightKg *float64  `json:"body_weight_kg"`
+	// BodyWeightRatio is Weight as a multiple of BodyWeightKg, e.g. 1.25
+	// for a 100 kg bench at 80 kg.
+	BodyWeightRatio *float64 `json:"bodyweight_ratio"`
+}
+
+type AnalyticsStore interface {
+	// WeeklyDistance returns the distance totals of the cardio entries of
+	// userID performed in r, one row per week that has any, oldest first.
+	WeeklyDistance(ctx context.Context, userID int, r TimeRange) ([]WeeklyDistance, error)
+	// RelativeStrength returns the heaviest set of every weighted exercise
+	// of userID, by exercise name.
+	RelativeStrength(ctx context.Context, userID int) ([]RelativeStrength, error)
+}
+
+type PostgresAnalyticsStore struct {
+	db       *sql.DB
+	timeouts QueryTimeouts
+}
+
+func NewPostgresAnalyticsStore(db *sql.DB, timeouts QueryTimeouts) *PostgresAnalyticsStore {
+	return &PostgresAnalyticsStore{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+func (s *PostgresAnalyticsStore) WeeklyDistance(ctx context.Context, userID int, r TimeRange) ([]WeeklyDistance, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "workout_entries", "WeeklyDistance")
+	defer op.end()
+
+	// distance_meters is normalized by the database, so km and miles add up
+	query := `
+		SELECT date_trunc('week', w.performed_at AT TIME ZONE 'UTC') AS week,
+			SUM(we.distance_meters),
+			COALESCE(SUM(we.duration_seconds), 0),
+			COUNT(*)
+		FROM workout_entries we
+		JOIN workouts w ON w.id = we.workout_id
+		WHERE w.user_id = $1 AND w.deleted_at IS NULL
+			AND we.distance_meters IS NOT NULL
+			AND ($2::timestamptz IS NULL OR w.performed_at >= $2)
+			AND ($3::timestamptz IS NULL OR w.performed_at < $3)
+		GROUP BY week
+		ORDER BY week
+	`
+
+	rows, err := s.db.QueryContext(ctx, op.statement(query), userID, nullTime(r.From), nullTime(r.To))
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	weeks := []WeeklyDistance{}
+	for rows.Next() {
+		var week WeeklyDistance
+		err = rows.Scan(&week.WeekStart, &week.DistanceMeters, &week.DurationSeconds, &week.Entries)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		week.WeekStart = week.WeekStart.UTC()
+		weeks = append(weeks, week)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+
+	return weeks, nil
+}
+
+func (s *PostgresAnalyticsStore) RelativeStrength(ctx context.Context, userID int) ([]RelativeStrength, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "workout_entries", "RelativeStrength")
+	defer op.end()
+
+	// the body weight of the day is found like for calorie estimates: the
+	// last weigh-in up to it, else the first one after
+	query := `
+		SELECT DISTINCT ON (LOWER(we.exercise_name))
+			we.exercise_name, we.weight, we.reps, w.performed_at,
+			COALESCE(
+				(SELECT m.body_weight_kg FROM body_measurements m
+				WHERE m.user_id = w.user_id AND m.body_weight_kg IS NOT NULL
+					AND m.measured_on <= (w.performed_at AT TIME ZONE 'UTC')::date
+				ORDER BY m.measured_on DESC LIMIT 1),
+				(SELECT m.body_weight_kg FROM body_measurements m
+				WHERE m.user_id = w.user_id AND m.body_weight_kg IS NOT NULL
+				ORDER BY m.measured_on LIMIT 1)
+			)
+		FROM workout_entries we
+		INNER JOIN workouts w ON w.id = we.workout_id
+		WHERE w.user_id = $1 AND w.deleted_at IS NULL AND we.weight > 0
+		ORDER BY LOWER(we.exercise_name), we.weight DESC, w.performed_at
+	`
+
+	rows, err := s.db.QueryContext(ctx, op.statement(query), userID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	lifts := []RelativeStrength{}
+	for rows.Next() {
+		var lift RelativeStrength
+		err = rows.Scan(&lift.ExerciseName, &lift.Weight, &lift.Reps, &lift.PerformedAt, &lift.BodyWeightKg)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		if lift.BodyWeightKg != nil {
+			ratio := math.Round(lift.Weight / *lift.BodyWeightKg * 100) / 100
+			lift.BodyWeightRatio = &ratio
+		}
+		lifts = append(lifts, lift)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+
+	return lifts, nil
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/oki-irawan/fem_project/internal/catalog"
+	"math"
+	"time"
+)
+
+// Calorie sources.
+const (
+	CaloriesReported  = "reported"
+	CaloriesEstimated = "estimated"
+)
+
+const (
+	// referenceWeightKg stands in for the body weight of users who have not
+	// given theirs.
+	referenceWeightKg = 70.0
+	// secondsPerRep and restSecondsPerSet estimate how long a set of reps
+	// takes. MET values for resistance training cover the rests as well.
+	secondsPerRep     = 3
+	restSecondsPerSet = 60
+)
+
+// latestBodyWeight selects the last weigh-in of the user u.
+const latestBodyWeight = `(
+	SELECT m.body_weight_kg FROM body_measurements m
+	WHERE m.user_id = u.id AND m.body_weight_kg IS NOT NULL
+	ORDER BY m.measured_on DESC LIMIT 1
+)`
+
+// bodyWeight returns the body weight of userID in kg on the day of at, nil
+// if unknown: the last weigh-in up to that day, else the earliest one after
+// it.
+func bodyWeight(ctx context.Context, q querier, op *operation, userID int, at time.Time) (*float64, error) {
+	var weight sql.NullFloat64
+	query := `
+		SELECT COALESCE(
+			(SELECT body_weight_kg FROM body_measurements
+			WHERE user_id = $1 AND body_weight_kg IS NOT NULL AND measured_on <= $2
+			ORDER BY measured_on DESC LIMIT 1),
+			(SELECT body_weight_kg FROM body_measurements
+			WHERE user_id = $1 AND body_weight_kg IS NOT NULL
+			ORDER BY measured_on LIMIT 1)
+		)
+	`
+
+	err := q.QueryRowContext(ctx, op.statement(query), userID, NewDate(at)).Scan(&weight)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, translateError(err)
+	}
+	if !weight.Valid {
+		return nil, nil
+	}
+	return &weight.Float64, nil
+}
+
+// setCalories estimates the calories of workout when estimate is true and
+// records where its calories come from.
+func setCalories(workout *Workout, estimate bool, weightKg *float64) {
+	if !estimate {
+		workout.CaloriesSource = CaloriesReported
+		return
+	}
+
+	weight := referenceWeightKg
+	if weightKg != nil {
+		weight = *weightKg
+	}
+
+	workout.CaloriesBurned = estimateCalories(workout, weight, catalog.Default())
+	workout.CaloriesSource = CaloriesEstimated
+}
+
+// estimateCalories returns MET × kg × hours summed over the entries of
+// workout. Entries without a duration are timed from their sets and reps,
+// and the entries together never last longer than the workout. A workout
+// without entries is estimated from its duration alone.
+func estimateCalories(workout *Workout, weightKg float64, exercises *catalog.Catalog) int {
+	var mets, seconds []float64
+	total := 0.0
+	for _, entry := range workout.Entries {
+		s := entrySeconds(&entry)
+		mets = append(mets, exercises.MET(entry.ExerciseName, entry.Type))
+		seconds = append(seconds, s)
+		total += s
+	}
+
+	limit := float64(workout.DurationMinutes * 60)
+	if total == 0 {
+		mets, seconds, total = []float64{exercises.MET("", "")}, []float64{limit}, limit
+	}
+
+	scale := 1.0
+	if limit > 0 && total > limit {
+		scale = limit / total
+	}
+
+	kcal := 0.0
+	for i, met := range mets {
+		kcal += met * weightKg * seconds[i] * scale / 3600
+	}
+	return int(math.Round(kcal))
+}
+
+// entrySeconds returns how long entry took, including the rests between sets
+// of strength entries.
+func entrySeconds(entry *WorkoutEntries) float64 {
+	sets := max(1, entry.Sets)
+
+	if entry.Type == EntryCardio {
+		if entry.DurationSeconds == nil {
+			return 0
+		}
+		return float64(*entry.DurationSeconds * sets)
+	}
+
+	work := 0
+	switch {
+	case entry.DurationSeconds != nil:
+		work = *entry.DurationSeconds
+	case entry.Reps != nil:
+		work = *entry.Reps * secondsPerRep
+	}
+	return float64(sets * (work + restSecondsPerSet))
+}
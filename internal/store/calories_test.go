@@ -0,0 +1,73 @@
+package store
+
+import (
+	"github.com/oki-irawan/fem_project/internal/catalog"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEstimateCalories(t *testing.T) {
+	exercises := catalog.New([]catalog.Exercise{
+		{Name: "Squat", Category: "strength", MET: 6},
+		{Name: "Running", Category: "cardio", MET: 10},
+	}, catalog.METTable{Default: 4, Categories: map[string]float64{"strength": 5, "cardio": 7}})
+
+	reps, seconds := 5, 1800
+
+	test := []struct {
+		name    string
+		workout Workout
+		want    int
+	}{
+		{
+			// 5 sets of (5 reps × 3 s + 60 s rest) = 375 s at 6 MET
+			name: "Sets And Reps",
+			workout: Workout{DurationMinutes: 60, Entries: []WorkoutEntries{
+				{Type: EntryStrength, ExerciseName: "Squat", Sets: 5, Reps: &reps},
+			}},
+			want: 44,
+		},
+		{
+			// 30 min at 10 MET plus 375 s of unknown strength work at 5 MET
+			name: "Cardio And Fallback",
+			workout: Workout{DurationMinutes: 60, Entries: []WorkoutEntries{
+				{Type: EntryCardio, ExerciseName: "Running", Sets: 1, DurationSeconds: &seconds},
+				{Type: EntryStrength, ExerciseName: "Sled Push", Sets: 5, Reps: &reps},
+			}},
+			want: 386,
+		},
+		{
+			// the 30 min run is squeezed into the 15 min workout
+			name: "Capped By Duration",
+			workout: Workout{DurationMinutes: 15, Entries: []WorkoutEntries{
+				{Type: EntryCardio, ExerciseName: "Running", Sets: 1, DurationSeconds: &seconds},
+			}},
+			want: 175,
+		},
+		{
+			name:    "No Entries",
+			workout: Workout{DurationMinutes: 45},
+			want:    210,
+		},
+	}
+
+	for _, tt := range test {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, estimateCalories(&tt.workout, 70, exercises))
+		})
+	}
+}
+
+func TestSetCalories(t *testing.T) {
+	workout := &Workout{CaloriesBurned: 500, DurationMinutes: 30}
+	setCalories(workout, false, nil)
+	assert.Equal(t, 500, workout.CaloriesBurned)
+	assert.Equal(t, CaloriesReported, workout.CaloriesSource)
+
+	weight := 100.0
+	workout = &Workout{DurationMinutes: 30}
+	setCalories(workout, true, &weight)
+	assert.Equal(t, CaloriesEstimated, workout.CaloriesSource)
+	// the default MET of 4 for half an hour
+	assert.Equal(t, 200, workout.CaloriesBurned)
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// Entry types.
+const (
+	EntryStrength = "strength"
+	EntryCardio   = "cardio"
+)
+
+// Distance units.
+const (
+	UnitMeters     = "m"
+	UnitKilometers = "km"
+	UnitMiles      = "mi"
+)
+
+// unitMeters is the length of each distance unit in meters.
+var unitMeters = map[string]float64{
+	UnitMeters:     1,
+	UnitKilometers: 1000,
+	UnitMiles:      1609.344,
+}
+
+// EntryInterval is a segment of a cardio entry, e.g. one repeat of an
+// interval run. Its distance is in the unit of the entry.
+type EntryInterval struct {
+	DurationSeconds int      `json:"duration_seconds"`
+	Distance        *float64 `json:"distance"`
+	AvgHeartRate    *int     `json:"avg_heart_rate"`
+	Notes           string   `json:"notes,omitempty"`
+	AvgPace         *float64 `json:"avg_pace_seconds_per_km"`
+	AvgSpeed        *float64 `json:"avg_speed_kmh"`
+}
+
+// DistanceMeters converts distance in unit to meters. ok is false for an
+// unknown unit.
+func DistanceMeters(distance float64, unit string) (float64, bool) {
+	m, ok := unitMeters[unit]
+	return distance * m, ok
+}
+
+// derive fills in the defaults and computed fields of entry.
+func (entry *WorkoutEntries) derive() {
+	if entry.Type == "" {
+		entry.Type = EntryStrength
+	}
+
+	entry.AvgPace, entry.AvgSpeed = nil, nil
+	if entry.Distance != nil && entry.DurationSeconds != nil {
+		entry.AvgPace, entry.AvgSpeed = paceAndSpeed(*entry.Distance, entry.DistanceUnit, *entry.DurationSeconds)
+	}
+
+	for i := range entry.Intervals {
+		interval := &entry.Intervals[i]
+		interval.AvgPace, interval.AvgSpeed = nil, nil
+		if interval.Distance != nil {
+			interval.AvgPace, interval.AvgSpeed = paceAndSpeed(*interval.Distance, entry.DistanceUnit, interval.DurationSeconds)
+		}
+	}
+}
+
+// paceAndSpeed returns the pace in seconds per kilometer and the speed in
+// km/h of covering distance in seconds.
+func paceAndSpeed(distance float64, unit string, seconds int) (*float64, *float64) {
+	meters, ok := DistanceMeters(distance, unit)
+	if !ok || meters <= 0 || seconds <= 0 {
+		return nil, nil
+	}
+
+	pace := math.Round(float64(seconds)/(meters/1000)*10) / 10
+	speed := math.Round(meters/1000/(float64(seconds)/3600)*100) / 100
+	return &pace, &speed
+}
+
+func marshalIntervals(intervals []EntryInterval) ([]byte, error) {
+	if len(intervals) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(intervals)
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -40,3 +41,14 @@ func Migrate(db *sql.DB, dir string) error {
 
 	return nil
 }
+
+// MigrationVersions returns the version the database is at and the latest
+// version available in migrationFS.
+func MigrationVersions(ctx context.Context, db *sql.DB, migrationFS fs.FS) (current, target int64, err error) {
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, migrationFS)
+	if err != nil {
+		return 0, 0, fmt.Errorf("goose provider: %w", err)
+	}
+
+	return provider.GetVersions(ctx)
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("resource not found")
+	ErrConflict   = errors.New("resource conflict")
+	ErrForbidden  = errors.New("forbidden")
+	ErrValidation = errors.New("validation failed")
+	// ErrVersionMismatch is returned when an update was based on a stale
+	// version of the resource.
+	ErrVersionMismatch = errors.New("resource version mismatch")
+	// ErrCursorExpired is returned for a sync cursor older than the
+	// tombstones that are still kept.
+	ErrCursorExpired = errors.New("sync cursor expired")
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		messages = append(messages, f.Field+": "+f.Message)
+	}
+	return "validation failed: " + strings.Join(messages, ", ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// ConflictError is returned when a write violates a unique constraint.
+type ConflictError struct {
+	Constraint string
+	Field      string
+}
+
+func (e *ConflictError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("%s already exists", e.Field)
+	}
+	return "resource already exists"
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// constraintFields maps database constraints to the request field that
+// violates them.
+var constraintFields = map[string]string{
+	"users_username_key":         "username",
+	"users_email_key":            "email",
+	"workouts_title_key":         "title",
+	"workouts_uuid_key":          "uuid",
+	"workout_entries_uuid_key":   "entries",
+	"valid_workout_entry":        "entries",
+	"body_measurements_user_day": "measured_on",
+}
+
+const (
+	pgUniqueViolation = "23505"
+	pgCheckViolation  = "23514"
+)
+
+// translateError turns driver errors into the domain errors above. Other
+// errors are returned unchanged.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		return &ConflictError{Constraint: pgErr.ConstraintName, Field: constraintFields[pgErr.ConstraintName]}
+	case pgCheckViolation:
+		field, ok := constraintFields[pgErr.ConstraintName]
+		if !ok {
+			field = pgErr.ConstraintName
+		}
+		return &ValidationError{Fields: []FieldError{{Field: field, Code: "invalid", Message: "violates " + pgErr.ConstraintName}}}
+	}
+
+	return err
+}
@@ -0,0 +1,369 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Kinds of goals.
+const (
+	// GoalLift is reached by lifting Target on ExerciseName.
+	GoalLift = "lift"
+	// GoalWorkouts is reached by doing Target workouts.
+	GoalWorkouts = "workouts"
+	// GoalDistance is reached by covering Target km in cardio entries.
+	GoalDistance = "distance"
+	// GoalBodyWeight is reached by weighing in at Target kg, coming from
+	// either side.
+	GoalBodyWeight = "body_weight"
+)
+
+// Calendar periods a workouts or distance goal can repeat over. Weeks start
+// on Monday and all periods are in UTC, like the analytics.
+const (
+	PeriodWeek    = "week"
+	PeriodMonth   = "month"
+	PeriodQuarter = "quarter"
+	PeriodYear    = "year"
+)
+
+// Goal is something a user sets out to do between StartsOn and Deadline.
+type Goal struct {
+	ID           int64   `json:"id"`
+	UUID         string  `json:"uuid"`
+	UserID       int     `json:"-"`
+	Type         string  `json:"type"`
+	Title        string  `json:"title"`
+	ExerciseName *string `json:"exercise_name"`
+	// Target is in kg for lift and body weight goals, in km for distance
+	// goals and a count of workouts for workouts goals.
+	Target float64 `json:"target"`
+	// Period makes a workouts or distance goal start over every period,
+	// e.g. 4 workouts a week. Without it everything from StartsOn counts.
+	Period *string `json:"period"`
+	// Baseline is the best lift before StartsOn for lift goals and the body
+	// weight on StartsOn for body weight goals. Progress is measured from it.
+	Baseline   *float64   `json:"baseline"`
+	StartsOn   Date       `json:"starts_on"`
+	Deadline   *Date      `json:"deadline"`
+	AchievedAt *time.Time `json:"achieved_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type GoalStore interface {
+	// CreateGoal stores goal with its baseline as of goal.StartsOn.
+	CreateGoal(ctx context.Context, goal *Goal) error
+	GetGoal(ctx context.Context, userID int, id int64) (*Goal, error)
+	// ListGoals returns the goals of userID, oldest first.
+	ListGoals(ctx context.Context, userID int) ([]*Goal, error)
+	// UpdateGoal stores goal with a fresh baseline. Changing what the goal
+	// asks for resets its achievement.
+	UpdateGoal(ctx context.Context, goal *Goal) error
+	DeleteGoal(ctx context.Context, userID int, id int64) error
+	// MeasureGoal returns what goal measures over r: the heaviest lift,
+	// the number of workouts, the km covered or the last body weight. It
+	// is nil when there is no lift or weigh-in in r.
+	MeasureGoal(ctx context.Context, goal *Goal, r TimeRange) (*float64, error)
+	// ListOpenGoals returns up to limit goals that have started, have no
+	// deadline before deadlineSince and are not achieved yet, or not in the
+	// current period, least recently checked first.
+	ListOpenGoals(ctx context.Context, deadlineSince time.Time, limit int) ([]*Goal, error)
+	MarkGoalChecked(ctx context.Context, id int64) error
+	// MarkGoalAchieved records that goal id is achieved unless that was
+	// already recorded at or after since. It returns when the goal was
+	// achieved, nil if it had been recorded before.
+	MarkGoalAchieved(ctx context.Context, id int64, since time.Time) (*time.Time, error)
+}
+
+type PostgresGoalStore struct {
+	db       *sql.DB
+	timeouts QueryTimeouts
+}
+
+func NewPostgresGoalStore(db *sql.DB, timeouts QueryTimeouts) *PostgresGoalStore {
+	return &PostgresGoalStore{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+const goalColumns = `id, uuid, user_id, goal_type, title, exercise_name, target, period, baseline, starts_on, deadline,
+	achieved_at, created_at, updated_at`
+
+func scanGoal(row scanner, g *Goal) error {
+	err := row.Scan(&g.ID, &g.UUID, &g.UserID, &g.Type, &g.Title, &g.ExerciseName, &g.Target, &g.Period, &g.Baseline,
+		&g.StartsOn, &g.Deadline, &g.AchievedAt, &g.CreatedAt, &g.UpdatedAt)
+	return translateError(err)
+}
+
+// baseline returns where goal starts from. A body weight goal cannot be
+// tracked without a body weight to start from.
+func (s *PostgresGoalStore) baseline(ctx context.Context, op *operation, goal *Goal) (*float64, error) {
+	switch goal.Type {
+	case GoalLift:
+		return s.measure(ctx, op, goal, TimeRange{To: goal.StartsOn.Time})
+	case GoalBodyWeight:
+		weight, err := bodyWeight(ctx, s.db, op, goal.UserID, goal.StartsOn.Time)
+		if err != nil {
+			return nil, err
+		}
+		if weight == nil {
+			return nil, &ValidationError{Fields: []FieldError{{
+				Field: "target", Code: "no_body_weight",
+				Message: "a body weight goal needs a body weight to start from, add a measurement first",
+			}}}
+		}
+		return weight, nil
+	}
+
+	return nil, nil
+}
+
+func (s *PostgresGoalStore) CreateGoal(ctx context.Context, goal *Goal) error {
+	ctx, op := startOperation(ctx, s.timeouts, "goals", "CreateGoal")
+	defer op.end()
+
+	baseline, err := s.baseline(ctx, op, goal)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO goals (user_id, goal_type, title, exercise_name, target, period, baseline, starts_on, deadline)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING ` + goalColumns
+
+	row := s.db.QueryRowContext(ctx, op.statement(query), goal.UserID, goal.Type, goal.Title, goal.ExerciseName,
+		goal.Target, goal.Period, baseline, goal.StartsOn, goal.Deadline)
+	return scanGoal(row, goal)
+}
+
+func (s *PostgresGoalStore) GetGoal(ctx context.Context, userID int, id int64) (*Goal, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "goals", "GetGoal")
+	defer op.end()
+
+	query := `
+		SELECT ` + goalColumns + `
+		FROM goals
+		WHERE id = $1 AND user_id = $2
+	`
+
+	goal := &Goal{}
+	err := scanGoal(s.db.QueryRowContext(ctx, op.statement(query), id, userID), goal)
+	if err != nil {
+		return nil, err
+	}
+
+	return goal, nil
+}
+
+func (s *PostgresGoalStore) ListGoals(ctx context.Context, userID int) ([]*Goal, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "goals", "ListGoals")
+	defer op.end()
+
+	query := `
+		SELECT ` + goalColumns + `
+		FROM goals
+		WHERE user_id = $1
+		ORDER BY id
+	`
+
+	return s.queryGoals(ctx, op, query, userID)
+}
+
+func (s *PostgresGoalStore) queryGoals(ctx context.Context, op *operation, query string, args ...any) ([]*Goal, error) {
+	rows, err := s.db.QueryContext(ctx, op.statement(query), args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	goals := []*Goal{}
+	for rows.Next() {
+		goal := &Goal{}
+		err = scanGoal(rows, goal)
+		if err != nil {
+			return nil, err
+		}
+
+		goals = append(goals, goal)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+
+	return goals, nil
+}
+
+func (s *PostgresGoalStore) UpdateGoal(ctx context.Context, goal *Goal) error {
+	ctx, op := startOperation(ctx, s.timeouts, "goals", "UpdateGoal")
+	defer op.end()
+
+	baseline, err := s.baseline(ctx, op, goal)
+	if err != nil {
+		return err
+	}
+
+	// the SET expressions see the old row, so achieved_at is only kept when
+	// the goal still asks for the same thing
+	query := `
+		UPDATE goals
+		SET title = $1, exercise_name = $2, target = $3, period = $4, baseline = $5, starts_on = $6, deadline = $7,
+			achieved_at = CASE
+				WHEN (exercise_name, target, period, starts_on, deadline) IS DISTINCT FROM ($2, $3, $4, $6, $7) THEN NULL
+				ELSE achieved_at
+			END,
+			checked_at = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $8 AND user_id = $9
+		RETURNING ` + goalColumns
+
+	row := s.db.QueryRowContext(ctx, op.statement(query), goal.Title, goal.ExerciseName, goal.Target, goal.Period,
+		baseline, goal.StartsOn, goal.Deadline, goal.ID, goal.UserID)
+	return scanGoal(row, goal)
+}
+
+func (s *PostgresGoalStore) DeleteGoal(ctx context.Context, userID int, id int64) error {
+	ctx, op := startOperation(ctx, s.timeouts, "goals", "DeleteGoal")
+	defer op.end()
+
+	query := `DELETE FROM goals WHERE id = $1 AND user_id = $2`
+
+	result, err := s.db.ExecContext(ctx, op.statement(query), id, userID)
+	if err != nil {
+		return translateError(err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return translateError(err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *PostgresGoalStore) MeasureGoal(ctx context.Context, goal *Goal, r TimeRange) (*float64, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "goals", "MeasureGoal")
+	defer op.end()
+
+	return s.measure(ctx, op, goal, r)
+}
+
+func (s *PostgresGoalStore) measure(ctx context.Context, op *operation, goal *Goal, r TimeRange) (*float64, error) {
+	var query string
+	args := []any{goal.UserID, nullTime(r.From), nullTime(r.To)}
+
+	switch goal.Type {
+	case GoalLift:
+		query = `
+			SELECT MAX(we.weight)
+			FROM workout_entries we
+			INNER JOIN workouts w ON w.id = we.workout_id
+			WHERE w.user_id = $1 AND w.deleted_at IS NULL
+				AND ($2::timestamptz IS NULL OR w.performed_at >= $2)
+				AND ($3::timestamptz IS NULL OR w.performed_at < $3)
+				AND LOWER(we.exercise_name) = LOWER($4)
+		`
+		args = append(args, goal.ExerciseName)
+	case GoalWorkouts:
+		query = `
+			SELECT COUNT(*)
+			FROM workouts w
+			WHERE w.user_id = $1 AND w.deleted_at IS NULL
+				AND ($2::timestamptz IS NULL OR w.performed_at >= $2)
+				AND ($3::timestamptz IS NULL OR w.performed_at < $3)
+		`
+	case GoalDistance:
+		query = `
+			SELECT COALESCE(SUM(we.distance_meters), 0) / 1000
+			FROM workout_entries we
+			INNER JOIN workouts w ON w.id = we.workout_id
+			WHERE w.user_id = $1 AND w.deleted_at IS NULL
+				AND ($2::timestamptz IS NULL OR w.performed_at >= $2)
+				AND ($3::timestamptz IS NULL OR w.performed_at < $3)
+		`
+	case GoalBodyWeight:
+		// the bounds are UTC midnights, so comparing days is exact
+		query = `
+			SELECT body_weight_kg
+			FROM body_measurements
+			WHERE user_id = $1 AND body_weight_kg IS NOT NULL
+				AND ($2::timestamptz IS NULL OR measured_on >= ($2 AT TIME ZONE 'UTC')::date)
+				AND ($3::timestamptz IS NULL OR measured_on < ($3 AT TIME ZONE 'UTC')::date)
+			ORDER BY measured_on DESC
+			LIMIT 1
+		`
+	default:
+		return nil, nil
+	}
+
+	var value sql.NullFloat64
+	err := s.db.QueryRowContext(ctx, op.statement(query), args...).Scan(&value)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, translateError(err)
+	}
+	if !value.Valid {
+		return nil, nil
+	}
+	return &value.Float64, nil
+}
+
+func (s *PostgresGoalStore) ListOpenGoals(ctx context.Context, deadlineSince time.Time, limit int) ([]*Goal, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "goals", "ListOpenGoals")
+	defer op.end()
+
+	query := `
+		SELECT ` + goalColumns + `
+		FROM goals
+		WHERE starts_on <= (CURRENT_TIMESTAMP AT TIME ZONE 'UTC')::date
+			AND (deadline IS NULL OR deadline >= $1)
+			AND (achieved_at IS NULL OR (
+				period IS NOT NULL AND
+				achieved_at < date_trunc(period, CURRENT_TIMESTAMP AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'
+			))
+		ORDER BY checked_at NULLS FIRST, id
+		LIMIT $2
+	`
+
+	return s.queryGoals(ctx, op, query, NewDate(deadlineSince), limit)
+}
+
+func (s *PostgresGoalStore) MarkGoalChecked(ctx context.Context, id int64) error {
+	ctx, op := startOperation(ctx, s.timeouts, "goals", "MarkGoalChecked")
+	defer op.end()
+
+	query := `UPDATE goals SET checked_at = CURRENT_TIMESTAMP WHERE id = $1`
+
+	_, err := s.db.ExecContext(ctx, op.statement(query), id)
+	return translateError(err)
+}
+
+func (s *PostgresGoalStore) MarkGoalAchieved(ctx context.Context, id int64, since time.Time) (*time.Time, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "goals", "MarkGoalAchieved")
+	defer op.end()
+
+	// the condition makes sure only one caller gets to report the goal
+	query := `
+		UPDATE goals
+		SET achieved_at = CURRENT_TIMESTAMP, checked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND (achieved_at IS NULL OR achieved_at < $2)
+		RETURNING achieved_at
+	`
+
+	var achievedAt time.Time
+	err := s.db.QueryRowContext(ctx, op.statement(query), id, since).Scan(&achievedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return &achievedAt, nil
+}
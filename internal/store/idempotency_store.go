@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type IdempotencyRecord struct {
+	UserID      int
+	Key         string
+	Method      string
+	Path        string
+	RequestHash []byte
+	StatusCode  int
+	// Header holds the response headers that are replayed with Body.
+	Header    http.Header
+	Body      []byte
+	Completed bool
+	ExpiresAt time.Time
+	// LockedUntil is when the reservation of an uncompleted record lapses.
+	LockedUntil time.Time
+}
+
+type IdempotencyStore interface {
+	// Reserve claims record.Key for record.UserID. When the key is already
+	// taken and not expired, the stored record is returned instead, unless
+	// it was never completed and its lease ran out: then it is taken over.
+	Reserve(ctx context.Context, record *IdempotencyRecord) (existing *IdempotencyRecord, err error)
+	Complete(ctx context.Context, record *IdempotencyRecord) error
+	Release(ctx context.Context, userID int, key string) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type PostgresIdempotencyStore struct {
+	db       *sql.DB
+	timeouts QueryTimeouts
+}
+
+func NewPostgresIdempotencyStore(db *sql.DB, timeouts QueryTimeouts) *PostgresIdempotencyStore {
+	return &PostgresIdempotencyStore{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+func (s *PostgresIdempotencyStore) Reserve(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "idempotency_keys", "Reserve")
+	defer op.end()
+
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND expires_at < CURRENT_TIMESTAMP`
+
+	_, err := s.db.ExecContext(ctx, op.statement(query), record.UserID, record.Key)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	// the primary key makes concurrent reservations of the same key race
+	// safely: exactly one insert, or takeover of a lapsed reservation, wins
+	query = `
+		INSERT INTO idempotency_keys (user_id, key, method, path, request_hash, expires_at, locked_until)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id, key) DO UPDATE
+		SET method = EXCLUDED.method,
+			path = EXCLUDED.path,
+			request_hash = EXCLUDED.request_hash,
+			created_at = CURRENT_TIMESTAMP,
+			expires_at = EXCLUDED.expires_at,
+			locked_until = EXCLUDED.locked_until
+		WHERE idempotency_keys.completed_at IS NULL AND idempotency_keys.locked_until < CURRENT_TIMESTAMP
+	`
+
+	result, err := s.db.ExecContext(ctx, op.statement(query), record.UserID, record.Key, record.Method, record.Path, record.RequestHash, record.ExpiresAt, record.LockedUntil)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	if inserted == 1 {
+		return nil, nil
+	}
+
+	query = `
+		SELECT user_id, key, method, path, request_hash, status_code, response_headers, response_body, completed_at IS NOT NULL, expires_at, locked_until
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2
+	`
+
+	existing := &IdempotencyRecord{}
+	var statusCode sql.NullInt64
+	var header []byte
+	var lockedUntil sql.NullTime
+	err = s.db.QueryRowContext(ctx, op.statement(query), record.UserID, record.Key).Scan(
+		&existing.UserID,
+		&existing.Key,
+		&existing.Method,
+		&existing.Path,
+		&existing.RequestHash,
+		&statusCode,
+		&header,
+		&existing.Body,
+		&existing.Completed,
+		&existing.ExpiresAt,
+		&lockedUntil,
+	)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	existing.StatusCode = int(statusCode.Int64)
+	existing.LockedUntil = lockedUntil.Time
+	if header != nil {
+		err = json.Unmarshal(header, &existing.Header)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return existing, nil
+}
+
+func (s *PostgresIdempotencyStore) Complete(ctx context.Context, record *IdempotencyRecord) error {
+	ctx, op := startOperation(ctx, s.timeouts, "idempotency_keys", "Complete")
+	defer op.end()
+
+	header, err := json.Marshal(record.Header)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $1, response_headers = $2, response_body = $3, completed_at = CURRENT_TIMESTAMP
+		WHERE user_id = $4 AND key = $5
+	`
+
+	_, err = s.db.ExecContext(ctx, op.statement(query), record.StatusCode, header, record.Body, record.UserID, record.Key)
+	return translateError(err)
+}
+
+func (s *PostgresIdempotencyStore) Release(ctx context.Context, userID int, key string) error {
+	ctx, op := startOperation(ctx, s.timeouts, "idempotency_keys", "Release")
+	defer op.end()
+
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2 AND completed_at IS NULL`
+
+	_, err := s.db.ExecContext(ctx, op.statement(query), userID, key)
+	return translateError(err)
+}
+
+func (s *PostgresIdempotencyStore) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "idempotency_keys", "DeleteExpired")
+	defer op.end()
+
+	query := `DELETE FROM idempotency_keys WHERE expires_at < CURRENT_TIMESTAMP`
+
+	result, err := s.db.ExecContext(ctx, op.statement(query))
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	return result.RowsAffected()
+}
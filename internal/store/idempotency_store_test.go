@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReserveLease(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresIdempotencyStore(db, QueryTimeouts{})
+	ctx := context.Background()
+	userID := createTestUser(t, db, "alice")
+
+	_, err := db.Exec(`DELETE FROM idempotency_keys WHERE user_id = $1`, userID)
+	require.NoError(t, err)
+
+	record := func(key string, lease time.Duration) *IdempotencyRecord {
+		return &IdempotencyRecord{
+			UserID:      userID,
+			Key:         key,
+			Method:      http.MethodPost,
+			Path:        "/workouts",
+			RequestHash: []byte("push day"),
+			ExpiresAt:   time.Now().Add(time.Hour),
+			LockedUntil: time.Now().Add(lease),
+		}
+	}
+
+	existing, err := store.Reserve(ctx, record("in-flight", time.Minute))
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	existing, err = store.Reserve(ctx, record("in-flight", time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.False(t, existing.Completed)
+
+	// a reservation whose lease ran out is taken over
+	existing, err = store.Reserve(ctx, record("abandoned", -time.Second))
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	existing, err = store.Reserve(ctx, record("abandoned", time.Minute))
+	require.NoError(t, err)
+	assert.Nil(t, existing)
+
+	// but a completed one never is
+	completed := record("completed", -time.Second)
+	_, err = store.Reserve(ctx, completed)
+	require.NoError(t, err)
+	completed.StatusCode = http.StatusCreated
+	require.NoError(t, store.Complete(ctx, completed))
+
+	existing, err = store.Reserve(ctx, record("completed", time.Minute))
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.True(t, existing.Completed)
+	assert.Equal(t, http.StatusCreated, existing.StatusCode)
+}
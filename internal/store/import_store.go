@@ -0,0 +1,272 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Import job statuses.
+const (
+	ImportPending   = "pending"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// ImportRowError is a CSV row that could not be imported.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+// Statuses of a workout in an import report.
+const (
+	ImportWorkoutNew       = "new"
+	ImportWorkoutCreated   = "created"
+	ImportWorkoutDuplicate = "duplicate"
+	ImportWorkoutInvalid   = "invalid"
+	// ImportWorkoutConflict is a workout whose title stayed taken after
+	// numbering it.
+	ImportWorkoutConflict = "conflict"
+)
+
+// ImportWorkout is what happened, or in a dry run would happen, to one
+// workout found in the file.
+type ImportWorkout struct {
+	Title       string       `json:"title"`
+	PerformedAt time.Time    `json:"performed_at"`
+	Entries     int          `json:"entries"`
+	Rows        []int        `json:"rows"`
+	Status      string       `json:"status"`
+	WorkoutID   int          `json:"workout_id,omitempty"`
+	Errors      []FieldError `json:"errors,omitempty"`
+}
+
+type ImportReport struct {
+	Errors             []ImportRowError `json:"errors"`
+	UnmatchedExercises []string         `json:"unmatched_exercises"`
+	Workouts           []ImportWorkout  `json:"workouts"`
+}
+
+type ImportJob struct {
+	ID       int64  `json:"id"`
+	UserID   int    `json:"user_id"`
+	Status   string `json:"status"`
+	Format   string `json:"format"`
+	DryRun   bool   `json:"dry_run"`
+	Filename string `json:"filename"`
+	// Data is the uploaded file. It is only loaded for the worker.
+	Data              []byte        `json:"-"`
+	TotalWorkouts     int           `json:"total_workouts"`
+	ProcessedWorkouts int           `json:"processed_workouts"`
+	CreatedWorkouts   int           `json:"created_workouts"`
+	DuplicateWorkouts int           `json:"duplicate_workouts"`
+	FailedWorkouts    int           `json:"failed_workouts"`
+	Report            *ImportReport `json:"report,omitempty"`
+	Error             string        `json:"error,omitempty"`
+	CreatedAt         time.Time     `json:"created_at"`
+	StartedAt         *time.Time    `json:"started_at"`
+	FinishedAt        *time.Time    `json:"finished_at"`
+}
+
+type ImportStore interface {
+	CreateImport(ctx context.Context, job *ImportJob) error
+	GetImport(ctx context.Context, userID int, id int64) (*ImportJob, error)
+	// ListImports returns the most recent imports of userID.
+	ListImports(ctx context.Context, userID int, limit int) ([]*ImportJob, error)
+	// ClaimImport marks the oldest pending import as running and returns it
+	// with its data, or ErrNotFound when there is none. Running imports
+	// without progress for staleAfter are claimed again, so a crashed worker
+	// does not strand them.
+	ClaimImport(ctx context.Context, staleAfter time.Duration) (*ImportJob, error)
+	UpdateImportProgress(ctx context.Context, job *ImportJob) error
+	// FinishImport stores the final status and report and drops the data.
+	FinishImport(ctx context.Context, job *ImportJob) error
+}
+
+type PostgresImportStore struct {
+	db       *sql.DB
+	timeouts QueryTimeouts
+}
+
+func NewPostgresImportStore(db *sql.DB, timeouts QueryTimeouts) *PostgresImportStore {
+	return &PostgresImportStore{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+const importColumns = `id, user_id, status, format, dry_run, filename, total_workouts, processed_workouts,
+	created_workouts, duplicate_workouts, failed_workouts, report, error, created_at, started_at, finished_at`
+
+func scanImport(row scanner, job *ImportJob, extra ...any) error {
+	var report []byte
+	var jobErr sql.NullString
+
+	dest := []any{
+		&job.ID,
+		&job.UserID,
+		&job.Status,
+		&job.Format,
+		&job.DryRun,
+		&job.Filename,
+		&job.TotalWorkouts,
+		&job.ProcessedWorkouts,
+		&job.CreatedWorkouts,
+		&job.DuplicateWorkouts,
+		&job.FailedWorkouts,
+		&report,
+		&jobErr,
+		&job.CreatedAt,
+		&job.StartedAt,
+		&job.FinishedAt,
+	}
+
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+
+	job.Error = jobErr.String
+	if report != nil {
+		return json.Unmarshal(report, &job.Report)
+	}
+	return nil
+}
+
+func (s *PostgresImportStore) CreateImport(ctx context.Context, job *ImportJob) error {
+	ctx, op := startOperation(ctx, s.timeouts, "import_jobs", "CreateImport")
+	defer op.end()
+
+	query := `
+		INSERT INTO import_jobs (user_id, format, dry_run, filename, data)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at
+	`
+
+	err := s.db.QueryRowContext(ctx, op.statement(query), job.UserID, job.Format, job.DryRun, job.Filename, job.Data).Scan(&job.ID, &job.Status, &job.CreatedAt)
+	return translateError(err)
+}
+
+func (s *PostgresImportStore) GetImport(ctx context.Context, userID int, id int64) (*ImportJob, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "import_jobs", "GetImport")
+	defer op.end()
+
+	query := `
+		SELECT ` + importColumns + `
+		FROM import_jobs
+		WHERE id = $1 AND user_id = $2
+	`
+
+	job := &ImportJob{}
+	err := scanImport(s.db.QueryRowContext(ctx, op.statement(query), id, userID), job)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return job, nil
+}
+
+func (s *PostgresImportStore) ListImports(ctx context.Context, userID int, limit int) ([]*ImportJob, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "import_jobs", "ListImports")
+	defer op.end()
+
+	// reports can be large, the list leaves them out
+	query := `
+		SELECT id, user_id, status, format, dry_run, filename, total_workouts, processed_workouts,
+			created_workouts, duplicate_workouts, failed_workouts, NULL::jsonb, error, created_at, started_at, finished_at
+		FROM import_jobs
+		WHERE user_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`
+
+	rows, err := s.db.QueryContext(ctx, op.statement(query), userID, limit)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	jobs := []*ImportJob{}
+	for rows.Next() {
+		job := &ImportJob{}
+		err = scanImport(rows, job)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, translateError(rows.Err())
+}
+
+func (s *PostgresImportStore) ClaimImport(ctx context.Context, staleAfter time.Duration) (*ImportJob, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "import_jobs", "ClaimImport")
+	defer op.end()
+
+	// SKIP LOCKED lets several workers claim different jobs concurrently
+	query := `
+		UPDATE import_jobs
+		SET status = 'running', started_at = COALESCE(started_at, CURRENT_TIMESTAMP), heartbeat_at = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id
+			FROM import_jobs
+			WHERE status = 'pending' OR (status = 'running' AND heartbeat_at < $1)
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + importColumns + `, data`
+
+	job := &ImportJob{}
+	err := scanImport(s.db.QueryRowContext(ctx, op.statement(query), time.Now().Add(-staleAfter)), job, &job.Data)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return job, nil
+}
+
+func (s *PostgresImportStore) UpdateImportProgress(ctx context.Context, job *ImportJob) error {
+	ctx, op := startOperation(ctx, s.timeouts, "import_jobs", "UpdateImportProgress")
+	defer op.end()
+
+	query := `
+		UPDATE import_jobs
+		SET total_workouts = $1, processed_workouts = $2, created_workouts = $3, duplicate_workouts = $4,
+			failed_workouts = $5, heartbeat_at = CURRENT_TIMESTAMP
+		WHERE id = $6
+	`
+
+	_, err := s.db.ExecContext(ctx, op.statement(query), job.TotalWorkouts, job.ProcessedWorkouts, job.CreatedWorkouts, job.DuplicateWorkouts, job.FailedWorkouts, job.ID)
+	return translateError(err)
+}
+
+func (s *PostgresImportStore) FinishImport(ctx context.Context, job *ImportJob) error {
+	ctx, op := startOperation(ctx, s.timeouts, "import_jobs", "FinishImport")
+	defer op.end()
+
+	var report []byte
+	if job.Report != nil {
+		var err error
+		report, err = json.Marshal(job.Report)
+		if err != nil {
+			return err
+		}
+	}
+
+	query := `
+		UPDATE import_jobs
+		SET status = $1, total_workouts = $2, processed_workouts = $3, created_workouts = $4, duplicate_workouts = $5,
+			failed_workouts = $6, report = $7, error = NULLIF($8, ''), data = ''::bytea, finished_at = CURRENT_TIMESTAMP
+		WHERE id = $9
+		RETURNING finished_at
+	`
+
+	err := s.db.QueryRowContext(ctx, op.statement(query), job.Status, job.TotalWorkouts, job.ProcessedWorkouts, job.CreatedWorkouts,
+		job.DuplicateWorkouts, job.FailedWorkouts, report, job.Error, job.ID).Scan(&job.FinishedAt)
+	return translateError(err)
+}
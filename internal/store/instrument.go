@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"github.com/oki-irawan/fem_project/internal/metrics"
+	"github.com/oki-irawan/fem_project/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+	"time"
+)
+
+// operation tracks a single store method call: it owns a child span of the
+// request span and records the method latency when it ends.
+type operation struct {
+	span       trace.Span
+	store      string
+	method     string
+	start      time.Time
+	statements []string
+	cancel     context.CancelFunc
+}
+
+// startOperation starts instrumenting a store method and applies its query
+// timeout to ctx. Use it as
+//
+//	ctx, op := startOperation(ctx, pg.timeouts, "workouts", "CreateWorkout")
+//	defer op.end()
+func startOperation(ctx context.Context, timeouts QueryTimeouts, store, method string) (context.Context, *operation) {
+	cancel := context.CancelFunc(func() {})
+	if timeout := timeouts.For(store, method); timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, store+"."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", method),
+			attribute.String("db.collection.name", store),
+		),
+	)
+
+	return ctx, &operation{
+		span:   span,
+		store:  store,
+		method: method,
+		start:  time.Now(),
+		cancel: cancel,
+	}
+}
+
+// statement records a SQL statement executed by the operation and returns it
+// unchanged.
+func (o *operation) statement(query string) string {
+	normalized := strings.Join(strings.Fields(query), " ")
+	for _, s := range o.statements {
+		if s == normalized {
+			return query
+		}
+	}
+
+	o.statements = append(o.statements, normalized)
+	return query
+}
+
+func (o *operation) end() {
+	if len(o.statements) > 0 {
+		o.span.SetAttributes(attribute.String("db.query.text", strings.Join(o.statements, "; ")))
+	}
+	o.span.End()
+	o.cancel()
+
+	metrics.StoreQueryDuration.WithLabelValues(o.store, o.method).Observe(time.Since(o.start).Seconds())
+}
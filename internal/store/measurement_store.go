@@ -0,0 +1,229 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Date is a calendar day, written as 2006-01-02 in JSON and stored as a
+// DATE.
+type Date struct {
+	time.Time
+}
+
+// NewDate returns the UTC day of t.
+func NewDate(t time.Time) Date {
+	year, month, day := t.UTC().Date()
+	return Date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Format(time.DateOnly))
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return fmt.Errorf("date must look like 2006-01-02: %w", err)
+	}
+	d.Time = t
+	return nil
+}
+
+func (d *Date) Scan(src any) error {
+	t, ok := src.(time.Time)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into Date", src)
+	}
+	*d = NewDate(t)
+	return nil
+}
+
+func (d Date) Value() (driver.Value, error) {
+	return d.Format(time.DateOnly), nil
+}
+
+// MeasurementValues are the values a body measurement can record. Any of
+// them may be left out.
+type MeasurementValues struct {
+	BodyWeightKg   *float64 `json:"body_weight_kg"`
+	BodyFatPercent *float64 `json:"body_fat_percent"`
+	WaistCm        *float64 `json:"waist_cm"`
+	ChestCm        *float64 `json:"chest_cm"`
+	ArmCm          *float64 `json:"arm_cm"`
+	ThighCm        *float64 `json:"thigh_cm"`
+}
+
+// Fields returns the values with their JSON names, in a fixed order.
+func (mv *MeasurementValues) Fields() []MeasurementField {
+	return []MeasurementField{
+		{"body_weight_kg", &mv.BodyWeightKg},
+		{"body_fat_percent", &mv.BodyFatPercent},
+		{"waist_cm", &mv.WaistCm},
+		{"chest_cm", &mv.ChestCm},
+		{"arm_cm", &mv.ArmCm},
+		{"thigh_cm", &mv.ThighCm},
+	}
+}
+
+// MeasurementField points at one of the MeasurementValues.
+type MeasurementField struct {
+	Name  string
+	Value **float64
+}
+
+// BodyMeasurement is what a user measured on a day.
+type BodyMeasurement struct {
+	ID         int64  `json:"id"`
+	UUID       string `json:"uuid"`
+	UserID     int    `json:"-"`
+	MeasuredOn Date   `json:"measured_on"`
+	MeasurementValues
+	Notes     string    `json:"notes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type MeasurementStore interface {
+	CreateMeasurement(ctx context.Context, measurement *BodyMeasurement) error
+	GetMeasurement(ctx context.Context, userID int, id int64) (*BodyMeasurement, error)
+	// ListMeasurements returns the measurements of userID, oldest first.
+	ListMeasurements(ctx context.Context, userID int) ([]*BodyMeasurement, error)
+	UpdateMeasurement(ctx context.Context, measurement *BodyMeasurement) error
+	DeleteMeasurement(ctx context.Context, userID int, id int64) error
+}
+
+type PostgresMeasurementStore struct {
+	db       *sql.DB
+	timeouts QueryTimeouts
+}
+
+func NewPostgresMeasurementStore(db *sql.DB, timeouts QueryTimeouts) *PostgresMeasurementStore {
+	return &PostgresMeasurementStore{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+const measurementColumns = `id, uuid, user_id, measured_on, body_weight_kg, body_fat_percent, waist_cm, chest_cm, arm_cm, thigh_cm,
+	notes, created_at, updated_at`
+
+func scanMeasurement(row scanner, m *BodyMeasurement) error {
+	err := row.Scan(&m.ID, &m.UUID, &m.UserID, &m.MeasuredOn, &m.BodyWeightKg, &m.BodyFatPercent, &m.WaistCm, &m.ChestCm,
+		&m.ArmCm, &m.ThighCm, &m.Notes, &m.CreatedAt, &m.UpdatedAt)
+	return translateError(err)
+}
+
+func (s *PostgresMeasurementStore) CreateMeasurement(ctx context.Context, m *BodyMeasurement) error {
+	ctx, op := startOperation(ctx, s.timeouts, "body_measurements", "CreateMeasurement")
+	defer op.end()
+
+	query := `
+		INSERT INTO body_measurements (user_id, measured_on, body_weight_kg, body_fat_percent, waist_cm, chest_cm, arm_cm, thigh_cm, notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING ` + measurementColumns
+
+	row := s.db.QueryRowContext(ctx, op.statement(query), m.UserID, m.MeasuredOn, m.BodyWeightKg, m.BodyFatPercent,
+		m.WaistCm, m.ChestCm, m.ArmCm, m.ThighCm, m.Notes)
+	return scanMeasurement(row, m)
+}
+
+func (s *PostgresMeasurementStore) GetMeasurement(ctx context.Context, userID int, id int64) (*BodyMeasurement, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "body_measurements", "GetMeasurement")
+	defer op.end()
+
+	query := `
+		SELECT ` + measurementColumns + `
+		FROM body_measurements
+		WHERE id = $1 AND user_id = $2
+	`
+
+	m := &BodyMeasurement{}
+	err := scanMeasurement(s.db.QueryRowContext(ctx, op.statement(query), id, userID), m)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (s *PostgresMeasurementStore) ListMeasurements(ctx context.Context, userID int) ([]*BodyMeasurement, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "body_measurements", "ListMeasurements")
+	defer op.end()
+
+	query := `
+		SELECT ` + measurementColumns + `
+		FROM body_measurements
+		WHERE user_id = $1
+		ORDER BY measured_on
+	`
+
+	rows, err := s.db.QueryContext(ctx, op.statement(query), userID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	measurements := []*BodyMeasurement{}
+	for rows.Next() {
+		m := &BodyMeasurement{}
+		err = scanMeasurement(rows, m)
+		if err != nil {
+			return nil, err
+		}
+
+		measurements = append(measurements, m)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+
+	return measurements, nil
+}
+
+func (s *PostgresMeasurementStore) UpdateMeasurement(ctx context.Context, m *BodyMeasurement) error {
+	ctx, op := startOperation(ctx, s.timeouts, "body_measurements", "UpdateMeasurement")
+	defer op.end()
+
+	query := `
+		UPDATE body_measurements
+		SET measured_on = $1, body_weight_kg = $2, body_fat_percent = $3, waist_cm = $4, chest_cm = $5,
+			arm_cm = $6, thigh_cm = $7, notes = $8, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $9 AND user_id = $10
+		RETURNING ` + measurementColumns
+
+	row := s.db.QueryRowContext(ctx, op.statement(query), m.MeasuredOn, m.BodyWeightKg, m.BodyFatPercent, m.WaistCm,
+		m.ChestCm, m.ArmCm, m.ThighCm, m.Notes, m.ID, m.UserID)
+	return scanMeasurement(row, m)
+}
+
+func (s *PostgresMeasurementStore) DeleteMeasurement(ctx context.Context, userID int, id int64) error {
+	ctx, op := startOperation(ctx, s.timeouts, "body_measurements", "DeleteMeasurement")
+	defer op.end()
+
+	query := `DELETE FROM body_measurements WHERE id = $1 AND user_id = $2`
+
+	result, err := s.db.ExecContext(ctx, op.statement(query), id, userID)
+	if err != nil {
+		return translateError(err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return translateError(err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
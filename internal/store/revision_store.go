@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Revision actions.
+const (
+	RevisionCreate = "create"
+	RevisionUpdate = "update"
+	RevisionRevert = "revert"
+	// RevisionTrash and RevisionRestore record moving a workout to the
+	// trash and back. Their snapshot is the workout as it was trashed.
+	RevisionTrash   = "trash"
+	RevisionRestore = "restore"
+)
+
+// Revision is an immutable copy of a workout as it was saved at Version.
+// Every version of a workout has exactly one revision.
+type Revision struct {
+	WorkoutID int    `json:"workout_id"`
+	Version   int    `json:"version"`
+	AuthorID  *int   `json:"author_id"`
+	Action    string `json:"action"`
+	// RevertedFrom is the version a revert went back to.
+	RevertedFrom *int      `json:"reverted_from,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	Snapshot     *Workout  `json:"snapshot,omitempty"`
+}
+
+type RevisionStore interface {
+	// ListRevisions returns the revisions of a workout, newest first and
+	// without snapshots.
+	ListRevisions(ctx context.Context, workoutID int64) ([]Revision, error)
+	GetRevision(ctx context.Context, workoutID int64, version int) (*Revision, error)
+}
+
+type PostgresRevisionStore struct {
+	db       *sql.DB
+	timeouts QueryTimeouts
+}
+
+func NewPostgresRevisionStore(db *sql.DB, timeouts QueryTimeouts) *PostgresRevisionStore {
+	return &PostgresRevisionStore{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+// recordRevision stores workout as its current version. It runs in the
+// transaction that saved the workout, so history never misses a write.
+func recordRevision(ctx context.Context, q querier, op *operation, workout *Workout, action string, revertedFrom *int) error {
+	snapshot := *workout
+	snapshot.DeletedAt = nil
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	// only owners can write workouts for now, so the owner is the author
+	query := `
+		INSERT INTO workout_revisions (workout_id, version, author_id, action, reverted_from, snapshot)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err = q.ExecContext(ctx, op.statement(query), workout.ID, workout.Version, workout.UserID, action, revertedFrom, data)
+	return translateError(err)
+}
+
+func (s *PostgresRevisionStore) ListRevisions(ctx context.Context, workoutID int64) ([]Revision, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "workout_revisions", "ListRevisions")
+	defer op.end()
+
+	query := `
+		SELECT workout_id, version, author_id, action, reverted_from, created_at
+		FROM workout_revisions
+		WHERE workout_id = $1
+		ORDER BY version DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, op.statement(query), workoutID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	revisions := []Revision{}
+	for rows.Next() {
+		var revision Revision
+		err = rows.Scan(&revision.WorkoutID, &revision.Version, &revision.AuthorID, &revision.Action, &revision.RevertedFrom, &revision.CreatedAt)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, translateError(rows.Err())
+}
+
+func (s *PostgresRevisionStore) GetRevision(ctx context.Context, workoutID int64, version int) (*Revision, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "workout_revisions", "GetRevision")
+	defer op.end()
+
+	query := `
+		SELECT workout_id, version, author_id, action, reverted_from, created_at, snapshot
+		FROM workout_revisions
+		WHERE workout_id = $1 AND version = $2
+	`
+
+	revision := &Revision{}
+	var snapshot []byte
+
+	err := s.db.QueryRowContext(ctx, op.statement(query), workoutID, version).Scan(
+		&revision.WorkoutID,
+		&revision.Version,
+		&revision.AuthorID,
+		&revision.Action,
+		&revision.RevertedFrom,
+		&revision.CreatedAt,
+		&snapshot,
+	)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	err = json.Unmarshal(snapshot, &revision.Snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return revision, nil
+}
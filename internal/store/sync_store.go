@@ -0,0 +1,358 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SyncCursor is a position in a user's change feed. Changes are ordered by
+// the transaction that made them first, so a transaction that commits late
+// never lands behind a cursor a client already holds.
+type SyncCursor struct {
+	TxID int64
+	Seq  int64
+}
+
+// Deletion is a workout, entry, body measurement or goal that was deleted.
+// Workouts are also deleted by moving them to the trash.
+type Deletion struct {
+	Entity    string    `json:"entity"`
+	UUID      string    `json:"uuid"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// ChangeSet is one page of the change feed. Workouts are returned whole, with
+// all of their entries; Deletions should be applied before the rest.
+type ChangeSet struct {
+	Workouts     []*Workout
+	Measurements []*BodyMeasurement
+	Goals        []*Goal
+	Deletions    []Deletion
+	Cursor       SyncCursor
+	HasMore      bool
+}
+
+type SyncStore interface {
+	// ListChanges returns up to limit changes to the data of userID made
+	// after since. It returns ErrCursorExpired when tombstones the client
+	// has not seen yet were pruned.
+	ListChanges(ctx context.Context, userID int, since SyncCursor, limit int) (*ChangeSet, error)
+	// PruneTombstones deletes tombstones older than olderThan and returns
+	// how many were deleted.
+	PruneTombstones(ctx context.Context, olderThan time.Time) (int64, error)
+}
+
+type PostgresSyncStore struct {
+	db       *sql.DB
+	timeouts QueryTimeouts
+}
+
+func NewPostgresSyncStore(db *sql.DB, timeouts QueryTimeouts) *PostgresSyncStore {
+	return &PostgresSyncStore{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+func (s *PostgresSyncStore) ListChanges(ctx context.Context, userID int, since SyncCursor, limit int) (*ChangeSet, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "sync", "ListChanges")
+	defer op.end()
+
+	// one snapshot for the feed and the workouts it points at
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer tx.Rollback()
+
+	if since != (SyncCursor{}) {
+		var expired bool
+		query := `
+			SELECT EXISTS (
+				SELECT 1
+				FROM sync_horizons
+				WHERE user_id = $1 AND (change_txid, change_seq) > ($2, $3)
+			)
+		`
+
+		err = tx.QueryRowContext(ctx, op.statement(query), userID, since.TxID, since.Seq).Scan(&expired)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		if expired {
+			return nil, ErrCursorExpired
+		}
+	}
+
+	// changes of transactions that may still be running are held back until
+	// every older transaction has finished
+	query := `
+		SELECT kind, uuid, id, deleted_at, change_txid, change_seq
+		FROM (
+			SELECT 'workout' AS kind, uuid, CASE WHEN deleted_at IS NULL THEN id END AS id, deleted_at, change_txid, change_seq
+			FROM workouts
+			WHERE user_id = $1
+			UNION ALL
+			SELECT 'body_measurement', uuid, id, NULL, change_txid, change_seq
+			FROM body_measurements
+			WHERE user_id = $1
+			UNION ALL
+			SELECT 'goal', uuid, id, NULL, change_txid, change_seq
+			FROM goals
+			WHERE user_id = $1
+			UNION ALL
+			SELECT entity, uuid, NULL, deleted_at, change_txid, change_seq
+			FROM sync_tombstones
+			WHERE user_id = $1
+		) changes
+		WHERE (change_txid, change_seq) > ($2, $3)
+			AND change_txid < txid_snapshot_xmin(txid_current_snapshot())
+		ORDER BY change_txid, change_seq
+		LIMIT $4
+	`
+
+	rows, err := tx.QueryContext(ctx, op.statement(query), userID, since.TxID, since.Seq, limit+1)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	changes := &ChangeSet{Cursor: since}
+	// the ids of the changed rows still there, by kind
+	ids := map[string][]int64{}
+	count := 0
+
+	for rows.Next() {
+		if count == limit {
+			changes.HasMore = true
+			break
+		}
+		count++
+
+		var kind, uuid string
+		var id sql.NullInt64
+		var deletedAt sql.NullTime
+
+		err = rows.Scan(&kind, &uuid, &id, &deletedAt, &changes.Cursor.TxID, &changes.Cursor.Seq)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		if id.Valid {
+			ids[kind] = append(ids[kind], id.Int64)
+		} else {
+			changes.Deletions = append(changes.Deletions, Deletion{Entity: kind, UUID: uuid, DeletedAt: deletedAt.Time})
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+	rows.Close()
+
+	changes.Workouts, err = loadWorkouts(ctx, tx, op, ids["workout"])
+	if err != nil {
+		return nil, err
+	}
+
+	changes.Measurements, err = loadMeasurements(ctx, tx, op, ids["body_measurement"])
+	if err != nil {
+		return nil, err
+	}
+
+	changes.Goals, err = loadGoals(ctx, tx, op, ids["goal"])
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+func (s *PostgresSyncStore) PruneTombstones(ctx context.Context, olderThan time.Time) (int64, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "sync", "PruneTombstones")
+	defer op.end()
+
+	// the horizon moves to the newest pruned tombstone of each user
+	query := `
+		WITH pruned AS (
+			DELETE FROM sync_tombstones
+			WHERE deleted_at < $1
+			RETURNING user_id, change_txid, change_seq
+		), horizons AS (
+			INSERT INTO sync_horizons (user_id, change_txid, change_seq)
+			SELECT DISTINCT ON (user_id) user_id, change_txid, change_seq
+			FROM pruned
+			ORDER BY user_id, change_txid DESC, change_seq DESC
+			ON CONFLICT (user_id) DO UPDATE
+			SET change_txid = EXCLUDED.change_txid, change_seq = EXCLUDED.change_seq
+			WHERE (EXCLUDED.change_txid, EXCLUDED.change_seq) > (sync_horizons.change_txid, sync_horizons.change_seq)
+		)
+		SELECT COUNT(*) FROM pruned
+	`
+
+	var pruned int64
+	err := s.db.QueryRowContext(ctx, op.statement(query), olderThan).Scan(&pruned)
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	return pruned, nil
+}
+
+// loadWorkouts loads the workouts with ids, in the same order, together with
+// their entries.
+func loadWorkouts(ctx context.Context, q querier, op *operation, ids []int64) ([]*Workout, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT ` + workoutColumns + `
+		FROM workouts
+		WHERE id = ANY($1)
+	`
+
+	rows, err := q.QueryContext(ctx, op.statement(query), ids)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	byID := make(map[int]*Workout, len(ids))
+	for rows.Next() {
+		workout := &Workout{Entries: []WorkoutEntries{}}
+		err = scanWorkout(rows, workout)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		byID[workout.ID] = workout
+	}
+	if err = rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+	rows.Close()
+
+	query = `
+		SELECT ` + entryColumns + `, workout_id
+		FROM workout_entries
+		WHERE workout_id = ANY($1)
+		ORDER BY workout_id, order_index
+	`
+
+	rows, err = q.QueryContext(ctx, op.statement(query), ids)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry WorkoutEntries
+		var workoutID int
+
+		err = scanEntry(rows, &entry, &workoutID)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		if workout, ok := byID[workoutID]; ok {
+			workout.Entries = append(workout.Entries, entry)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+
+	workouts := make([]*Workout, 0, len(ids))
+	for _, id := range ids {
+		if workout, ok := byID[int(id)]; ok {
+			workouts = append(workouts, workout)
+		}
+	}
+
+	return workouts, nil
+}
+
+// loadMeasurements loads the body measurements with ids, in the same order.
+func loadMeasurements(ctx context.Context, q querier, op *operation, ids []int64) ([]*BodyMeasurement, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT ` + measurementColumns + `
+		FROM body_measurements
+		WHERE id = ANY($1)
+	`
+
+	rows, err := q.QueryContext(ctx, op.statement(query), ids)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	byID := make(map[int64]*BodyMeasurement, len(ids))
+	for rows.Next() {
+		m := &BodyMeasurement{}
+		err = scanMeasurement(rows, m)
+		if err != nil {
+			return nil, err
+		}
+
+		byID[m.ID] = m
+	}
+	if err = rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+
+	measurements := make([]*BodyMeasurement, 0, len(ids))
+	for _, id := range ids {
+		if m, ok := byID[id]; ok {
+			measurements = append(measurements, m)
+		}
+	}
+
+	return measurements, nil
+}
+
+// loadGoals loads the goals with ids, in the same order.
+func loadGoals(ctx context.Context, q querier, op *operation, ids []int64) ([]*Goal, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT ` + goalColumns + `
+		FROM goals
+		WHERE id = ANY($1)
+	`
+
+	rows, err := q.QueryContext(ctx, op.statement(query), ids)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	byID := make(map[int64]*Goal, len(ids))
+	for rows.Next() {
+		g := &Goal{}
+		err = scanGoal(rows, g)
+		if err != nil {
+			return nil, err
+		}
+
+		byID[g.ID] = g
+	}
+	if err = rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+
+	goals := make([]*Goal, 0, len(ids))
+	for _, id := range ids {
+		if g, ok := byID[id]; ok {
+			goals = append(goals, g)
+		}
+	}
+
+	return goals, nil
+}
@@ -0,0 +1,145 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestListChanges(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	workouts := NewPostgresWorkoutStore(db, QueryTimeouts{})
+	sync := NewPostgresSyncStore(db, QueryTimeouts{})
+	ctx := context.Background()
+	userID := createTestUser(t, db, "sync")
+
+	_, err := db.Exec(`DELETE FROM sync_tombstones WHERE user_id = $1`, userID)
+	require.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM sync_horizons WHERE user_id = $1`, userID)
+	require.NoError(t, err)
+
+	var created []*Workout
+	for _, title := range []string{"Push Day", "Pull Day", "Leg Day"} {
+		workout, err := workouts.CreateWorkout(ctx, &Workout{UserID: userID, Title: title, DurationMinutes: 45})
+		require.NoError(t, err)
+		created = append(created, workout)
+	}
+
+	// a full sync in pages
+	page, err := sync.ListChanges(ctx, userID, SyncCursor{}, 2)
+	require.NoError(t, err)
+	require.Len(t, page.Workouts, 2)
+	assert.Equal(t, created[0].UUID, page.Workouts[0].UUID)
+	assert.Equal(t, created[1].UUID, page.Workouts[1].UUID)
+	assert.True(t, page.HasMore)
+
+	page, err = sync.ListChanges(ctx, userID, page.Cursor, 2)
+	require.NoError(t, err)
+	require.Len(t, page.Workouts, 1)
+	assert.Equal(t, created[2].UUID, page.Workouts[0].UUID)
+	assert.False(t, page.HasMore)
+	synced := page.Cursor
+
+	page, err = sync.ListChanges(ctx, userID, synced, 2)
+	require.NoError(t, err)
+	assert.Empty(t, page.Workouts)
+	assert.Empty(t, page.Deletions)
+	assert.Equal(t, synced, page.Cursor)
+
+	// an update and a move to the trash
+	updated := created[0]
+	updated.Title = "Push Day Heavy"
+	require.NoError(t, workouts.UpdateWorkout(ctx, updated))
+	require.NoError(t, workouts.DeleteWorkout(ctx, int64(created[1].ID)))
+
+	page, err = sync.ListChanges(ctx, userID, synced, 10)
+	require.NoError(t, err)
+	require.Len(t, page.Workouts, 1)
+	assert.Equal(t, "Push Day Heavy", page.Workouts[0].Title)
+	require.Len(t, page.Deletions, 1)
+	assert.Equal(t, Deletion{Entity: "workout", UUID: created[1].UUID, DeletedAt: page.Deletions[0].DeletedAt}, page.Deletions[0])
+	synced = page.Cursor
+
+	// purging the trash comes back as a tombstone
+	_, err = workouts.PurgeTrash(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	page, err = sync.ListChanges(ctx, userID, synced, 10)
+	require.NoError(t, err)
+	assert.Empty(t, page.Workouts)
+	require.Len(t, page.Deletions, 1)
+	assert.Equal(t, created[1].UUID, page.Deletions[0].UUID)
+	latest := page.Cursor
+
+	// once the tombstone is pruned, only cursors that saw it can go on
+	pruned, err := sync.PruneTombstones(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, pruned, int64(1))
+
+	_, err = sync.ListChanges(ctx, userID, synced, 10)
+	assert.ErrorIs(t, err, ErrCursorExpired)
+
+	page, err = sync.ListChanges(ctx, userID, latest, 10)
+	require.NoError(t, err)
+	assert.Empty(t, page.Deletions)
+
+	page, err = sync.ListChanges(ctx, userID, SyncCursor{}, 10)
+	require.NoError(t, err)
+	assert.Len(t, page.Workouts, 2)
+	assert.Empty(t, page.Deletions)
+
+	// body measurements and goals are in the feed too
+	measurements := NewPostgresMeasurementStore(db, QueryTimeouts{})
+	goals := NewPostgresGoalStore(db, QueryTimeouts{})
+	_, err = db.Exec(`DELETE FROM body_measurements WHERE user_id = $1`, userID)
+	require.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM goals WHERE user_id = $1`, userID)
+	require.NoError(t, err)
+	page, err = sync.ListChanges(ctx, userID, latest, 10)
+	require.NoError(t, err)
+	latest = page.Cursor
+
+	weight := 80.5
+	measurement := &BodyMeasurement{UserID: userID, MeasuredOn: NewDate(time.Now()), MeasurementValues: MeasurementValues{BodyWeightKg: &weight}}
+	require.NoError(t, measurements.CreateMeasurement(ctx, measurement))
+	goal := &Goal{UserID: userID, Type: GoalWorkouts, Title: "Train often", Target: 12, StartsOn: NewDate(time.Now())}
+	require.NoError(t, goals.CreateGoal(ctx, goal))
+
+	page, err = sync.ListChanges(ctx, userID, latest, 10)
+	require.NoError(t, err)
+	require.Len(t, page.Measurements, 1)
+	assert.Equal(t, measurement.UUID, page.Measurements[0].UUID)
+	require.Len(t, page.Goals, 1)
+	assert.Equal(t, goal.UUID, page.Goals[0].UUID)
+	latest = page.Cursor
+
+	// the goal tracker checking a goal is not a change
+	require.NoError(t, goals.MarkGoalChecked(ctx, goal.ID))
+	page, err = sync.ListChanges(ctx, userID, latest, 10)
+	require.NoError(t, err)
+	assert.Empty(t, page.Goals)
+
+	require.NoError(t, measurements.DeleteMeasurement(ctx, userID, measurement.ID))
+	require.NoError(t, goals.DeleteGoal(ctx, userID, goal.ID))
+
+	page, err = sync.ListChanges(ctx, userID, latest, 10)
+	require.NoError(t, err)
+	assert.Empty(t, page.Measurements)
+	assert.Empty(t, page.Goals)
+	require.Len(t, page.Deletions, 2)
+	assert.Equal(t, "body_measurement", page.Deletions[0].Entity)
+	assert.Equal(t, measurement.UUID, page.Deletions[0].UUID)
+	assert.Equal(t, "goal", page.Deletions[1].Entity)
+	assert.Equal(t, goal.UUID, page.Deletions[1].UUID)
+
+	// other users keep their cursors
+	otherID := createTestUser(t, db, "sync-other")
+	_, err = db.Exec(`DELETE FROM sync_horizons WHERE user_id = $1`, otherID)
+	require.NoError(t, err)
+	_, err = sync.ListChanges(ctx, otherID, synced, 10)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QueryTimeouts bounds how long a store method may hold a connection. The
+// deadline applies on top of the caller's context, so a cancelled request
+// still stops the query earlier.
+type QueryTimeouts struct {
+	Default time.Duration
+	// Overrides are keyed by "<store>.<method>", e.g. "workouts.CreateWorkout".
+	Overrides map[string]time.Duration
+}
+
+func (t QueryTimeouts) For(store, method string) time.Duration {
+	if d, ok := t.Overrides[store+"."+method]; ok {
+		return d
+	}
+	return t.Default
+}
+
+// ParseTimeoutOverrides parses a comma separated list of store.Method=duration
+// pairs, e.g. "workouts.CreateWorkout=10s,users.GetUserToken=500ms".
+func ParseTimeoutOverrides(s string) (map[string]time.Duration, error) {
+	overrides := make(map[string]time.Duration)
+	if strings.TrimSpace(s) == "" {
+		return overrides, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || !strings.Contains(key, ".") {
+			return nil, fmt.Errorf("invalid query timeout %q, want store.Method=duration", pair)
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query timeout %q: %w", pair, err)
+		}
+
+		overrides[key] = d
+	}
+
+	return overrides, nil
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TitleAttempts bounds the numbered titles SaveWithNumberedTitle tries.
+const TitleAttempts = 10
+
+// SaveWithNumberedTitle calls save until the title of workout is free, for
+// workouts whose title is made up rather than chosen by the user. After the
+// first attempt the title is numbered, "Morning Run #2", "Morning Run #3" and
+// so on. Other errors, and the conflict of the last attempt, are returned.
+func SaveWithNumberedTitle(workout *Workout, attempts int, save func() error) error {
+	base := []rune(workout.Title)
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			suffix := fmt.Sprintf(" #%d", attempt)
+			title := base
+			if limit := 255 - len(suffix); len(title) > limit {
+				title = title[:limit]
+			}
+			workout.Title = string(title) + suffix
+		}
+		// a failed attempt must not leave identifiers behind
+		workout.ID, workout.UUID = 0, ""
+
+		err := save()
+
+		var conflictErr *ConflictError
+		if errors.As(err, &conflictErr) && conflictErr.Field == "title" && attempt < attempts {
+			continue
+		}
+		return err
+	}
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestSaveWithNumberedTitle(t *testing.T) {
+	taken := &ConflictError{Constraint: "workouts_title_key", Field: "title"}
+	reset := errors.New("connection reset")
+	long := strings.Repeat("é", 255)
+
+	test := []struct {
+		name      string
+		title     string
+		failures  []error
+		wantTitle string
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "Free", title: "Morning Run", wantTitle: "Morning Run", wantCalls: 1},
+		{name: "Taken Twice", title: "Morning Run", failures: []error{taken, taken}, wantTitle: "Morning Run #3", wantCalls: 3},
+		{name: "Long Title", title: long, failures: []error{taken}, wantTitle: strings.Repeat("é", 252) + " #2", wantCalls: 2},
+		{
+			name: "Gives Up", title: "Morning Run", failures: []error{taken, taken, taken},
+			wantTitle: "Morning Run #3", wantErr: ErrConflict, wantCalls: 3,
+		},
+		{
+			name: "Other Conflict", title: "Morning Run", failures: []error{&ConflictError{Constraint: "workouts_uuid_key", Field: "uuid"}},
+			wantTitle: "Morning Run", wantErr: ErrConflict, wantCalls: 1,
+		},
+		{name: "Other Error", title: "Morning Run", failures: []error{reset}, wantTitle: "Morning Run", wantErr: reset, wantCalls: 1},
+	}
+
+	for _, tt := range test {
+		t.Run(tt.name, func(t *testing.T) {
+			workout := &Workout{ID: 4, UUID: "2f1c9a52-8d3e-4b7a-9c61-0e5d4f3a2b10", Title: tt.title}
+			calls := 0
+
+			err := SaveWithNumberedTitle(workout, 3, func() error {
+				assert.Zero(t, workout.ID)
+				assert.Empty(t, workout.UUID)
+				calls++
+				if calls <= len(tt.failures) {
+					return tt.failures[calls-1]
+				}
+				return nil
+			})
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantTitle, workout.Title)
+			assert.Equal(t, tt.wantCalls, calls)
+		})
+	}
+}
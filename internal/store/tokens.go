@@ -1,49 +1,65 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"github.com/oki-irawan/fem_project/internal/tokens"
 	"time"
 )
 
 type PostgresTokenStore struct {
-	db *sql.DB
+	db       *sql.DB
+	timeouts QueryTimeouts
 }
 
-func NewPostgresTokenStore(db *sql.DB) *PostgresTokenStore {
+func NewPostgresTokenStore(db *sql.DB, timeouts QueryTimeouts) *PostgresTokenStore {
 	return &PostgresTokenStore{
-		db: db,
+		db:       db,
+		timeouts: timeouts,
 	}
 }
 
 type TokenStore interface {
-	Insert(token *tokens.Token) error
-	CreateNewToken(userID int64, ttl time.Duration, scope string) (*tokens.Token, error)
-	DeleteAllTokensForUser(userID int64, scope string) error
+	Insert(ctx context.Context, token *tokens.Token) error
+	CreateNewToken(ctx context.Context, userID int64, ttl time.Duration, scope string) (*tokens.Token, error)
+	DeleteAllTokensForUser(ctx context.Context, userID int64, scope string) error
 }
 
-func (p *PostgresTokenStore) CreateNewToken(userID int64, ttl time.Duration, scope string) (*tokens.Token, error) {
+func (p *PostgresTokenStore) CreateNewToken(ctx context.Context, userID int64, ttl time.Duration, scope string) (*tokens.Token, error) {
+	ctx, op := startOperation(ctx, p.timeouts, "tokens", "CreateNewToken")
+	defer op.end()
+
 	token, err := tokens.GenerateToken(userID, ttl, scope)
 	if err != nil {
 		return nil, err
 	}
 
-	err = p.Insert(token)
+	err = p.Insert(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
 	return token, nil
 }
 
-func (p *PostgresTokenStore) Insert(token *tokens.Token) error {
+func (p *PostgresTokenStore) Insert(ctx context.Context, token *tokens.Token) error {
+	ctx, op := startOperation(ctx, p.timeouts, "tokens", "Insert")
+	defer op.end()
+
 	query := `
 		INSERT INTO tokens (hash, user_id, expiry, scope)
 		VALUES ($1, $2, $3, $4)
 	`
-	_, err := p.db.Exec(query, token.Hash, token.UserID, token.Expiry, token.Scope)
-	return err
+	_, err := p.db.ExecContext(ctx, op.statement(query), token.Hash, token.UserID, time.Unix(token.Expiry, 0), token.Scope)
+	return translateError(err)
 }
 
-func (p *PostgresTokenStore) DeleteAllTokensForUser(userID int64, scope string) error {
+func (p *PostgresTokenStore) DeleteAllTokensForUser(ctx context.Context, userID int64, scope string) error {
+	ctx, op := startOperation(ctx, p.timeouts, "tokens", "DeleteAllTokensForUser")
+	defer op.end()
+
 	query := `DELETE FROM tokens WHERE scope = $1 AND user_id = $2`
 
-	_, err := p.db.Exec(query, scope, userID)
-	return err
+	_, err := p.db.ExecContext(ctx, op.statement(query), scope, userID)
+	return translateError(err)
 }
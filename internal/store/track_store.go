@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// TrackPoint is one sample of a recorded activity. Devices leave out what
+// they do not measure, e.g. an indoor run has no position.
+type TrackPoint struct {
+	Time      time.Time `json:"time"`
+	Lat       *float64  `json:"lat,omitempty"`
+	Lon       *float64  `json:"lon,omitempty"`
+	Elevation *float64  `json:"ele,omitempty"`
+	HeartRate *int      `json:"hr,omitempty"`
+	// Distance is the distance covered since the start, as the device
+	// measured it.
+	Distance *float64 `json:"dist,omitempty"`
+}
+
+type TrackSummary struct {
+	StartedAt           time.Time `json:"started_at"`
+	ElapsedSeconds      int       `json:"elapsed_seconds"`
+	MovingSeconds       int       `json:"moving_seconds"`
+	DistanceMeters      float64   `json:"distance_meters"`
+	ElevationGainMeters float64   `json:"elevation_gain_meters"`
+	ElevationLossMeters float64   `json:"elevation_loss_meters"`
+	AvgHeartRate        *int      `json:"avg_heart_rate"`
+	MaxHeartRate        *int      `json:"max_heart_rate"`
+}
+
+// Track is the recording a workout was imported from.
+type Track struct {
+	WorkoutID int          `json:"workout_id"`
+	Format    string       `json:"format"`
+	Sport     string       `json:"sport"`
+	Summary   TrackSummary `json:"summary"`
+	// Points are served as GeoJSON, not with the track.
+	Points []TrackPoint `json:"-"`
+}
+
+type TrackStore interface {
+	// CreateWorkoutWithTrack creates workout and stores track for it in one
+	// transaction.
+	CreateWorkoutWithTrack(ctx context.Context, workout *Workout, track *Track) error
+	GetTrack(ctx context.Context, workoutID int64) (*Track, error)
+}
+
+type PostgresTrackStore struct {
+	db       *sql.DB
+	timeouts QueryTimeouts
+}
+
+func NewPostgresTrackStore(db *sql.DB, timeouts QueryTimeouts) *PostgresTrackStore {
+	return &PostgresTrackStore{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+func (s *PostgresTrackStore) CreateWorkoutWithTrack(ctx context.Context, workout *Workout, track *Track) error {
+	ctx, op := startOperation(ctx, s.timeouts, "workout_tracks", "CreateWorkoutWithTrack")
+	defer op.end()
+
+	points, err := json.Marshal(track.Points)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return translateError(err)
+	}
+	defer tx.Rollback()
+
+	err = createWorkout(ctx, tx, op, workout)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO workout_tracks (workout_id, format, sport, started_at, elapsed_seconds, moving_seconds, distance_meters,
+			elevation_gain_meters, elevation_loss_meters, avg_heart_rate, max_heart_rate, points)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+
+	summary := &track.Summary
+	_, err = tx.ExecContext(ctx, op.statement(query), workout.ID, track.Format, track.Sport, summary.StartedAt, summary.ElapsedSeconds,
+		summary.MovingSeconds, summary.DistanceMeters, summary.ElevationGainMeters, summary.ElevationLossMeters,
+		summary.AvgHeartRate, summary.MaxHeartRate, points)
+	if err != nil {
+		return translateError(err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return translateError(err)
+	}
+
+	track.WorkoutID = workout.ID
+	return nil
+}
+
+func (s *PostgresTrackStore) GetTrack(ctx context.Context, workoutID int64) (*Track, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "workout_tracks", "GetTrack")
+	defer op.end()
+
+	query := `
+		SELECT t.workout_id, t.format, t.sport, t.started_at, t.elapsed_seconds, t.moving_seconds, t.distance_meters,
+			t.elevation_gain_meters, t.elevation_loss_meters, t.avg_heart_rate, t.max_heart_rate, t.points
+		FROM workout_tracks t
+		INNER JOIN workouts w ON w.id = t.workout_id
+		WHERE t.workout_id = $1 AND w.deleted_at IS NULL
+	`
+
+	track := &Track{}
+	summary := &track.Summary
+	var points []byte
+
+	err := s.db.QueryRowContext(ctx, op.statement(query), workoutID).Scan(&track.WorkoutID, &track.Format, &track.Sport,
+		&summary.StartedAt, &summary.ElapsedSeconds, &summary.MovingSeconds, &summary.DistanceMeters,
+		&summary.ElevationGainMeters, &summary.ElevationLossMeters, &summary.AvgHeartRate, &summary.MaxHeartRate, &points)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	err = json.Unmarshal(points, &track.Points)
+	if err != nil {
+		return nil, err
+	}
+
+	return track, nil
+}
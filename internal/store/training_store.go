@@ -0,0 +1,301 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Ways of deriving heart rate zones.
+const (
+	// ZonesFromMax splits the range up to the max heart rate.
+	ZonesFromMax = "max"
+	// ZonesFromReserve splits the heart rate reserve, max minus resting
+	// (Karvonen).
+	ZonesFromReserve = "reserve"
+	// ZonesFromThreshold places the zones around the lactate threshold
+	// heart rate (Friel).
+	ZonesFromThreshold = "lthr"
+)
+
+// HeartRateSettings are what the heart rate zones of a user are derived
+// from. Which heart rates are required depends on Method.
+type HeartRateSettings struct {
+	UserID                    int       `json:"-"`
+	Method                    string    `json:"method"`
+	MaxHeartRate              *int      `json:"max_heart_rate"`
+	RestingHeartRate          *int      `json:"resting_heart_rate"`
+	LactateThresholdHeartRate *int      `json:"lactate_threshold_heart_rate"`
+	UpdatedAt                 time.Time `json:"updated_at"`
+}
+
+// TrainingLoad is the heart rate analysis of a workout.
+type TrainingLoad struct {
+	WorkoutID      int `json:"workout_id"`
+	WorkoutVersion int `json:"workout_version"`
+	// Method is the TRIMP formula used.
+	Method string `json:"method"`
+	// ZoneSeconds is the time spent in each zone, zone 1 first.
+	ZoneSeconds []int     `json:"zone_seconds"`
+	TRIMP       float64   `json:"trimp"`
+	ComputedAt  time.Time `json:"computed_at"`
+}
+
+// DailyTrainingLoad is the total training load of the workouts of a UTC day.
+type DailyTrainingLoad struct {
+	Date     time.Time `json:"date"`
+	TRIMP    float64   `json:"trimp"`
+	Workouts int       `json:"workouts"`
+}
+
+type TrainingStore interface {
+	GetHeartRateSettings(ctx context.Context, userID int) (*HeartRateSettings, error)
+	PutHeartRateSettings(ctx context.Context, settings *HeartRateSettings) error
+	// ListStaleTrainingLoads returns up to limit workouts whose training
+	// load is missing or out of date, because the workout or the settings
+	// of its owner changed since it was computed. Workouts that failed to
+	// compute come last, least recently failed first.
+	ListStaleTrainingLoads(ctx context.Context, limit int) ([]int64, error)
+	// SaveTrainingLoad stores load as computed from settings.
+	SaveTrainingLoad(ctx context.Context, load *TrainingLoad, settings *HeartRateSettings) error
+	DeleteTrainingLoad(ctx context.Context, workoutID int64) error
+	// MarkTrainingLoadFailed records that the training load of workoutID
+	// failed to compute. Saving or deleting its load clears the mark.
+	MarkTrainingLoadFailed(ctx context.Context, workoutID int64) error
+	GetTrainingLoad(ctx context.Context, workoutID int64) (*TrainingLoad, error)
+	// DailyTrainingLoads returns the load of userID per day with workouts
+	// in r, oldest first.
+	DailyTrainingLoads(ctx context.Context, userID int, r TimeRange) ([]DailyTrainingLoad, error)
+}
+
+type PostgresTrainingStore struct {
+	db       *sql.DB
+	timeouts QueryTimeouts
+}
+
+func NewPostgresTrainingStore(db *sql.DB, timeouts QueryTimeouts) *PostgresTrainingStore {
+	return &PostgresTrainingStore{
+		db:       db,
+		timeouts: timeouts,
+	}
+}
+
+func (s *PostgresTrainingStore) GetHeartRateSettings(ctx context.Context, userID int) (*HeartRateSettings, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "heart_rate_settings", "GetHeartRateSettings")
+	defer op.end()
+
+	query := `
+		SELECT user_id, method, max_heart_rate, resting_heart_rate, lactate_threshold_heart_rate, updated_at
+		FROM heart_rate_settings
+		WHERE user_id = $1
+	`
+
+	settings := &HeartRateSettings{}
+	err := s.db.QueryRowContext(ctx, op.statement(query), userID).Scan(&settings.UserID, &settings.Method,
+		&settings.MaxHeartRate, &settings.RestingHeartRate, &settings.LactateThresholdHeartRate, &settings.UpdatedAt)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return settings, nil
+}
+
+func (s *PostgresTrainingStore) PutHeartRateSettings(ctx context.Context, settings *HeartRateSettings) error {
+	ctx, op := startOperation(ctx, s.timeouts, "heart_rate_settings", "PutHeartRateSettings")
+	defer op.end()
+
+	query := `
+		INSERT INTO heart_rate_settings (user_id, method, max_heart_rate, resting_heart_rate, lactate_threshold_heart_rate)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE
+		SET method = EXCLUDED.method,
+			max_heart_rate = EXCLUDED.max_heart_rate,
+			resting_heart_rate = EXCLUDED.resting_heart_rate,
+			lactate_threshold_heart_rate = EXCLUDED.lactate_threshold_heart_rate,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING updated_at
+	`
+
+	err := s.db.QueryRowContext(ctx, op.statement(query), settings.UserID, settings.Method, settings.MaxHeartRate,
+		settings.RestingHeartRate, settings.LactateThresholdHeartRate).Scan(&settings.UpdatedAt)
+	return translateError(err)
+}
+
+func (s *PostgresTrainingStore) ListStaleTrainingLoads(ctx context.Context, limit int) ([]int64, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "workout_training_loads", "ListStaleTrainingLoads")
+	defer op.end()
+
+	// a workout without heart rate data only needs a visit when it lost the
+	// data it had, so its old load gets removed
+	query := `
+		SELECT w.id
+		FROM workouts w
+		INNER JOIN heart_rate_settings s ON s.user_id = w.user_id
+		LEFT JOIN workout_training_loads l ON l.workout_id = w.id
+		LEFT JOIN training_load_failures f ON f.workout_id = w.id
+		WHERE w.deleted_at IS NULL AND (
+			(l.workout_id IS NULL AND (
+				EXISTS (
+					SELECT 1 FROM workout_entries we
+					WHERE we.workout_id = w.id AND we.avg_heart_rate IS NOT NULL AND we.duration_seconds IS NOT NULL
+				) OR
+				EXISTS (
+					SELECT 1 FROM workout_tracks t
+					WHERE t.workout_id = w.id AND t.avg_heart_rate IS NOT NULL
+				)
+			)) OR
+			l.workout_version <> w.version OR
+			l.settings_updated_at <> s.updated_at
+		)
+		ORDER BY f.failed_at NULLS FIRST, w.id
+		LIMIT $1
+	`
+
+	rows, err := s.db.QueryContext(ctx, op.statement(query), limit)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+
+	return ids, nil
+}
+
+func (s *PostgresTrainingStore) SaveTrainingLoad(ctx context.Context, load *TrainingLoad, settings *HeartRateSettings) error {
+	ctx, op := startOperation(ctx, s.timeouts, "workout_training_loads", "SaveTrainingLoad")
+	defer op.end()
+
+	zones, err := json.Marshal(load.ZoneSeconds)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		WITH cleared AS (
+			DELETE FROM training_load_failures WHERE workout_id = $1
+		)
+		INSERT INTO workout_training_loads (workout_id, user_id, workout_version, settings_updated_at, method, zone_seconds, trimp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (workout_id) DO UPDATE
+		SET workout_version = EXCLUDED.workout_version,
+			settings_updated_at = EXCLUDED.settings_updated_at,
+			method = EXCLUDED.method,
+			zone_seconds = EXCLUDED.zone_seconds,
+			trimp = EXCLUDED.trimp,
+			computed_at = CURRENT_TIMESTAMP
+		RETURNING computed_at
+	`
+
+	err = s.db.QueryRowContext(ctx, op.statement(query), load.WorkoutID, settings.UserID, load.WorkoutVersion,
+		settings.UpdatedAt, load.Method, zones, load.TRIMP).Scan(&load.ComputedAt)
+	return translateError(err)
+}
+
+func (s *PostgresTrainingStore) DeleteTrainingLoad(ctx context.Context, workoutID int64) error {
+	ctx, op := startOperation(ctx, s.timeouts, "workout_training_loads", "DeleteTrainingLoad")
+	defer op.end()
+
+	query := `
+		WITH cleared AS (
+			DELETE FROM training_load_failures WHERE workout_id = $1
+		)
+		DELETE FROM workout_training_loads WHERE workout_id = $1
+	`
+
+	_, err := s.db.ExecContext(ctx, op.statement(query), workoutID)
+	return translateError(err)
+}
+
+func (s *PostgresTrainingStore) MarkTrainingLoadFailed(ctx context.Context, workoutID int64) error {
+	ctx, op := startOperation(ctx, s.timeouts, "training_load_failures", "MarkTrainingLoadFailed")
+	defer op.end()
+
+	query := `
+		INSERT INTO training_load_failures (workout_id)
+		VALUES ($1)
+		ON CONFLICT (workout_id) DO UPDATE SET failed_at = CURRENT_TIMESTAMP
+	`
+
+	_, err := s.db.ExecContext(ctx, op.statement(query), workoutID)
+	return translateError(err)
+}
+
+func (s *PostgresTrainingStore) GetTrainingLoad(ctx context.Context, workoutID int64) (*TrainingLoad, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "workout_training_loads", "GetTrainingLoad")
+	defer op.end()
+
+	query := `
+		SELECT workout_id, workout_version, method, zone_seconds, trimp, computed_at
+		FROM workout_training_loads
+		WHERE workout_id = $1
+	`
+
+	load := &TrainingLoad{}
+	var zones []byte
+
+	err := s.db.QueryRowContext(ctx, op.statement(query), workoutID).Scan(&load.WorkoutID, &load.WorkoutVersion,
+		&load.Method, &zones, &load.TRIMP, &load.ComputedAt)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	err = json.Unmarshal(zones, &load.ZoneSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	return load, nil
+}
+
+func (s *PostgresTrainingStore) DailyTrainingLoads(ctx context.Context, userID int, r TimeRange) ([]DailyTrainingLoad, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "workout_training_loads", "DailyTrainingLoads")
+	defer op.end()
+
+	query := `
+		SELECT date_trunc('day', w.performed_at AT TIME ZONE 'UTC') AS day, SUM(l.trimp), COUNT(*)
+		FROM workout_training_loads l
+		INNER JOIN workouts w ON w.id = l.workout_id
+		WHERE l.user_id = $1 AND w.deleted_at IS NULL
+			AND ($2::timestamptz IS NULL OR w.performed_at >= $2)
+			AND ($3::timestamptz IS NULL OR w.performed_at < $3)
+		GROUP BY day
+		ORDER BY day
+	`
+
+	rows, err := s.db.QueryContext(ctx, op.statement(query), userID, nullTime(r.From), nullTime(r.To))
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	days := []DailyTrainingLoad{}
+	for rows.Next() {
+		var day DailyTrainingLoad
+		err = rows.Scan(&day.Date, &day.TRIMP, &day.Workouts)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		day.Date = day.Date.UTC()
+		days = append(days, day)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+
+	return days, nil
+}
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"crypto/sha256"
 	"database/sql"
 	"errors"
@@ -38,11 +39,14 @@ func (p *password) Matches(plainTextPassword string) (bool, error) {
 }
 
 type User struct {
-	ID           int       `json:"id"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	PasswordHash password  `json:"-"`
-	Bio          string    `json:"bio"`
+	ID           int      `json:"id"`
+	Username     string   `json:"username"`
+	Email        string   `json:"email"`
+	PasswordHash password `json:"-"`
+	Bio          string   `json:"bio"`
+	// BodyWeightKg is the latest weigh-in, see MeasurementStore. It is used
+	// to estimate calories when it is known.
+	BodyWeightKg *float64  `json:"body_weight_kg"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -54,28 +58,36 @@ func (u *User) IsAnonymous() bool {
 }
 
 type UserStore interface {
-	CreateUser(user *User) error
-	GetUserByUsername(username string) (*User, error)
-	UpdateUser(user *User) error
-	GetUserToken(scope, plainTextPassword string) (*User, error)
+	CreateUser(ctx context.Context, user *User) error
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	UpdateUser(ctx context.Context, user *User) error
+	// UpdateProfile saves the bio of user. A body weight other than the
+	// latest weigh-in is recorded as a weigh-in for today.
+	UpdateProfile(ctx context.Context, user *User) error
+	GetUserToken(ctx context.Context, scope, plainTextPassword string) (*User, error)
 }
 
 type PostgresUserStore struct {
-	db *sql.DB
+	db       *sql.DB
+	timeouts QueryTimeouts
 }
 
-func NewPostgresUserStore(db *sql.DB) *PostgresUserStore {
+func NewPostgresUserStore(db *sql.DB, timeouts QueryTimeouts) *PostgresUserStore {
 	return &PostgresUserStore{
-		db: db,
+		db:       db,
+		timeouts: timeouts,
 	}
 }
 
-func (s *PostgresUserStore) GetUserToken(scope, plainTextPassword string) (*User, error) {
+func (s *PostgresUserStore) GetUserToken(ctx context.Context, scope, plainTextPassword string) (*User, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "users", "GetUserToken")
+	defer op.end()
+
 	tokenHash := sha256.Sum256([]byte(plainTextPassword))
 
 	query := `
-		SELECT u.id, u.username, u.email, u.bio, u.created_at, u.updated_at
-		FROM users u 
+		SELECT u.id, u.username, u.email, u.bio, ` + latestBodyWeight + `, u.created_at, u.updated_at
+		FROM users u
 		INNER JOIN tokens t ON t.user_id = u.id
 		WHERE t.hash = $1 AND t.scope = $2 AND t.expiry > $3
 	`
@@ -84,11 +96,12 @@ func (s *PostgresUserStore) GetUserToken(scope, plainTextPassword string) (*User
 		PasswordHash: password{},
 	}
 
-	err := s.db.QueryRow(query, tokenHash, scope, time.Now()).Scan(
+	err := s.db.QueryRowContext(ctx, op.statement(query), tokenHash, scope, time.Now()).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.Bio,
+		&user.BodyWeightKg,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -98,44 +111,51 @@ func (s *PostgresUserStore) GetUserToken(scope, plainTextPassword string) (*User
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 
 	return user, nil
 }
 
-func (s *PostgresUserStore) CreateUser(user *User) error {
+func (s *PostgresUserStore) CreateUser(ctx context.Context, user *User) error {
+	ctx, op := startOperation(ctx, s.timeouts, "users", "CreateUser")
+	defer op.end()
+
 	query := `
 	INSERT INTO users (username, email, password_hash, bio)
 	VALUES ($1, $2, $3, $4)
 	RETURNING id, created_at, updated_at
     `
 
-	err := s.db.QueryRow(query, user.Username, user.Email, user.PasswordHash.hash, user.Bio).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	err := s.db.QueryRowContext(ctx, op.statement(query), user.Username, user.Email, user.PasswordHash.hash, user.Bio).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
 	return nil
 }
 
-func (s *PostgresUserStore) GetUserByUsername(username string) (*User, error) {
+func (s *PostgresUserStore) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	ctx, op := startOperation(ctx, s.timeouts, "users", "GetUserByUsername")
+	defer op.end()
+
 	user := &User{
 		PasswordHash: password{},
 	}
 
 	query := `
-		SELECT id, username, email, password_hash, bio, created_at, updated_at
-		FROM users
-		WHERE username = $1
+		SELECT u.id, u.username, u.email, u.password_hash, u.bio, ` + latestBodyWeight + `, u.created_at, u.updated_at
+		FROM users u
+		WHERE u.username = $1
 	`
 
-	err := s.db.QueryRow(query, username).Scan(
+	err := s.db.QueryRowContext(ctx, op.statement(query), username).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.PasswordHash.hash,
 		&user.Bio,
+		&user.BodyWeightKg,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -145,13 +165,16 @@ func (s *PostgresUserStore) GetUserByUsername(username string) (*User, error) {
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 
 	return user, nil
 }
 
-func (s *PostgresUserStore) UpdateUser(user *User) error {
+func (s *PostgresUserStore) UpdateUser(ctx context.Context, user *User) error {
+	ctx, op := startOperation(ctx, s.timeouts, "users", "UpdateUser")
+	defer op.end()
+
 	query := `
 		UPDATE users
 		SET username = $1, email = $2, password_hash = $3, bio = $4, updated_at = CURRENT_TIMESTAMP
@@ -159,18 +182,65 @@ func (s *PostgresUserStore) UpdateUser(user *User) error {
 		RETURNING updated_at
 	`
 
-	result, err := s.db.Exec(query, user.Username, user.Email, user.PasswordHash.hash, user.Bio, user.ID)
+	result, err := s.db.ExecContext(ctx, op.statement(query), user.Username, user.Email, user.PasswordHash.hash, user.Bio, user.ID)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
 	rowAffected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 
 	if rowAffected == 0 {
-		return sql.ErrNoRows
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *PostgresUserStore) UpdateProfile(ctx context.Context, user *User) error {
+	ctx, op := startOperation(ctx, s.timeouts, "users", "UpdateProfile")
+	defer op.end()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return translateError(err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE users
+		SET bio = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+		RETURNING updated_at
+	`
+
+	err = tx.QueryRowContext(ctx, op.statement(query), user.Bio, user.ID).Scan(&user.UpdatedAt)
+	if err != nil {
+		return translateError(err)
+	}
+
+	if user.BodyWeightKg != nil {
+		// a weight other than the latest weigh-in is today's weigh-in
+		query = `
+			INSERT INTO body_measurements (user_id, measured_on, body_weight_kg)
+			SELECT u.id, $2, $3
+			FROM users u
+			WHERE u.id = $1 AND $3::numeric IS DISTINCT FROM ` + latestBodyWeight + `
+			ON CONFLICT (user_id, measured_on) DO UPDATE
+			SET body_weight_kg = EXCLUDED.body_weight_kg, updated_at = CURRENT_TIMESTAMP
+		`
+
+		_, err = tx.ExecContext(ctx, op.statement(query), user.ID, NewDate(time.Now()), *user.BodyWeightKg)
+		if err != nil {
+			return translateError(err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return translateError(err)
 	}
 
 	return nil
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestProfileBodyWeight(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	users := NewPostgresUserStore(db, QueryTimeouts{})
+	measurements := NewPostgresMeasurementStore(db, QueryTimeouts{})
+	ctx := context.Background()
+	userID := createTestUser(t, db, "weigh-in")
+
+	_, err := db.Exec(`DELETE FROM body_measurements WHERE user_id = $1`, userID)
+	require.NoError(t, err)
+
+	user, err := users.GetUserByUsername(ctx, "weigh-in")
+	require.NoError(t, err)
+	assert.Nil(t, user.BodyWeightKg)
+
+	weight := 80.5
+	err = measurements.CreateMeasurement(ctx, &BodyMeasurement{
+		UserID:            userID,
+		MeasuredOn:        NewDate(time.Now().AddDate(0, 0, -3)),
+		MeasurementValues: MeasurementValues{BodyWeightKg: &weight},
+	})
+	require.NoError(t, err)
+
+	// the profile shows the latest weigh-in
+	user, err = users.GetUserByUsername(ctx, "weigh-in")
+	require.NoError(t, err)
+	require.NotNil(t, user.BodyWeightKg)
+	assert.Equal(t, 80.5, *user.BodyWeightKg)
+
+	// saving the profile with it unchanged records nothing
+	user.Bio = "Lifting on weekdays"
+	require.NoError(t, users.UpdateProfile(ctx, user))
+
+	list, err := measurements.ListMeasurements(ctx, userID)
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	// a new weight is today's weigh-in
+	weight = 79.8
+	user.BodyWeightKg = &weight
+	require.NoError(t, users.UpdateProfile(ctx, user))
+
+	list, err = measurements.ListMeasurements(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, NewDate(time.Now()), list[1].MeasuredOn)
+	assert.Equal(t, 79.8, *list[1].BodyWeightKg)
+
+	user, err = users.GetUserByUsername(ctx, "weigh-in")
+	require.NoError(t, err)
+	assert.Equal(t, "Lifting on weekdays", user.Bio)
+	assert.Equal(t, 79.8, *user.BodyWeightKg)
+}
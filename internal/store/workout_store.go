@@ -1,22 +1,39 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"strings"
+	"time"
 )
 
 type Workout struct {
-	ID              int              `json:"id"`
-	UserID          int              `json:"user_id"`
-	Title           string           `json:"title"`
-	Description     string           `json:"description"`
-	DurationMinutes int              `json:"duration_minutes"`
-	CaloriesBurned  int              `json:"calories_burned"`
-	Entries         []WorkoutEntries `json:"entries"`
+	ID              int    `json:"id"`
+	UUID            string `json:"uuid"`
+	UserID          int    `json:"user_id"`
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+	DurationMinutes int    `json:"duration_minutes"`
+	CaloriesBurned  int    `json:"calories_burned"`
+	// CaloriesSource says whether CaloriesBurned was reported by the client
+	// or estimated on save.
+	CaloriesSource string `json:"calories_source"`
+	// PerformedAt is when the workout took place, the time it was saved
+	// unless given.
+	PerformedAt time.Time `json:"performed_at"`
+	Version     int       `json:"version"`
+	// DeletedAt is set while the workout is in the trash.
+	DeletedAt *time.Time       `json:"deleted_at,omitempty"`
+	Entries   []WorkoutEntries `json:"entries"`
 }
 
 type WorkoutEntries struct {
-	ID              int      `json:"id"`
+	ID   int    `json:"id"`
+	UUID string `json:"uuid"`
+	// Type is EntryStrength or EntryCardio, strength when empty.
+	Type            string   `json:"type"`
 	ExerciseName    string   `json:"exercise_name"`
 	Sets            int      `json:"sets"`
 	Reps            *int     `json:"reps"`
@@ -24,203 +41,741 @@ type WorkoutEntries struct {
 	Weight          *float64 `json:"weight"`
 	Notes           string   `json:"notes"`
 	OrderIndex      int      `json:"order_index"`
+
+	// Cardio fields. Distance is in DistanceUnit.
+	Distance            *float64        `json:"distance"`
+	DistanceUnit        string          `json:"distance_unit,omitempty"`
+	ElevationGainMeters *float64        `json:"elevation_gain_meters"`
+	AvgHeartRate        *int            `json:"avg_heart_rate"`
+	MaxHeartRate        *int            `json:"max_heart_rate"`
+	Intervals           []EntryInterval `json:"intervals"`
+	// AvgPace and AvgSpeed are computed from the distance and duration;
+	// values sent by clients are ignored.
+	AvgPace  *float64 `json:"avg_pace_seconds_per_km"`
+	AvgSpeed *float64 `json:"avg_speed_kmh"`
 }
 
 type PostgresWorkoutStore struct {
-	db *sql.DB
+	db       *sql.DB
+	timeouts QueryTimeouts
 }
 
-func NewPostgresWorkoutStore(db *sql.DB) *PostgresWorkoutStore {
+func NewPostgresWorkoutStore(db *sql.DB, timeouts QueryTimeouts) *PostgresWorkoutStore {
 	return &PostgresWorkoutStore{
-		db: db,
+		db:       db,
+		timeouts: timeouts,
 	}
 }
 
 type WorkoutStore interface {
-	CreateWorkout(workout *Workout) (*Workout, error)
-	GetWorkoutByID(id int64) (*Workout, error)
-	UpdateWorkout(workout *Workout) error
-	DeleteWorkout(id int64) error
-	GetWorkoutOwner(id int64) (int, error)
+	CreateWorkout(ctx context.Context, workout *Workout) (*Workout, error)
+	// Reads skip workouts in the trash unless they say otherwise.
+	GetWorkoutByID(ctx context.Context, id int64) (*Workout, error)
+	// GetWorkoutByUUID only finds workouts owned by userID.
+	GetWorkoutByUUID(ctx context.Context, userID int, uuid string) (*Workout, error)
+	UpdateWorkout(ctx context.Context, workout *Workout) error
+	// RevertWorkout saves workout like UpdateWorkout, recording it as a
+	// revert to revision version.
+	RevertWorkout(ctx context.Context, workout *Workout, version int) error
+	// DeleteWorkout moves a workout to the trash. Like RestoreWorkout it
+	// bumps the version and records a revision.
+	DeleteWorkout(ctx context.Context, id int64) error
+	GetWorkoutOwner(ctx context.Context, id int64) (int, error)
+	// ListTrash returns the trashed workouts of userID, most recently
+	// deleted first.
+	ListTrash(ctx context.Context, userID int) ([]*Workout, error)
+	// RestoreWorkout takes a workout of userID out of the trash.
+	RestoreWorkout(ctx context.Context, userID int, id int64) (*Workout, error)
+	// PurgeTrash permanently deletes workouts trashed before olderThan.
+	PurgeTrash(ctx context.Context, olderThan time.Time) (int64, error)
+	// HasWorkoutAt reports whether userID has a workout performed within the
+	// same minute as performedAt.
+	HasWorkoutAt(ctx context.Context, userID int, performedAt time.Time) (bool, error)
+	// ListWorkoutsPerformed returns up to limit workouts of userID performed
+	// in r, oldest first, that come after the workout after. Passing the last
+	// workout of a page as after returns the next page.
+	ListWorkoutsPerformed(ctx context.Context, userID int, r TimeRange, after *Workout, limit int) ([]*Workout, error)
+	// BestWeights returns the heaviest weight userID has logged for each of
+	// exercises, keyed by the lower case name. Exercises never lifted with
+	// a weight are left out.
+	BestWeights(ctx context.Context, userID int, exercises []string) (map[string]float64, error)
 }
 
-func (pg *PostgresWorkoutStore) CreateWorkout(workout *Workout) (*Workout, error) {
-	tx, err := pg.db.Begin()
-	if err != nil {
-		return nil, err
+// TimeRange is the half-open interval [From, To). A zero bound is open.
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// nullTime maps the zero time to NULL so the column default applies.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
 	}
-	defer tx.Rollback()
+	return &t
+}
 
-	query := `
-		INSERT INTO workouts (user_id, title, description, duration_minutes, calories_burned) 
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id
-	`
+const workoutColumns = `id, uuid, user_id, title, description, duration_minutes, calories_burned, calories_source, performed_at, version, deleted_at`
+
+func scanWorkout(row scanner, workout *Workout) error {
+	var description sql.NullString
+	var calories sql.NullInt64
+
+	err := row.Scan(
+		&workout.ID,
+		&workout.UUID,
+		&workout.UserID,
+		&workout.Title,
+		&description,
+		&workout.DurationMinutes,
+		&calories,
+		&workout.CaloriesSource,
+		&workout.PerformedAt,
+		&workout.Version,
+		&workout.DeletedAt,
+	)
+	workout.Description = description.String
+	workout.CaloriesBurned = int(calories.Int64)
+
+	return err
+}
 
-	err = tx.QueryRow(query, workout.UserID, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned).Scan(&workout.ID)
-	if err != nil {
-		return nil, err
+const entryColumns = `id, uuid, entry_type, exercise_name, sets, reps, duration_seconds, weight, notes, order_index,
+	distance, distance_unit, elevation_gain_meters, avg_heart_rate, max_heart_rate, intervals`
+
+// scanEntry scans entryColumns into entry, followed by any extra columns the
+// query selected after them.
+func scanEntry(row scanner, entry *WorkoutEntries, extra ...any) error {
+	var notes, distanceUnit sql.NullString
+	var intervals []byte
+
+	dest := []any{
+		&entry.ID,
+		&entry.UUID,
+		&entry.Type,
+		&entry.ExerciseName,
+		&entry.Sets,
+		&entry.Reps,
+		&entry.DurationSeconds,
+		&entry.Weight,
+		&notes,
+		&entry.OrderIndex,
+		&entry.Distance,
+		&distanceUnit,
+		&entry.ElevationGainMeters,
+		&entry.AvgHeartRate,
+		&entry.MaxHeartRate,
+		&intervals,
 	}
 
-	for _, entry := range workout.Entries {
-		query := `
-			INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-			RETURNING id
-		`
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
 
-		err = tx.QueryRow(query, workout.ID, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex).Scan(&entry.ID)
+	entry.Notes = notes.String
+	entry.DistanceUnit = distanceUnit.String
+	if intervals != nil {
+		err = json.Unmarshal(intervals, &entry.Intervals)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
+	entry.derive()
 
-	err = tx.Commit()
+	return nil
+}
+
+// insertEntry inserts entry for workoutID, keeping a client generated UUID
+// when there is one.
+func insertEntry(ctx context.Context, q querier, op *operation, workoutID int, entry *WorkoutEntries) error {
+	entry.derive()
+	intervals, err := marshalIntervals(entry.Intervals)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return workout, nil
+	query := `
+		INSERT INTO workout_entries (uuid, workout_id, entry_type, exercise_name, sets, reps, duration_seconds, weight, notes, order_index,
+			distance, distance_unit, elevation_gain_meters, avg_heart_rate, max_heart_rate, intervals)
+		VALUES (COALESCE(NULLIF($1, '')::uuid, gen_random_uuid()), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), $13, $14, $15, $16)
+		RETURNING id, uuid
+	`
+
+	err = q.QueryRowContext(ctx, op.statement(query), entry.UUID, workoutID, entry.Type, entry.ExerciseName, entry.Sets, entry.Reps,
+		entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex, entry.Distance, entry.DistanceUnit,
+		entry.ElevationGainMeters, entry.AvgHeartRate, entry.MaxHeartRate, intervals).Scan(&entry.ID, &entry.UUID)
+	return translateError(err)
 }
 
-func (pg *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error) {
-	workout := &Workout{}
+func updateEntry(ctx context.Context, q querier, op *operation, workoutID int, entry *WorkoutEntries) error {
+	entry.derive()
+	intervals, err := marshalIntervals(entry.Intervals)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		SELECT id, title, description, duration_minutes, calories_burned
-		FROM workouts 
-		WHERE id = $1
+		UPDATE workout_entries
+		SET entry_type = $1, exercise_name = $2, sets = $3, reps = $4, duration_seconds = $5, weight = $6, notes = $7, order_index = $8,
+			distance = $9, distance_unit = NULLIF($10, ''), elevation_gain_meters = $11, avg_heart_rate = $12, max_heart_rate = $13,
+			intervals = $14
+		WHERE id = $15 AND workout_id = $16
 	`
-	err := pg.db.QueryRow(query, id).Scan(&workout.ID, &workout.Title, &workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned)
-	if errors.Is(err, sql.ErrNoRows) {
 
-		return nil, nil
+	_, err = q.ExecContext(ctx, op.statement(query), entry.Type, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds,
+		entry.Weight, entry.Notes, entry.OrderIndex, entry.Distance, entry.DistanceUnit, entry.ElevationGainMeters,
+		entry.AvgHeartRate, entry.MaxHeartRate, intervals, entry.ID, workoutID)
+	return translateError(err)
+}
+
+// saveEntries makes the stored entries of workout match workout.Entries.
+// Entries are matched to stored ones by ID, then by UUID, and updated in
+// place so their IDs stay stable; unmatched ones are inserted and stored
+// entries missing from the list are deleted.
+func saveEntries(ctx context.Context, q querier, op *operation, workout *Workout) error {
+	query := `SELECT id, uuid FROM workout_entries WHERE workout_id = $1`
+
+	rows, err := q.QueryContext(ctx, op.statement(query), workout.ID)
+	if err != nil {
+		return translateError(err)
 	}
+	defer rows.Close()
+
+	uuids := make(map[int]string)
+	ids := make(map[string]int)
+	for rows.Next() {
+		var id int
+		var uuid string
+		err = rows.Scan(&id, &uuid)
+		if err != nil {
+			return translateError(err)
+		}
 
+		uuids[id] = uuid
+		ids[uuid] = id
+	}
+	if err = rows.Err(); err != nil {
+		return translateError(err)
+	}
+	rows.Close()
+
+	kept := make(map[int]bool)
+	keptIDs := []int64{}
+	for i := range workout.Entries {
+		entry := &workout.Entries[i]
+
+		id := 0
+		if _, ok := uuids[entry.ID]; ok {
+			id = entry.ID
+		} else if byUUID, ok := ids[entry.UUID]; ok {
+			id = byUUID
+		}
+
+		if kept[id] {
+			// the same stored entry listed twice, the copy becomes a new one
+			id = 0
+			entry.UUID = ""
+		}
+		if id == 0 {
+			entry.ID = 0
+			continue
+		}
+
+		kept[id] = true
+		keptIDs = append(keptIDs, int64(id))
+		entry.ID = id
+		entry.UUID = uuids[id]
+	}
+
+	// delete first so UUIDs of removed entries can be inserted again
+	query = `DELETE FROM workout_entries WHERE workout_id = $1 AND id <> ALL($2)`
+
+	_, err = q.ExecContext(ctx, op.statement(query), workout.ID, keptIDs)
 	if err != nil {
-		return nil, err
+		return translateError(err)
 	}
 
-	query = `
-		SELECT id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index
+	for i := range workout.Entries {
+		entry := &workout.Entries[i]
+
+		if entry.ID == 0 {
+			err = insertEntry(ctx, q, op, workout.ID, entry)
+		} else {
+			err = updateEntry(ctx, q, op, workout.ID, entry)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func loadEntries(ctx context.Context, q querier, op *operation, workoutID int) ([]WorkoutEntries, error) {
+	query := `
+		SELECT ` + entryColumns + `
 		FROM workout_entries
 		WHERE workout_id = $1
 		ORDER BY order_index
 	`
 
-	rows, err := pg.db.Query(query, id)
+	rows, err := q.QueryContext(ctx, op.statement(query), workoutID)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
+	defer rows.Close()
 
+	var entries []WorkoutEntries
 	for rows.Next() {
 		var entry WorkoutEntries
-		err = rows.Scan(
-			&entry.ID,
-			&entry.ExerciseName,
-			&entry.Sets,
-			&entry.Reps,
-			&entry.DurationSeconds,
-			&entry.Weight,
-			&entry.Notes,
-			&entry.OrderIndex,
-		)
-
+		err = scanEntry(rows, &entry)
 		if err != nil {
-			return nil, err
+			return nil, translateError(err)
 		}
 
-		workout.Entries = append(workout.Entries, entry)
+		entries = append(entries, entry)
+	}
+
+	return entries, translateError(rows.Err())
+}
+
+func (pg *PostgresWorkoutStore) CreateWorkout(ctx context.Context, workout *Workout) (*Workout, error) {
+	ctx, op := startOperation(ctx, pg.timeouts, "workouts", "CreateWorkout")
+	defer op.end()
 
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer tx.Rollback()
+
+	err = createWorkout(ctx, tx, op, workout)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, translateError(err)
 	}
 
 	return workout, nil
 }
 
-func (pg *PostgresWorkoutStore) UpdateWorkout(workout *Workout) error {
-	tx, err := pg.db.Begin()
+// createWorkout inserts workout with its entries and records the create
+// revision, inside the caller's transaction.
+func createWorkout(ctx context.Context, tx *sql.Tx, op *operation, workout *Workout) error {
+	performedAt := workout.PerformedAt
+	if performedAt.IsZero() {
+		performedAt = time.Now()
+	}
+
+	weight, err := bodyWeight(ctx, tx, op, workout.UserID, performedAt)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
+	setCalories(workout, workout.CaloriesBurned == 0, weight)
 
 	query := `
-		UPDATE workouts
-		SET title = $1, description = $2, duration_minutes = $3, calories_burned = $4
-		WHERE id = $5
+		INSERT INTO workouts (uuid, user_id, title, description, duration_minutes, calories_burned, calories_source, performed_at) 
+		VALUES (COALESCE(NULLIF($1, '')::uuid, gen_random_uuid()), $2, $3, $4, $5, $6, $7, COALESCE($8, CURRENT_TIMESTAMP))
+		RETURNING id, uuid, performed_at, version
 	`
 
-	result, err := tx.Exec(query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.ID)
+	err = tx.QueryRowContext(ctx, op.statement(query), workout.UUID, workout.UserID, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.CaloriesSource, nullTime(workout.PerformedAt)).Scan(&workout.ID, &workout.UUID, &workout.PerformedAt, &workout.Version)
 	if err != nil {
-		return err
+		return translateError(err)
+	}
+
+	for i := range workout.Entries {
+		err = insertEntry(ctx, tx, op, workout.ID, &workout.Entries[i])
+		if err != nil {
+			return err
+		}
 	}
 
-	rowAffected, err := result.RowsAffected()
+	return recordRevision(ctx, tx, op, workout, RevisionCreate, nil)
+}
+
+func (pg *PostgresWorkoutStore) GetWorkoutByID(ctx context.Context, id int64) (*Workout, error) {
+	ctx, op := startOperation(ctx, pg.timeouts, "workouts", "GetWorkoutByID")
+	defer op.end()
+
+	workout := &Workout{}
+	query := `
+		SELECT ` + workoutColumns + `
+		FROM workouts 
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+	err := scanWorkout(pg.db.QueryRowContext(ctx, op.statement(query), id), workout)
 	if err != nil {
-		return err
+		return nil, translateError(err)
 	}
 
-	if rowAffected == 0 {
-		return sql.ErrNoRows
+	workout.Entries, err = loadEntries(ctx, pg.db, op, workout.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	query = `DELETE FROM workout_entries WHERE workout_id = $1`
+	return workout, nil
+}
+
+func (pg *PostgresWorkoutStore) GetWorkoutByUUID(ctx context.Context, userID int, uuid string) (*Workout, error) {
+	ctx, op := startOperation(ctx, pg.timeouts, "workouts", "GetWorkoutByUUID")
+	defer op.end()
 
-	_, err = tx.Exec(query, workout.ID)
+	workout := &Workout{}
+	query := `
+		SELECT ` + workoutColumns + `
+		FROM workouts
+		WHERE uuid = $1 AND user_id = $2 AND deleted_at IS NULL
+	`
+	err := scanWorkout(pg.db.QueryRowContext(ctx, op.statement(query), uuid, userID), workout)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	workout.Entries, err = loadEntries(ctx, pg.db, op, workout.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return workout, nil
+}
+
+// UpdateWorkout saves workout if it is still at workout.Version and bumps the
+// version. A concurrent update in between makes it fail with
+// ErrVersionMismatch.
+func (pg *PostgresWorkoutStore) UpdateWorkout(ctx context.Context, workout *Workout) error {
+	ctx, op := startOperation(ctx, pg.timeouts, "workouts", "UpdateWorkout")
+	defer op.end()
+
+	return pg.updateWorkout(ctx, op, workout, RevisionUpdate, nil)
+}
+
+func (pg *PostgresWorkoutStore) RevertWorkout(ctx context.Context, workout *Workout, version int) error {
+	ctx, op := startOperation(ctx, pg.timeouts, "workouts", "RevertWorkout")
+	defer op.end()
+
+	return pg.updateWorkout(ctx, op, workout, RevisionRevert, &version)
+}
+
+func (pg *PostgresWorkoutStore) updateWorkout(ctx context.Context, op *operation, workout *Workout, action string, revertedFrom *int) error {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return translateError(err)
+	}
+	defer tx.Rollback()
+
+	// an estimate sent back unchanged, as clients that echo the whole
+	// workout do, stays an estimate
+	var calories, userID int
+	var source string
+	var performedAt time.Time
+	query := `SELECT COALESCE(calories_burned, 0), calories_source, user_id, performed_at FROM workouts WHERE id = $1`
+
+	err = tx.QueryRowContext(ctx, op.statement(query), workout.ID).Scan(&calories, &source, &userID, &performedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return translateError(err)
+	}
+	if !workout.PerformedAt.IsZero() {
+		performedAt = workout.PerformedAt
+	}
+
+	weight, err := bodyWeight(ctx, tx, op, userID, performedAt)
 	if err != nil {
 		return err
 	}
+	setCalories(workout, workout.CaloriesBurned == 0 || (source == CaloriesEstimated && workout.CaloriesBurned == calories), weight)
+
+	query = `
+		UPDATE workouts
+		SET title = $1, description = $2, duration_minutes = $3, calories_burned = $4, calories_source = $5,
+			performed_at = COALESCE($6, performed_at), version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $7 AND version = $8 AND deleted_at IS NULL
+		RETURNING performed_at, version
+	`
 
-	for _, entry := range workout.Entries {
-		query := `
-			INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, notes, order_index)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
-		`
+	err = tx.QueryRowContext(ctx, op.statement(query), workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.CaloriesSource, nullTime(workout.PerformedAt), workout.ID, workout.Version).Scan(&workout.PerformedAt, &workout.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		var exists bool
+		query = `SELECT EXISTS (SELECT 1 FROM workouts WHERE id = $1 AND deleted_at IS NULL)`
 
-		_, err := tx.Exec(query, entry.ID, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Notes, entry.OrderIndex)
+		err = tx.QueryRowContext(ctx, op.statement(query), workout.ID).Scan(&exists)
 		if err != nil {
-			return err
+			return translateError(err)
 		}
 
+		if !exists {
+			return ErrNotFound
+		}
+		return ErrVersionMismatch
+	}
+	if err != nil {
+		return translateError(err)
+	}
+
+	err = saveEntries(ctx, tx, op, workout)
+	if err != nil {
+		return err
 	}
 
-	return tx.Commit()
+	err = recordRevision(ctx, tx, op, workout, action, revertedFrom)
+	if err != nil {
+		return err
+	}
+
+	return translateError(tx.Commit())
 }
 
-func (pg *PostgresWorkoutStore) DeleteWorkout(id int64) error {
+func (pg *PostgresWorkoutStore) DeleteWorkout(ctx context.Context, id int64) error {
+	ctx, op := startOperation(ctx, pg.timeouts, "workouts", "DeleteWorkout")
+	defer op.end()
+
 	query := `
-		DELETE FROM workouts WHERE id = $1
- 	`
+		UPDATE workouts
+		SET deleted_at = CURRENT_TIMESTAMP, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING ` + workoutColumns
+
+	_, err := pg.moveTrash(ctx, op, RevisionTrash, query, id)
+	return err
+}
 
-	result, err := pg.db.Exec(query)
+// moveTrash runs query, which moves one workout in or out of the trash and
+// bumps its version, and records the revision for it in the same
+// transaction.
+func (pg *PostgresWorkoutStore) moveTrash(ctx context.Context, op *operation, action string, query string, args ...any) (*Workout, error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, translateError(err)
 	}
+	defer tx.Rollback()
 
-	rowAffected, err := result.RowsAffected()
+	workout := &Workout{}
+	err = scanWorkout(tx.QueryRowContext(ctx, op.statement(query), args...), workout)
 	if err != nil {
-		return err
+		return nil, translateError(err)
 	}
 
-	if rowAffected == 0 {
-		return sql.ErrNoRows
+	workout.Entries, err = loadEntries(ctx, tx, op, workout.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	err = recordRevision(ctx, tx, op, workout, action, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return workout, nil
 }
 
-func (pg *PostgresWorkoutStore) GetWorkoutOwner(id int64) (int, error) {
+func (pg *PostgresWorkoutStore) GetWorkoutOwner(ctx context.Context, id int64) (int, error) {
+	ctx, op := startOperation(ctx, pg.timeouts, "workouts", "GetWorkoutOwner")
+	defer op.end()
+
 	var userID int
 
 	query := `
 	SELECT user_id
 	FROM workouts
-	WHERE id = $1
+	WHERE id = $1 AND deleted_at IS NULL
     `
 
-	err := pg.db.QueryRow(query, id).Scan(&userID)
+	err := pg.db.QueryRowContext(ctx, op.statement(query), id).Scan(&userID)
 	if err != nil {
-		return 0, err
+		return 0, translateError(err)
 	}
 
 	return userID, nil
 }
+
+func (pg *PostgresWorkoutStore) ListTrash(ctx context.Context, userID int) ([]*Workout, error) {
+	ctx, op := startOperation(ctx, pg.timeouts, "workouts", "ListTrash")
+	defer op.end()
+
+	query := `
+		SELECT id
+		FROM workouts
+		WHERE user_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`
+
+	rows, err := pg.db.QueryContext(ctx, op.statement(query), userID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+
+	return loadWorkouts(ctx, pg.db, op, ids)
+}
+
+func (pg *PostgresWorkoutStore) RestoreWorkout(ctx context.Context, userID int, id int64) (*Workout, error) {
+	ctx, op := startOperation(ctx, pg.timeouts, "workouts", "RestoreWorkout")
+	defer op.end()
+
+	query := `
+		UPDATE workouts
+		SET deleted_at = NULL, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+		RETURNING ` + workoutColumns
+
+	return pg.moveTrash(ctx, op, RevisionRestore, query, id, userID)
+}
+
+func (pg *PostgresWorkoutStore) PurgeTrash(ctx context.Context, olderThan time.Time) (int64, error) {
+	ctx, op := startOperation(ctx, pg.timeouts, "workouts", "PurgeTrash")
+	defer op.end()
+
+	query := `DELETE FROM workouts WHERE deleted_at < $1`
+
+	result, err := pg.db.ExecContext(ctx, op.statement(query), olderThan)
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	return result.RowsAffected()
+}
+
+func (pg *PostgresWorkoutStore) HasWorkoutAt(ctx context.Context, userID int, performedAt time.Time) (bool, error) {
+	ctx, op := startOperation(ctx, pg.timeouts, "workouts", "HasWorkoutAt")
+	defer op.end()
+
+	minute := performedAt.Truncate(time.Minute)
+
+	var exists bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM workouts
+			WHERE user_id = $1 AND deleted_at IS NULL AND performed_at >= $2 AND performed_at < $3
+		)
+	`
+
+	err := pg.db.QueryRowContext(ctx, op.statement(query), userID, minute, minute.Add(time.Minute)).Scan(&exists)
+	if err != nil {
+		return false, translateError(err)
+	}
+
+	return exists, nil
+}
+
+func (pg *PostgresWorkoutStore) ListWorkoutsPerformed(ctx context.Context, userID int, r TimeRange, after *Workout, limit int) ([]*Workout, error) {
+	ctx, op := startOperation(ctx, pg.timeouts, "workouts", "ListWorkoutsPerformed")
+	defer op.end()
+
+	var afterTime *time.Time
+	afterID := 0
+	if after != nil {
+		afterTime, afterID = &after.PerformedAt, after.ID
+	}
+
+	// ids first, then loadWorkouts, so a page costs two queries however many
+	// entries it has
+	query := `
+		SELECT id
+		FROM workouts
+		WHERE user_id = $1 AND deleted_at IS NULL
+			AND ($2::timestamptz IS NULL OR performed_at >= $2)
+			AND ($3::timestamptz IS NULL OR performed_at < $3)
+			AND ($4::timestamptz IS NULL OR (performed_at, id) > ($4, $5))
+		ORDER BY performed_at, id
+		LIMIT $6
+	`
+
+	rows, err := pg.db.QueryContext(ctx, op.statement(query), userID, nullTime(r.From), nullTime(r.To), afterTime, afterID, limit)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+
+	return loadWorkouts(ctx, pg.db, op, ids)
+}
+
+func (pg *PostgresWorkoutStore) BestWeights(ctx context.Context, userID int, exercises []string) (map[string]float64, error) {
+	ctx, op := startOperation(ctx, pg.timeouts, "workout_entries", "BestWeights")
+	defer op.end()
+
+	names := make([]string, 0, len(exercises))
+	for _, name := range exercises {
+		names = append(names, strings.ToLower(name))
+	}
+
+	query := `
+		SELECT LOWER(we.exercise_name), MAX(we.weight)
+		FROM workout_entries we
+		INNER JOIN workouts w ON w.id = we.workout_id
+		WHERE w.user_id = $1 AND w.deleted_at IS NULL AND we.weight IS NOT NULL
+			AND LOWER(we.exercise_name) = ANY($2)
+		GROUP BY LOWER(we.exercise_name)
+	`
+
+	rows, err := pg.db.QueryContext(ctx, op.statement(query), userID, names)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	best := make(map[string]float64)
+	for rows.Next() {
+		var name string
+		var weight float64
+		err = rows.Scan(&name, &weight)
+		if err != nil {
+			return nil, translateError(err)
+		}
+
+		best[name] = weight
+	}
+	if err = rows.Err(); err != nil {
+		return nil, translateError(err)
+	}
+
+	return best, nil
+}
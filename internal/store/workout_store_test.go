@@ -1,11 +1,13 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func setupTestDB(t *testing.T) *sql.DB {
@@ -31,7 +33,7 @@ func TestCreateWorkout(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
-	store := NewPostgresWorkoutStore(db)
+	store := NewPostgresWorkoutStore(db, QueryTimeouts{})
 
 	test := []struct {
 		name    string
@@ -98,7 +100,7 @@ func TestCreateWorkout(t *testing.T) {
 
 	for _, tt := range test {
 		t.Run(tt.name, func(t *testing.T) {
-			createdWorkout, err := store.CreateWorkout(tt.workout)
+			createdWorkout, err := store.CreateWorkout(context.Background(), tt.workout)
 			if tt.wantErr {
 				assert.Error(t, err)
 				return
@@ -110,7 +112,7 @@ func TestCreateWorkout(t *testing.T) {
 			assert.Equal(t, tt.workout.DurationMinutes, createdWorkout.DurationMinutes)
 			assert.Equal(t, tt.workout.CaloriesBurned, createdWorkout.CaloriesBurned)
 
-			retrieve, err := store.GetWorkoutByID(int64(createdWorkout.ID))
+			retrieve, err := store.GetWorkoutByID(context.Background(), int64(createdWorkout.ID))
 			require.NoError(t, err)
 
 			assert.Equal(t, createdWorkout.ID, retrieve.ID)
@@ -130,6 +132,234 @@ func TestCreateWorkout(t *testing.T) {
 
 }
 
+// createTestUser returns the id of the user called username, creating it
+// when a previous run has not.
+func createTestUser(t *testing.T, db *sql.DB, username string) int {
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO users (username, email, password_hash)
+		VALUES ($1, $1 || '@example.com', 'x')
+		ON CONFLICT (username) DO UPDATE SET username = EXCLUDED.username
+		RETURNING id
+	`, username).Scan(&id)
+	require.NoError(t, err)
+
+	return id
+}
+
+func TestWorkoutTitlesPerUser(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db, QueryTimeouts{})
+	ctx := context.Background()
+	alice, bob := createTestUser(t, db, "alice"), createTestUser(t, db, "bob")
+
+	workout := func(userID int) *Workout {
+		return &Workout{UserID: userID, Title: "Push Day", DurationMinutes: 60}
+	}
+
+	_, err := store.CreateWorkout(ctx, workout(alice))
+	require.NoError(t, err)
+
+	// another user may use the same title
+	_, err = store.CreateWorkout(ctx, workout(bob))
+	require.NoError(t, err)
+
+	_, err = store.CreateWorkout(ctx, workout(alice))
+	var conflictErr *ConflictError
+	require.ErrorAs(t, err, &conflictErr)
+	assert.Equal(t, "title", conflictErr.Field)
+}
+
+func TestBestWeights(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db, QueryTimeouts{})
+	ctx := context.Background()
+	alice, bob := createTestUser(t, db, "alice"), createTestUser(t, db, "bob")
+
+	lift := func(userID int, title string, weight float64) *Workout {
+		reps := 5
+		return &Workout{UserID: userID, Title: title, DurationMinutes: 60, Entries: []WorkoutEntries{
+			{ExerciseName: "Squat", Sets: 3, Reps: &reps, Weight: &weight},
+			{ExerciseName: "Plank", Sets: 3, Reps: &reps},
+		}}
+	}
+
+	_, err := store.CreateWorkout(ctx, lift(alice, "Leg Day", 100))
+	require.NoError(t, err)
+	_, err = store.CreateWorkout(ctx, lift(alice, "Leg Day Heavy", 120))
+	require.NoError(t, err)
+	trashed, err := store.CreateWorkout(ctx, lift(alice, "Leg Day Heavier", 140))
+	require.NoError(t, err)
+	require.NoError(t, store.DeleteWorkout(ctx, int64(trashed.ID)))
+	_, err = store.CreateWorkout(ctx, lift(bob, "Leg Day", 200))
+	require.NoError(t, err)
+
+	// trashed workouts and other users don't count
+	best, err := store.BestWeights(ctx, alice, []string{"squat", "Plank", "Deadlift"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"squat": 120}, best)
+}
+
+func TestTrashRestorePurge(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db, QueryTimeouts{})
+	revisions := NewPostgresRevisionStore(db, QueryTimeouts{})
+	ctx := context.Background()
+	userID := createTestUser(t, db, "alice")
+
+	workout, err := store.CreateWorkout(ctx, &Workout{UserID: userID, Title: "Trash Day", DurationMinutes: 30})
+	require.NoError(t, err)
+	id := int64(workout.ID)
+
+	require.NoError(t, store.DeleteWorkout(ctx, id))
+	_, err = store.GetWorkoutByID(ctx, id)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.DeleteWorkout(ctx, id), ErrNotFound)
+
+	trash, err := store.ListTrash(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.Equal(t, 2, trash[0].Version)
+
+	_, err = store.RestoreWorkout(ctx, userID+1, id)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	restored, err := store.RestoreWorkout(ctx, userID, id)
+	require.NoError(t, err)
+	assert.Equal(t, 3, restored.Version)
+	assert.Nil(t, restored.DeletedAt)
+
+	history, err := revisions.ListRevisions(ctx, id)
+	require.NoError(t, err)
+	actions := make([]string, 0, len(history))
+	for _, revision := range history {
+		actions = append(actions, revision.Action)
+	}
+	assert.Equal(t, []string{RevisionRestore, RevisionTrash, RevisionCreate}, actions)
+
+	// purging leaves a tombstone for clients that never saw the trashing
+	require.NoError(t, store.DeleteWorkout(ctx, id))
+	purged, err := store.PurgeTrash(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	var tombstones int
+	err = db.QueryRow(`SELECT COUNT(*) FROM sync_tombstones WHERE entity = 'workout' AND uuid = $1`, workout.UUID).Scan(&tombstones)
+	require.NoError(t, err)
+	assert.Equal(t, 1, tombstones)
+}
+
+func TestSaveEntries(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresWorkoutStore(db, QueryTimeouts{})
+	ctx := context.Background()
+	userID := createTestUser(t, db, "alice")
+
+	entry := func(name string, order int) WorkoutEntries {
+		return WorkoutEntries{ExerciseName: name, Sets: 3, Reps: intPtr(5), Weight: floatPtr(100), OrderIndex: order}
+	}
+	save := func(workout *Workout, entries ...WorkoutEntries) *Workout {
+		workout.Entries = entries
+		require.NoError(t, store.UpdateWorkout(ctx, workout))
+
+		saved, err := store.GetWorkoutByID(ctx, int64(workout.ID))
+		require.NoError(t, err)
+		return saved
+	}
+	names := func(workout *Workout) []string {
+		names := make([]string, 0, len(workout.Entries))
+		for _, entry := range workout.Entries {
+			names = append(names, entry.ExerciseName)
+		}
+		return names
+	}
+
+	created, err := store.CreateWorkout(ctx, &Workout{
+		UserID: userID, Title: "Leg Day", DurationMinutes: 60,
+		Entries: []WorkoutEntries{entry("Squat", 0), entry("Lunge", 1), entry("Calf Raise", 2)},
+	})
+	require.NoError(t, err)
+	workout, err := store.GetWorkoutByID(ctx, int64(created.ID))
+	require.NoError(t, err)
+	squat, lunge, calves := workout.Entries[0], workout.Entries[1], workout.Entries[2]
+
+	// an update keeps the ID and UUID
+	changed := squat
+	changed.Weight = floatPtr(110)
+	workout = save(workout, changed, lunge, calves)
+	require.Len(t, workout.Entries, 3)
+	assert.Equal(t, squat.ID, workout.Entries[0].ID)
+	assert.Equal(t, squat.UUID, workout.Entries[0].UUID)
+	assert.Equal(t, 110.0, *workout.Entries[0].Weight)
+
+	// so does a reorder
+	calves.OrderIndex, lunge.OrderIndex, changed.OrderIndex = 0, 1, 2
+	workout = save(workout, calves, lunge, changed)
+	assert.Equal(t, []string{"Calf Raise", "Lunge", "Squat"}, names(workout))
+	assert.Equal(t, []int{calves.ID, lunge.ID, squat.ID}, []int{workout.Entries[0].ID, workout.Entries[1].ID, workout.Entries[2].ID})
+
+	// an entry sent without its ID is found by its UUID
+	byUUID := lunge
+	byUUID.ID = 0
+	workout = save(workout, calves, byUUID, changed)
+	assert.Equal(t, lunge.ID, workout.Entries[1].ID)
+
+	// entries left out are deleted, the rest stay
+	workout = save(workout, changed)
+	require.Len(t, workout.Entries, 1)
+	assert.Equal(t, squat.ID, workout.Entries[0].ID)
+
+	var tombstones int
+	err = db.QueryRow(`SELECT COUNT(*) FROM sync_tombstones WHERE entity = 'workout_entry' AND uuid = ANY($1)`,
+		[]string{lunge.UUID, calves.UUID}).Scan(&tombstones)
+	require.NoError(t, err)
+	assert.Equal(t, 2, tombstones)
+
+	// the same entry listed twice, the copy becomes a new entry
+	copied := changed
+	copied.OrderIndex = 1
+	workout = save(workout, changed, copied)
+	require.Len(t, workout.Entries, 2)
+	assert.Equal(t, squat.ID, workout.Entries[0].ID)
+	assert.NotEqual(t, squat.ID, workout.Entries[1].ID)
+	assert.NotEqual(t, squat.UUID, workout.Entries[1].UUID)
+
+	// an entry of another workout cannot be claimed
+	other, err := store.CreateWorkout(ctx, &Workout{
+		UserID: userID, Title: "Push Day", DurationMinutes: 60,
+		Entries: []WorkoutEntries{entry("Bench Press", 0)},
+	})
+	require.NoError(t, err)
+	other, err = store.GetWorkoutByID(ctx, int64(other.ID))
+	require.NoError(t, err)
+	bench := other.Entries[0]
+
+	claimed := bench
+	claimed.UUID = ""
+	workout = save(workout, changed, claimed)
+	require.Len(t, workout.Entries, 2)
+	assert.NotEqual(t, bench.ID, workout.Entries[1].ID)
+	assert.Equal(t, "Bench Press", workout.Entries[1].ExerciseName)
+
+	workout.Entries = []WorkoutEntries{changed, {ExerciseName: "Bench Press", Sets: 3, Reps: intPtr(5), UUID: bench.UUID, OrderIndex: 1}}
+	err = store.UpdateWorkout(ctx, workout)
+	assert.ErrorIs(t, err, ErrConflict)
+
+	other, err = store.GetWorkoutByID(ctx, int64(other.ID))
+	require.NoError(t, err)
+	require.Len(t, other.Entries, 1)
+	assert.Equal(t, bench.ID, other.Entries[0].ID)
+	assert.Equal(t, 100.0, *other.Entries[0].Weight)
+}
+
 func intPtr(i int) *int {
 	return &i
 }
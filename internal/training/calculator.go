@@ -0,0 +1,102 @@
+package training
+
+import (
+	"context"
+	"errors"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"log/slog"
+)
+
+// batchSize is how many workouts a run brings up to date.
+const batchSize = 100
+
+// Calculator keeps the stored training loads up to date.
+type Calculator struct {
+	training store.TrainingStore
+	workouts store.WorkoutStore
+	tracks   store.TrackStore
+	logger   *slog.Logger
+}
+
+func NewCalculator(training store.TrainingStore, workouts store.WorkoutStore, tracks store.TrackStore, logger *slog.Logger) *Calculator {
+	return &Calculator{
+		training: training,
+		workouts: workouts,
+		tracks:   tracks,
+		logger:   logger,
+	}
+}
+
+// RunPending recomputes one batch of stale training loads. Anything left
+// over is picked up by the next run. A workout that fails is logged and
+// marked failed, which moves it behind the others, so it does not hold up
+// the rest.
+func (c *Calculator) RunPending(ctx context.Context) error {
+	ids, err := c.training.ListStaleTrainingLoads(ctx, batchSize)
+	if err != nil {
+		return err
+	}
+
+	updated := 0
+	settings := map[int]*store.HeartRateSettings{}
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err = c.update(ctx, id, settings)
+		if err != nil {
+			c.logger.ErrorContext(ctx, "updating training load", "workout_id", id, "error", err)
+			if err := c.training.MarkTrainingLoadFailed(ctx, id); err != nil {
+				c.logger.ErrorContext(ctx, "marking training load failed", "workout_id", id, "error", err)
+			}
+			continue
+		}
+		updated++
+	}
+
+	if updated > 0 {
+		c.logger.InfoContext(ctx, "training loads updated", "workouts", updated)
+	}
+	return nil
+}
+
+// update computes and saves the training load of a workout, or removes it
+// when the workout no longer has heart rate data. settings caches the
+// settings of the owners seen in this run.
+func (c *Calculator) update(ctx context.Context, workoutID int64, settings map[int]*store.HeartRateSettings) error {
+	workout, err := c.workouts.GetWorkoutByID(ctx, workoutID)
+	if errors.Is(err, store.ErrNotFound) {
+		// deleted since it was listed
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	userSettings, ok := settings[workout.UserID]
+	if !ok {
+		userSettings, err = c.training.GetHeartRateSettings(ctx, workout.UserID)
+		if err != nil {
+			return err
+		}
+		settings[workout.UserID] = userSettings
+	}
+
+	track, err := c.tracks.GetTrack(ctx, workoutID)
+	if errors.Is(err, store.ErrNotFound) {
+		track = nil
+	} else if err != nil {
+		return err
+	}
+
+	load, err := Compute(userSettings, workout, track)
+	if err != nil {
+		return err
+	}
+	if load == nil {
+		return c.training.DeleteTrainingLoad(ctx, workoutID)
+	}
+
+	return c.training.SaveTrainingLoad(ctx, load, userSettings)
+}
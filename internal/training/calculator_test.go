@@ -0,0 +1,94 @@
+package training
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+)
+
+type fakeTrainingStore struct {
+	store.TrainingStore
+	stale   []int64
+	saved   []int64
+	deleted []int64
+	failed  []int64
+}
+
+func (s *fakeTrainingStore) ListStaleTrainingLoads(ctx context.Context, limit int) ([]int64, error) {
+	return s.stale, nil
+}
+
+func (s *fakeTrainingStore) GetHeartRateSettings(ctx context.Context, userID int) (*store.HeartRateSettings, error) {
+	return &store.HeartRateSettings{UserID: userID, Method: store.ZonesFromReserve, MaxHeartRate: intPtr(190), RestingHeartRate: intPtr(50)}, nil
+}
+
+func (s *fakeTrainingStore) SaveTrainingLoad(ctx context.Context, load *store.TrainingLoad, settings *store.HeartRateSettings) error {
+	s.saved = append(s.saved, int64(load.WorkoutID))
+	return nil
+}
+
+func (s *fakeTrainingStore) DeleteTrainingLoad(ctx context.Context, workoutID int64) error {
+	s.deleted = append(s.deleted, workoutID)
+	return nil
+}
+
+func (s *fakeTrainingStore) MarkTrainingLoadFailed(ctx context.Context, workoutID int64) error {
+	s.failed = append(s.failed, workoutID)
+	return nil
+}
+
+type fakeWorkoutStore struct {
+	store.WorkoutStore
+	workouts map[int64]*store.Workout
+	errs     map[int64]error
+}
+
+func (s *fakeWorkoutStore) GetWorkoutByID(ctx context.Context, id int64) (*store.Workout, error) {
+	if err := s.errs[id]; err != nil {
+		return nil, err
+	}
+	workout, ok := s.workouts[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return workout, nil
+}
+
+type fakeTrackStore struct {
+	store.TrackStore
+}
+
+func (s *fakeTrackStore) GetTrack(ctx context.Context, workoutID int64) (*store.Track, error) {
+	return nil, store.ErrNotFound
+}
+
+func TestCalculatorRunPending(t *testing.T) {
+	cardio := []store.WorkoutEntries{{Type: store.EntryCardio, Sets: 1, DurationSeconds: intPtr(1800), AvgHeartRate: intPtr(120)}}
+	strength := []store.WorkoutEntries{{Type: store.EntryStrength, Sets: 3, Reps: intPtr(10)}}
+
+	training := &fakeTrainingStore{stale: []int64{1, 2, 3}}
+	workouts := &fakeWorkoutStore{
+		workouts: map[int64]*store.Workout{
+			2: {ID: 2, UserID: 1, Version: 1, Entries: cardio},
+			3: {ID: 3, UserID: 1, Version: 1, Entries: strength},
+		},
+		errs: map[int64]error{1: errors.New("statement timeout")},
+	}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	err := NewCalculator(training, workouts, &fakeTrackStore{}, logger).RunPending(context.Background())
+	require.NoError(t, err)
+
+	// the failing workout is logged and marked, the others still get done
+	assert.Contains(t, logs.String(), "statement timeout")
+	assert.Equal(t, []int64{1}, training.failed)
+	assert.Equal(t, []int64{2}, training.saved)
+	assert.Equal(t, []int64{3}, training.deleted)
+}
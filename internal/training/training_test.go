@@ -0,0 +1,141 @@
+package training
+
+import (
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestZones(t *testing.T) {
+	test := []struct {
+		name       string
+		settings   store.HeartRateSettings
+		wantStarts []int
+	}{
+		{
+			name:       "Max",
+			settings:   store.HeartRateSettings{Method: store.ZonesFromMax, MaxHeartRate: intPtr(200)},
+			wantStarts: []int{100, 120, 140, 160, 180},
+		},
+		{
+			name:       "Reserve",
+			settings:   store.HeartRateSettings{Method: store.ZonesFromReserve, MaxHeartRate: intPtr(190), RestingHeartRate: intPtr(50)},
+			wantStarts: []int{120, 134, 148, 162, 176},
+		},
+		{
+			name:       "Threshold",
+			settings:   store.HeartRateSettings{Method: store.ZonesFromThreshold, LactateThresholdHeartRate: intPtr(170)},
+			wantStarts: []int{0, 145, 153, 162, 170},
+		},
+	}
+
+	for _, tt := range test {
+		t.Run(tt.name, func(t *testing.T) {
+			zones, err := Zones(&tt.settings)
+			require.NoError(t, err)
+			require.Len(t, zones, ZoneCount)
+
+			starts := make([]int, 0, len(zones))
+			for _, z := range zones {
+				starts = append(starts, z.MinHeartRate)
+			}
+			assert.Equal(t, tt.wantStarts, starts)
+			assert.Equal(t, tt.wantStarts[1]-1, *zones[0].MaxHeartRate)
+			assert.Nil(t, zones[ZoneCount-1].MaxHeartRate)
+		})
+	}
+
+	_, err := Zones(&store.HeartRateSettings{Method: store.ZonesFromReserve, MaxHeartRate: intPtr(190)})
+	assert.ErrorIs(t, err, ErrIncompleteSettings)
+}
+
+func TestComputeFromTrack(t *testing.T) {
+	start := time.Date(2024, 3, 1, 7, 0, 0, 0, time.UTC)
+	point := func(seconds int, bpm *int) store.TrackPoint {
+		return store.TrackPoint{Time: start.Add(time.Duration(seconds) * time.Second), HeartRate: bpm}
+	}
+
+	track := &store.Track{Points: []store.TrackPoint{
+		point(0, intPtr(110)),
+		point(10, intPtr(130)),
+		point(20, intPtr(185)),
+		// the recording was paused for 80 s
+		point(100, intPtr(150)),
+		point(110, nil),
+	}}
+	workout := &store.Workout{ID: 7, Version: 3}
+	settings := &store.HeartRateSettings{Method: store.ZonesFromMax, MaxHeartRate: intPtr(200)}
+
+	load, err := Compute(settings, workout, track)
+	require.NoError(t, err)
+	require.NotNil(t, load)
+
+	assert.Equal(t, 7, load.WorkoutID)
+	assert.Equal(t, 3, load.WorkoutVersion)
+	// without a resting heart rate the zones weight the minutes
+	assert.Equal(t, MethodEdwards, load.Method)
+	assert.Equal(t, []int{10, 10, 10, 0, 0}, load.ZoneSeconds)
+	assert.Equal(t, 1.0, load.TRIMP)
+}
+
+func TestComputeFromEntries(t *testing.T) {
+	workout := &store.Workout{Entries: []store.WorkoutEntries{
+		{Type: store.EntryCardio, Sets: 1, DurationSeconds: intPtr(1800), AvgHeartRate: intPtr(120)},
+		{Type: store.EntryStrength, Sets: 3, Reps: intPtr(10)},
+	}}
+	settings := &store.HeartRateSettings{Method: store.ZonesFromReserve, MaxHeartRate: intPtr(190), RestingHeartRate: intPtr(50)}
+
+	load, err := Compute(settings, workout, nil)
+	require.NoError(t, err)
+	require.NotNil(t, load)
+
+	assert.Equal(t, MethodBanister, load.Method)
+	assert.Equal(t, []int{1800, 0, 0, 0, 0}, load.ZoneSeconds)
+	// 30 min at half the reserve: 30 * 0.5 * 0.64 * e^0.96
+	assert.Equal(t, 25.1, load.TRIMP)
+
+	load, err = Compute(settings, &store.Workout{Entries: workout.Entries[1:]}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, load)
+}
+
+func TestWorkload(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	// a steady 100 a day for four weeks, then a week at 300
+	var daily []store.DailyTrainingLoad
+	for i := -27; i <= 7; i++ {
+		load := 100.0
+		if i > 0 {
+			load = 300
+		}
+		daily = append(daily, store.DailyTrainingLoad{Date: from.AddDate(0, 0, i), TRIMP: load, Workouts: 1})
+	}
+
+	days := Workload(daily, from, from.AddDate(0, 0, 8))
+	require.Len(t, days, 8)
+
+	steady := days[0]
+	assert.Equal(t, from, steady.Date)
+	assert.Equal(t, 700.0, steady.Acute)
+	assert.Equal(t, 700.0, steady.Chronic)
+	assert.Equal(t, 1.0, *steady.Ratio)
+	assert.Equal(t, StatusOptimal, steady.Status)
+
+	spike := days[7]
+	assert.Equal(t, 2100.0, spike.Acute)
+	assert.Equal(t, 1050.0, spike.Chronic)
+	assert.Equal(t, 2.0, *spike.Ratio)
+	assert.Equal(t, StatusHighRisk, spike.Status)
+
+	empty := Workload(nil, from, from.AddDate(0, 0, 1))
+	require.Len(t, empty, 1)
+	assert.Nil(t, empty[0].Ratio)
+	assert.Empty(t, empty[0].Status)
+}
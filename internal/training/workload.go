@@ -0,0 +1,88 @@
+package training
+
+import (
+	"github.com/oki-irawan/fem_project/internal/store"
+	"math"
+	"time"
+)
+
+// The rolling windows of the acute:chronic workload ratio, in days.
+const (
+	AcuteDays   = 7
+	ChronicDays = 28
+)
+
+// Workload statuses, from the acute:chronic ratio. Ratios between 0.8 and
+// 1.3 are the usual "sweet spot"; above 1.5 the injury risk rises sharply.
+const (
+	StatusUndertraining = "undertraining"
+	StatusOptimal       = "optimal"
+	StatusOverreaching  = "overreaching"
+	StatusHighRisk      = "high_risk"
+)
+
+// Day is the workload on a day and in the windows ending with it.
+type Day struct {
+	Date  time.Time `json:"date"`
+	TRIMP float64   `json:"trimp"`
+	// Acute is the load of the last AcuteDays days.
+	Acute float64 `json:"acute"`
+	// Chronic is the weekly average load of the last ChronicDays days, so
+	// it compares to Acute.
+	Chronic float64 `json:"chronic"`
+	// Ratio is Acute over Chronic, nil until there is chronic load.
+	Ratio  *float64 `json:"ratio"`
+	Status string   `json:"status,omitempty"`
+}
+
+// Workload returns a Day for every UTC day from from up to, but not
+// including, to. daily must be sorted and start ChronicDays-1 days before
+// from for the first windows to be complete.
+func Workload(daily []store.DailyTrainingLoad, from, to time.Time) []Day {
+	loads := make(map[time.Time]float64, len(daily))
+	for _, d := range daily {
+		loads[d.Date] = d.TRIMP
+	}
+
+	days := []Day{}
+	for date := from; date.Before(to); date = date.AddDate(0, 0, 1) {
+		day := Day{Date: date, TRIMP: loads[date]}
+
+		for i := 0; i < ChronicDays; i++ {
+			load := loads[date.AddDate(0, 0, -i)]
+			if i < AcuteDays {
+				day.Acute += load
+			}
+			day.Chronic += load
+		}
+		day.Chronic = round(day.Chronic * AcuteDays / ChronicDays)
+		day.Acute = round(day.Acute)
+
+		if day.Chronic > 0 {
+			ratio := math.Round(day.Acute/day.Chronic*100) / 100
+			day.Ratio = &ratio
+			day.Status = Status(ratio)
+		}
+
+		days = append(days, day)
+	}
+	return days
+}
+
+// Status returns the workload status of an acute:chronic ratio.
+func Status(ratio float64) string {
+	switch {
+	case ratio < 0.8:
+		return StatusUndertraining
+	case ratio <= 1.3:
+		return StatusOptimal
+	case ratio <= 1.5:
+		return StatusOverreaching
+	default:
+		return StatusHighRisk
+	}
+}
+
+func round(f float64) float64 {
+	return math.Round(f*10) / 10
+}
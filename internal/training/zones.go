@@ -0,0 +1,81 @@
+// Package training analyses heart rate data: time in zone, training load
+// (TRIMP) and the acute:chronic workload ratio.
+package training
+
+import (
+	"errors"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"math"
+)
+
+// ZoneCount is the number of heart rate zones.
+const ZoneCount = 5
+
+var ErrIncompleteSettings = errors.New("heart rate settings are incomplete for their method")
+
+// Zone is a heart rate range. Zones are contiguous: a zone ends where the
+// next begins, and time below zone 1 counts as zone 1.
+type Zone struct {
+	Number       int  `json:"zone"`
+	MinHeartRate int  `json:"min_heart_rate"`
+	MaxHeartRate *int `json:"max_heart_rate"`
+}
+
+// The lower bounds of the zones, as a fraction of the max heart rate or the
+// heart rate reserve, and of the lactate threshold (Friel).
+var (
+	percentStarts   = [ZoneCount]float64{0.5, 0.6, 0.7, 0.8, 0.9}
+	thresholdStarts = [ZoneCount]float64{0, 0.85, 0.9, 0.95, 1}
+)
+
+// Zones returns the heart rate zones settings describe.
+func Zones(settings *store.HeartRateSettings) ([]Zone, error) {
+	var starts [ZoneCount]int
+
+	switch settings.Method {
+	case store.ZonesFromMax:
+		if settings.MaxHeartRate == nil {
+			return nil, ErrIncompleteSettings
+		}
+		for i, f := range percentStarts {
+			starts[i] = int(math.Round(f * float64(*settings.MaxHeartRate)))
+		}
+	case store.ZonesFromReserve:
+		if settings.MaxHeartRate == nil || settings.RestingHeartRate == nil {
+			return nil, ErrIncompleteSettings
+		}
+		rest, reserve := float64(*settings.RestingHeartRate), float64(*settings.MaxHeartRate-*settings.RestingHeartRate)
+		for i, f := range percentStarts {
+			starts[i] = int(math.Round(rest + f*reserve))
+		}
+	case store.ZonesFromThreshold:
+		if settings.LactateThresholdHeartRate == nil {
+			return nil, ErrIncompleteSettings
+		}
+		for i, f := range thresholdStarts {
+			starts[i] = int(math.Round(f * float64(*settings.LactateThresholdHeartRate)))
+		}
+	default:
+		return nil, ErrIncompleteSettings
+	}
+
+	zones := make([]Zone, ZoneCount)
+	for i, start := range starts {
+		zones[i] = Zone{Number: i + 1, MinHeartRate: start}
+		if i+1 < ZoneCount {
+			end := starts[i+1] - 1
+			zones[i].MaxHeartRate = &end
+		}
+	}
+	return zones, nil
+}
+
+// zoneOf returns the index of the zone bpm falls in.
+func zoneOf(zones []Zone, bpm int) int {
+	for i := len(zones) - 1; i > 0; i-- {
+		if bpm >= zones[i].MinHeartRate {
+			return i
+		}
+	}
+	return 0
+}
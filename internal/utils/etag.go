@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ETag formats a resource version as a strong entity tag.
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// MatchETag reports whether etag is listed in an If-Match or If-None-Match
+// header value. Weak tags match their strong counterpart, and "*" matches
+// anything.
+func MatchETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
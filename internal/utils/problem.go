@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/logging"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/oki-irawan/fem_project/internal/tracing"
+	"net/http"
+)
+
+// Stable error codes. Clients should branch on these instead of on the
+// human readable detail.
+const (
+	CodeBadRequest           = "bad_request"
+	CodeInvalidRequestBody   = "invalid_request_body"
+	CodeInvalidID            = "invalid_id"
+	CodeUnauthorized         = "unauthorized"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeValidationFailed     = "validation_failed"
+	CodeRateLimited          = "rate_limited"
+	CodePreconditionFailed   = "precondition_failed"
+	CodeRequestTooLarge      = "request_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodePatchFailed          = "patch_failed"
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	CodeIdempotencyInFlight  = "idempotency_key_in_flight"
+	CodeSyncCursorExpired    = "sync_cursor_expired"
+	CodeInternalError        = "internal_error"
+)
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type      string             `json:"type"`
+	Title     string             `json:"title"`
+	Status    int                `json:"status"`
+	Detail    string             `json:"detail,omitempty"`
+	Instance  string             `json:"instance,omitempty"`
+	Code      string             `json:"code"`
+	RequestID string             `json:"request_id,omitempty"`
+	TraceID   string             `json:"trace_id,omitempty"`
+	Errors    []store.FieldError `json:"errors,omitempty"`
+}
+
+func NewProblem(r *http.Request, status int, code, detail string) *Problem {
+	return &Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: logging.RequestID(r.Context()),
+		TraceID:   tracing.TraceID(r.Context()),
+	}
+}
+
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) error {
+	return writeProblem(w, NewProblem(r, status, code, detail))
+}
+
+// WriteError maps err to a problem response. Domain errors from the store get
+// their matching status; anything else is reported as an internal error
+// without leaking its message.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) error {
+	var validationErr *store.ValidationError
+	var conflictErr *store.ConflictError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return WriteProblem(w, r, http.StatusRequestEntityTooLarge, CodeRequestTooLarge, fmt.Sprintf("The request body must not be larger than %d bytes", maxBytesErr.Limit))
+	case errors.Is(err, ErrInvalidRequestBody):
+		return WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequestBody, "Invalid request body")
+	case errors.As(err, &validationErr):
+		p := NewProblem(r, http.StatusUnprocessableEntity, CodeValidationFailed, "The request is invalid")
+		p.Errors = validationErr.Fields
+		return writeProblem(w, p)
+	case errors.As(err, &conflictErr):
+		return WriteProblem(w, r, http.StatusConflict, CodeConflict, conflictErr.Error())
+	case errors.Is(err, store.ErrValidation):
+		return WriteProblem(w, r, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error())
+	case errors.Is(err, store.ErrConflict):
+		return WriteProblem(w, r, http.StatusConflict, CodeConflict, "The resource conflicts with an existing one")
+	case errors.Is(err, store.ErrNotFound):
+		return WriteProblem(w, r, http.StatusNotFound, CodeNotFound, "The resource does not exist")
+	case errors.Is(err, store.ErrVersionMismatch):
+		return WriteProblem(w, r, http.StatusPreconditionFailed, CodePreconditionFailed, "The resource was modified by another request")
+	case errors.Is(err, store.ErrForbidden):
+		return WriteProblem(w, r, http.StatusForbidden, CodeForbidden, "You are not allowed to access this resource")
+	case errors.Is(err, store.ErrCursorExpired):
+		return WriteProblem(w, r, http.StatusGone, CodeSyncCursorExpired, "The sync cursor has expired, sync again without since")
+	}
+
+	return WriteProblem(w, r, http.StatusInternalServerError, CodeInternalError, "Internal server error")
+}
+
+func writeProblem(w http.ResponseWriter, p *Problem) error {
+	js, err := json.MarshalIndent(p, "", " ")
+	if err != nil {
+		return err
+	}
+
+	js = append(js, '\n')
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	w.Write(js)
+
+	return nil
+}
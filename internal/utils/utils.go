@@ -3,6 +3,7 @@ package utils
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5"
 	"net/http"
 	"strconv"
@@ -26,15 +27,38 @@ func WriteJSON(w http.ResponseWriter, statusCode int, data Envelope) error {
 }
 
 func ReadIdParameter(r *http.Request) (int64, error) {
-	idParam := chi.URLParam(r, "id")
-	if idParam == "" {
-		return 0, errors.New("invalid id parameter")
+	return ReadIntParameter(r, "id")
+}
+
+// ReadIntParameter reads the integer URL parameter name.
+func ReadIntParameter(r *http.Request, name string) (int64, error) {
+	param := chi.URLParam(r, name)
+	if param == "" {
+		return 0, fmt.Errorf("invalid %s parameter", name)
 	}
 
-	id, err := strconv.ParseInt(idParam, 10, 64)
+	value, err := strconv.ParseInt(param, 10, 64)
 	if err != nil {
-		return 0, errors.New("invalid id parameter")
+		return 0, fmt.Errorf("invalid %s parameter", name)
+	}
+
+	return value, nil
+}
+
+var ErrInvalidRequestBody = errors.New("invalid request body")
+
+// ReadJSON decodes the request body into dst. Malformed bodies are reported
+// as ErrInvalidRequestBody and oversized ones as *http.MaxBytesError.
+func ReadJSON(r *http.Request, dst any) error {
+	err := json.NewDecoder(r.Body).Decode(dst)
+	if err == nil {
+		return nil
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return maxBytesErr
 	}
 
-	return id, nil
+	return fmt.Errorf("%w: %v", ErrInvalidRequestBody, err)
 }
@@ -0,0 +1,38 @@
+package validator
+
+import (
+	"github.com/oki-irawan/fem_project/internal/store"
+	"math"
+)
+
+// Goal mirrors the valid_goal CHECK constraint.
+func Goal(v *Validator, goal *store.Goal) {
+	v.In("type", goal.Type, store.GoalLift, store.GoalWorkouts, store.GoalDistance, store.GoalBodyWeight)
+	v.MaxLength("title", goal.Title, 255)
+	v.Check(goal.Target > 0, "target", "out_of_range", "target must be greater than 0")
+	v.Check(!goal.StartsOn.IsZero(), "starts_on", "required", "starts_on is required")
+	if goal.Deadline != nil {
+		v.Check(!goal.Deadline.Before(goal.StartsOn.Time), "deadline", "out_of_range", "deadline must not be before starts_on")
+	}
+	if goal.Period != nil {
+		v.In("period", *goal.Period, store.PeriodWeek, store.PeriodMonth, store.PeriodQuarter, store.PeriodYear)
+	}
+
+	switch goal.Type {
+	case store.GoalLift:
+		v.Check(goal.ExerciseName != nil && *goal.ExerciseName != "", "exercise_name", "required", "exercise_name is required for lift goals")
+		if goal.ExerciseName != nil {
+			v.MaxLength("exercise_name", *goal.ExerciseName, 255)
+		}
+		v.Check(goal.Period == nil, "period", "not_allowed", "period is only allowed for workouts and distance goals")
+	case store.GoalWorkouts, store.GoalDistance:
+		v.Check(goal.ExerciseName == nil, "exercise_name", "not_allowed", "exercise_name is only allowed for lift goals")
+		if goal.Type == store.GoalWorkouts {
+			v.Check(goal.Target == math.Trunc(goal.Target), "target", "not_integer", "target must be a whole number of workouts")
+		}
+	case store.GoalBodyWeight:
+		v.Check(goal.ExerciseName == nil, "exercise_name", "not_allowed", "exercise_name is only allowed for lift goals")
+		v.Check(goal.Period == nil, "period", "not_allowed", "period is only allowed for workouts and distance goals")
+		v.Range("target", goal.Target, MinBodyWeight, MaxBodyWeight)
+	}
+}
@@ -0,0 +1,30 @@
+package validator
+
+import (
+	"github.com/oki-irawan/fem_project/internal/store"
+)
+
+// Measurement mirrors the valid_body_measurement CHECK constraint.
+func Measurement(v *Validator, m *store.BodyMeasurement) {
+	v.Check(!m.MeasuredOn.IsZero(), "measured_on", "required", "measured_on is required")
+	v.MaxLength("notes", m.Notes, 1000)
+
+	recorded := false
+	for _, field := range m.Fields() {
+		value := *field.Value
+		if value == nil {
+			continue
+		}
+		recorded = true
+
+		switch field.Name {
+		case "body_weight_kg":
+			v.Range(field.Name, *value, MinBodyWeight, MaxBodyWeight)
+		case "body_fat_percent":
+			v.Range(field.Name, *value, 1, 75)
+		default:
+			v.Range(field.Name, *value, 10, 300)
+		}
+	}
+	v.Check(recorded, "body_weight_kg", "required", "a measurement needs at least one value")
+}
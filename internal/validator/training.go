@@ -0,0 +1,27 @@
+package validator
+
+import (
+	"github.com/oki-irawan/fem_project/internal/store"
+)
+
+// HeartRateSettings mirrors the valid_heart_rate_settings CHECK constraint.
+func HeartRateSettings(v *Validator, settings *store.HeartRateSettings) {
+	v.In("method", settings.Method, store.ZonesFromMax, store.ZonesFromReserve, store.ZonesFromThreshold)
+
+	switch settings.Method {
+	case store.ZonesFromMax:
+		v.Check(settings.MaxHeartRate != nil, "max_heart_rate", "required", "max_heart_rate is required for max based zones")
+	case store.ZonesFromReserve:
+		v.Check(settings.MaxHeartRate != nil, "max_heart_rate", "required", "max_heart_rate is required for heart rate reserve zones")
+		v.Check(settings.RestingHeartRate != nil, "resting_heart_rate", "required", "resting_heart_rate is required for heart rate reserve zones")
+	case store.ZonesFromThreshold:
+		v.Check(settings.LactateThresholdHeartRate != nil, "lactate_threshold_heart_rate", "required", "lactate_threshold_heart_rate is required for threshold zones")
+	}
+
+	heartRate(v, "max_heart_rate", settings.MaxHeartRate)
+	heartRate(v, "resting_heart_rate", settings.RestingHeartRate)
+	heartRate(v, "lactate_threshold_heart_rate", settings.LactateThresholdHeartRate)
+	if settings.RestingHeartRate != nil && settings.MaxHeartRate != nil {
+		v.Check(*settings.RestingHeartRate < *settings.MaxHeartRate, "resting_heart_rate", "too_large", "resting_heart_rate must be below max_heart_rate")
+	}
+}
@@ -0,0 +1,131 @@
+package validator
+
+import (
+	"fmt"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var EmailRX = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
+var UUIDRX = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Validator collects field errors so a request can report every problem at
+// once instead of stopping at the first one.
+type Validator struct {
+	prefix string
+	errors *[]store.FieldError
+}
+
+// Validatable is implemented by request types that know their own rules.
+type Validatable interface {
+	Validate(v *Validator)
+}
+
+func New() *Validator {
+	return &Validator{errors: &[]store.FieldError{}}
+}
+
+// Validate runs the rules of req and returns a *store.ValidationError when
+// any of them fail.
+func Validate(req Validatable) error {
+	v := New()
+	req.Validate(v)
+	return v.Err()
+}
+
+// Field returns a validator that reports errors under name, e.g.
+// v.Field("entries").Index(0) reports as "entries[0].<field>".
+func (v *Validator) Field(name string) *Validator {
+	return &Validator{prefix: v.path(name) + ".", errors: v.errors}
+}
+
+func (v *Validator) Index(i int) *Validator {
+	return &Validator{prefix: strings.TrimSuffix(v.prefix, ".") + fmt.Sprintf("[%d].", i), errors: v.errors}
+}
+
+func (v *Validator) path(field string) string {
+	return v.prefix + field
+}
+
+func (v *Validator) AddError(field, code, message string) {
+	*v.errors = append(*v.errors, store.FieldError{Field: v.path(field), Code: code, Message: message})
+}
+
+// Check adds an error for field unless ok is true.
+func (v *Validator) Check(ok bool, field, code, message string) {
+	if !ok {
+		v.AddError(field, code, message)
+	}
+}
+
+func (v *Validator) Required(field, value string) {
+	v.Check(strings.TrimSpace(value) != "", field, "required", field+" is required")
+}
+
+func (v *Validator) MaxLength(field, value string, n int) {
+	v.Check(utf8.RuneCountInString(value) <= n, field, "too_long", fmt.Sprintf("%s must be at most %d characters", field, n))
+}
+
+func (v *Validator) Email(field, value string) {
+	if value == "" {
+		return
+	}
+	v.Check(EmailRX.MatchString(value), field, "invalid_format", "invalid email format")
+}
+
+func (v *Validator) UUID(field, value string) {
+	v.Check(UUIDRX.MatchString(value), field, "invalid_format", field+" must be a UUID")
+}
+
+// In checks that value is one of allowed.
+func (v *Validator) In(field, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.AddError(field, "invalid_value", field+" must be one of "+strings.Join(allowed, ", "))
+}
+
+func (v *Validator) Min(field string, value, min int) {
+	v.Check(value >= min, field, "too_small", fmt.Sprintf("%s must be at least %d", field, min))
+}
+
+func (v *Validator) Max(field string, value, max int) {
+	v.Check(value <= max, field, "too_large", fmt.Sprintf("%s must be at most %d", field, max))
+}
+
+func (v *Validator) Range(field string, value, min, max float64) {
+	v.Check(value >= min && value <= max, field, "out_of_range", fmt.Sprintf("%s must be between %g and %g", field, min, max))
+}
+
+// ExactlyOne checks that exactly one of fields is set, e.g.
+// v.ExactlyOne([]string{"reps", "duration_seconds"}, reps != nil, duration != nil).
+// The error is reported on the first field.
+func (v *Validator) ExactlyOne(fields []string, set ...bool) {
+	count := 0
+	for _, isSet := range set {
+		if isSet {
+			count++
+		}
+	}
+	if count == 1 {
+		return
+	}
+
+	v.AddError(fields[0], "exactly_one", "exactly one of "+strings.Join(fields, ", ")+" must be provided")
+}
+
+func (v *Validator) Valid() bool {
+	return len(*v.errors) == 0
+}
+
+func (v *Validator) Err() error {
+	if v.Valid() {
+		return nil
+	}
+	return &store.ValidationError{Fields: *v.errors}
+}
@@ -0,0 +1,143 @@
+package validator
+
+import (
+	"errors"
+	"github.com/oki-irawan/fem_project/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestValidator(t *testing.T) {
+	test := []struct {
+		name       string
+		run        func(v *Validator)
+		wantFields []string
+	}{
+		{
+			name: "Valid",
+			run: func(v *Validator) {
+				v.Required("title", "Push Day")
+				v.Min("sets", 3, 1)
+				v.ExactlyOne([]string{"reps", "duration_seconds"}, true, false)
+			},
+		},
+		{
+			name: "Aggregates Errors",
+			run: func(v *Validator) {
+				v.Required("title", "  ")
+				v.Min("duration_minutes", -5, 1)
+				v.Email("email", "not-an-email")
+			},
+			wantFields: []string{"title", "duration_minutes", "email"},
+		},
+		{
+			name: "UUID And Enum",
+			run: func(v *Validator) {
+				v.UUID("uuid", "2f1c9a52-8d3e-4b7a-9c61-0e5d4f3a2b10")
+				v.UUID("other", "not-a-uuid")
+				v.In("op", "upsert", "upsert", "delete")
+				v.In("entity", "template", "workout")
+			},
+			wantFields: []string{"other", "entity"},
+		},
+		{
+			name: "Nested Fields",
+			run: func(v *Validator) {
+				entries := v.Field("entries")
+				entries.Index(0).Required("exercise_name", "Squat")
+				entries.Index(1).ExactlyOne([]string{"reps", "duration_seconds"}, true, true)
+				entries.Index(2).ExactlyOne([]string{"reps", "duration_seconds"}, false, false)
+			},
+			wantFields: []string{"entries[1].reps", "entries[2].reps"},
+		},
+		{
+			name: "Cardio Entries",
+			run: func(v *Validator) {
+				seconds, distance, hr, maxHR := 1500, 5.0, 150, 140
+				entries := v.Field("entries")
+				Entry(entries.Index(0), &store.WorkoutEntries{
+					Type: store.EntryCardio, ExerciseName: "Running", Sets: 1, DurationSeconds: &seconds,
+					Distance: &distance, DistanceUnit: store.UnitKilometers,
+					Intervals: []store.EntryInterval{{DurationSeconds: 300, Distance: &distance}},
+				})
+				Entry(entries.Index(1), &store.WorkoutEntries{
+					ExerciseName: "Squat", Sets: 1, DurationSeconds: &seconds, Distance: &distance, DistanceUnit: "yd",
+				})
+				Entry(entries.Index(2), &store.WorkoutEntries{
+					Type: store.EntryCardio, ExerciseName: "Rowing", Sets: 1, AvgHeartRate: &hr, MaxHeartRate: &maxHR,
+					Intervals: []store.EntryInterval{{DurationSeconds: 60, Distance: &distance}},
+				})
+			},
+			wantFields: []string{
+				"entries[1].distance", "entries[1].distance_unit",
+				"entries[2].duration_seconds", "entries[2].avg_heart_rate", "entries[2].intervals[0].distance",
+			},
+		},
+		{
+			name: "Heart Rate Settings",
+			run: func(v *Validator) {
+				maxHR, resting := 190, 195
+				HeartRateSettings(v, &store.HeartRateSettings{Method: store.ZonesFromMax, MaxHeartRate: &maxHR})
+				HeartRateSettings(v, &store.HeartRateSettings{Method: store.ZonesFromReserve, MaxHeartRate: &maxHR, RestingHeartRate: &resting})
+				HeartRateSettings(v.Field("other"), &store.HeartRateSettings{Method: store.ZonesFromThreshold})
+			},
+			wantFields: []string{"resting_heart_rate", "other.lactate_threshold_heart_rate"},
+		},
+		{
+			name: "Body Measurements",
+			run: func(v *Validator) {
+				weight, fat, waist := 80.5, 90.0, 5.0
+				Measurement(v.Field("valid"), &store.BodyMeasurement{
+					MeasuredOn:        store.NewDate(time.Now()),
+					MeasurementValues: store.MeasurementValues{BodyWeightKg: &weight},
+				})
+				Measurement(v.Field("invalid"), &store.BodyMeasurement{
+					MeasurementValues: store.MeasurementValues{BodyFatPercent: &fat, WaistCm: &waist},
+				})
+				Measurement(v.Field("empty"), &store.BodyMeasurement{MeasuredOn: store.NewDate(time.Now())})
+			},
+			wantFields: []string{"invalid.measured_on", "invalid.body_fat_percent", "invalid.waist_cm", "empty.body_weight_kg"},
+		},
+		{
+			name: "Goals",
+			run: func(v *Validator) {
+				week, bench := store.PeriodWeek, "Bench Press"
+				start := store.NewDate(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+				deadline := store.NewDate(start.AddDate(0, 0, -1))
+				Goal(v.Field("valid"), &store.Goal{Type: store.GoalWorkouts, Target: 4, Period: &week, StartsOn: start})
+				Goal(v.Field("lift"), &store.Goal{Type: store.GoalLift, Target: 100, Period: &week, StartsOn: start, Deadline: &deadline})
+				Goal(v.Field("weight"), &store.Goal{Type: store.GoalBodyWeight, ExerciseName: &bench, Target: 7.5, StartsOn: start})
+			},
+			wantFields: []string{
+				"lift.deadline", "lift.exercise_name", "lift.period",
+				"weight.exercise_name", "weight.target",
+			},
+		},
+	}
+
+	for _, tt := range test {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New()
+			tt.run(v)
+
+			err := v.Err()
+			if len(tt.wantFields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, store.ErrValidation)
+
+			var validationErr *store.ValidationError
+			require.True(t, errors.As(err, &validationErr))
+
+			fields := make([]string, 0, len(validationErr.Fields))
+			for _, f := range validationErr.Fields {
+				fields = append(fields, f.Field)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package validator
+
+import (
+	"github.com/oki-irawan/fem_project/internal/store"
+)
+
+// MaxWeight is the largest value workout_entries.weight (DECIMAL(5,2)) holds.
+const MaxWeight = 999.99
+
+// Body weights in kg outside this range are typos; body_measurements
+// enforces the same.
+const (
+	MinBodyWeight = 20
+	MaxBodyWeight = 500
+)
+
+// Heart rates outside this range are sensor errors.
+const (
+	MinHeartRate = 20
+	MaxHeartRate = 250
+)
+
+// Workout checks the rules every saved workout must follow, whichever way
+// it was written.
+func Workout(v *Validator, workout *store.Workout) {
+	v.Required("title", workout.Title)
+	v.MaxLength("title", workout.Title, 255)
+	v.Min("duration_minutes", workout.DurationMinutes, 1)
+	v.Min("calories_burned", workout.CaloriesBurned, 0)
+
+	for i := range workout.Entries {
+		Entry(v.Field("entries").Index(i), &workout.Entries[i])
+	}
+}
+
+func Entry(v *Validator, entry *store.WorkoutEntries) {
+	v.Required("exercise_name", entry.ExerciseName)
+	v.MaxLength("exercise_name", entry.ExerciseName, 255)
+	v.Min("sets", entry.Sets, 1)
+	v.Min("order_index", entry.OrderIndex, 0)
+
+	// mirrors the valid_workout_entry CHECK constraint
+	switch entry.Type {
+	case "", store.EntryStrength:
+		v.ExactlyOne([]string{"reps", "duration_seconds"}, entry.Reps != nil, entry.DurationSeconds != nil)
+		v.Check(entry.Distance == nil, "distance", "not_allowed", "distance is only allowed on cardio entries")
+		v.Check(entry.ElevationGainMeters == nil, "elevation_gain_meters", "not_allowed", "elevation_gain_meters is only allowed on cardio entries")
+		v.Check(len(entry.Intervals) == 0, "intervals", "not_allowed", "intervals are only allowed on cardio entries")
+	case store.EntryCardio:
+		v.Check(entry.DurationSeconds != nil, "duration_seconds", "required", "duration_seconds is required for cardio entries")
+		v.Check(entry.Reps == nil, "reps", "not_allowed", "reps are not allowed on cardio entries")
+	default:
+		v.In("type", entry.Type, store.EntryStrength, store.EntryCardio)
+	}
+
+	if entry.Reps != nil {
+		v.Min("reps", *entry.Reps, 1)
+	}
+	if entry.DurationSeconds != nil {
+		v.Min("duration_seconds", *entry.DurationSeconds, 1)
+	}
+	if entry.Weight != nil {
+		v.Range("weight", *entry.Weight, 0, MaxWeight)
+	}
+
+	if entry.Distance != nil {
+		v.Check(*entry.Distance > 0, "distance", "too_small", "distance must be greater than 0")
+		v.Required("distance_unit", entry.DistanceUnit)
+	}
+	if entry.DistanceUnit != "" {
+		v.In("distance_unit", entry.DistanceUnit, store.UnitMeters, store.UnitKilometers, store.UnitMiles)
+		v.Check(entry.Distance != nil, "distance", "required", "distance is required with distance_unit")
+	}
+	if entry.ElevationGainMeters != nil {
+		v.Check(*entry.ElevationGainMeters >= 0, "elevation_gain_meters", "too_small", "elevation_gain_meters must not be negative")
+	}
+	heartRate(v, "avg_heart_rate", entry.AvgHeartRate)
+	heartRate(v, "max_heart_rate", entry.MaxHeartRate)
+	if entry.AvgHeartRate != nil && entry.MaxHeartRate != nil {
+		v.Check(*entry.AvgHeartRate <= *entry.MaxHeartRate, "avg_heart_rate", "too_large", "avg_heart_rate must not exceed max_heart_rate")
+	}
+
+	total := 0
+	for i := range entry.Intervals {
+		interval := &entry.Intervals[i]
+		iv := v.Field("intervals").Index(i)
+
+		iv.Min("duration_seconds", interval.DurationSeconds, 1)
+		if interval.Distance != nil {
+			iv.Check(*interval.Distance > 0, "distance", "too_small", "distance must be greater than 0")
+			iv.Check(entry.DistanceUnit != "", "distance", "not_allowed", "interval distances need a distance_unit on the entry")
+		}
+		heartRate(iv, "avg_heart_rate", interval.AvgHeartRate)
+		total += interval.DurationSeconds
+	}
+	if entry.DurationSeconds != nil {
+		v.Check(total <= *entry.DurationSeconds, "intervals", "too_large", "intervals must not last longer than the entry")
+	}
+}
+
+// heartRate checks a heart rate in beats per minute against the range the
+// database allows.
+func heartRate(v *Validator, field string, bpm *int) {
+	if bpm != nil {
+		v.Min(field, *bpm, MinHeartRate)
+		v.Max(field, *bpm, MaxHeartRate)
+	}
+}
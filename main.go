@@ -1,40 +1,159 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/oki-irawan/fem_project/internal/app"
+	"github.com/oki-irawan/fem_project/internal/logging"
+	"github.com/oki-irawan/fem_project/internal/middleware"
+	"github.com/oki-irawan/fem_project/internal/ratelimit"
 	"github.com/oki-irawan/fem_project/internal/routes"
+	"github.com/oki-irawan/fem_project/internal/store"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 )
 
 func main() {
-	var port int
-	flag.IntVar(&port, "port", 8080, "port to listen on")
+	var cfg app.Config
+	flag.IntVar(&cfg.Port, "port", 8080, "port to listen on")
+	flag.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", 20*time.Second, "time to wait for in-flight requests on shutdown")
+	flag.DurationVar(&cfg.DrainDelay, "drain-delay", 5*time.Second, "time to report not ready before the listener is closed")
+	flag.DurationVar(&cfg.HealthCheckTimeout, "health-timeout", 2*time.Second, "timeout for each readiness check")
+	flag.StringVar(&cfg.MetricsToken, "metrics-token", os.Getenv("METRICS_TOKEN"), "bearer token required to scrape /metrics (open when empty)")
+	flag.StringVar(&cfg.TraceExporter, "trace-exporter", envOrDefault("TRACE_EXPORTER", "none"), "trace exporter: otlp, stdout or none")
+	flag.DurationVar(&cfg.RequestTimeout, "request-timeout", 25*time.Second, "deadline for handling a single request")
+	flag.DurationVar(&cfg.QueryTimeouts.Default, "query-timeout", 5*time.Second, "default timeout for a store operation")
+	logLevel := flag.String("log-level", envOrDefault("LOG_LEVEL", "info"), "log level: debug, info, warn or error")
+	queryTimeouts := flag.String("query-timeouts", os.Getenv("QUERY_TIMEOUTS"), "per operation timeouts, e.g. workouts.CreateWorkout=10s,users.GetUserToken=1s")
+	flag.BoolVar(&cfg.TrustProxy, "trust-proxy", false, "take the client IP from X-Forwarded-For and X-Real-IP")
+	flag.BoolVar(&cfg.RateLimit.Enabled, "ratelimit", true, "enable rate limiting")
+	flag.StringVar(&cfg.RateLimit.Backend, "ratelimit-backend", envOrDefault("RATELIMIT_BACKEND", "memory"), "rate limit backend: memory or postgres")
+	authLimit := flag.String("ratelimit-auth", "10/1m", "limit for login and sign up, as requests/period")
+	ipLimit := flag.String("ratelimit-ip", "1200/1m", "limit per client IP for authenticated routes, as requests/period")
+	readLimit := flag.String("ratelimit-read", "300/1m", "limit for reads, as requests/period")
+	writeLimit := flag.String("ratelimit-write", "60/1m", "limit for writes, as requests/period")
+	corsOrigins := flag.String("cors-origins", os.Getenv("CORS_ORIGINS"), "comma separated origins allowed to call the API, * for any")
+	flag.BoolVar(&cfg.CORS.AllowCredentials, "cors-credentials", false, "allow credentialed CORS requests")
+	flag.BoolVar(&cfg.HSTS, "hsts", false, "send Strict-Transport-Security, only when served over HTTPS")
+	flag.Int64Var(&cfg.MaxBodyBytes, "max-body-bytes", 1<<20, "maximum size of a request body")
+	flag.Int64Var(&cfg.ImportMaxBytes, "import-max-bytes", 10<<20, "maximum size of an uploaded import or activity file")
+	flag.DurationVar(&cfg.IdempotencyTTL, "idempotency-ttl", 24*time.Hour, "how long responses to Idempotency-Key requests are kept")
+	flag.DurationVar(&cfg.TrashRetention, "trash-retention", 30*24*time.Hour, "how long deleted workouts stay in the trash")
+	flag.DurationVar(&cfg.TombstoneRetention, "tombstone-retention", 90*24*time.Hour, "how long deletions are kept for offline clients to sync")
 	flag.Parse()
 
-	app, err := app.NewApplication()
+	// "https://a.example, https://b.example" and a trailing comma are fine
+	for _, origin := range strings.Split(*corsOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.CORS.AllowedOrigins = append(cfg.CORS.AllowedOrigins, origin)
+		}
+	}
+	cfg.CORS.AllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	cfg.CORS.AllowedHeaders = []string{"Authorization", "Content-Type", middleware.IdempotencyKeyHeader, "If-Match", "If-None-Match", middleware.RequestIDHeader}
+	cfg.CORS.ExposedHeaders = []string{"ETag", "Content-Disposition", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Idempotent-Replayed", "Accept-Patch", middleware.RequestIDHeader}
+	cfg.CORS.MaxAge = 600
+
+	overrides, err := store.ParseTimeoutOverrides(*queryTimeouts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	cfg.QueryTimeouts.Overrides = overrides
+
+	cfg.LogLevel, err = logging.ParseLevel(*logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	for _, p := range []struct {
+		policy *ratelimit.Policy
+		name   string
+		value  string
+	}{
+		{&cfg.RateLimit.Auth, "auth", *authLimit},
+		{&cfg.RateLimit.IP, "ip", *ipLimit},
+		{&cfg.RateLimit.Read, "read", *readLimit},
+		{&cfg.RateLimit.Write, "write", *writeLimit},
+	} {
+		*p.policy, err = ratelimit.ParsePolicy(p.name, p.value)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	app, err := app.NewApplication(cfg)
 	if err != nil {
 		panic(err)
 	}
-	defer app.DB.Close()
 
 	r := routes.SetupRoutes(app)
 
 	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", port),
+		Addr:         fmt.Sprintf(":%d", cfg.Port),
 		Handler:      r,
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
 
-	app.Logger.Printf("We are running on port %d\n", port)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err = app.Start(ctx)
+	if err != nil {
+		app.Logger.Error("starting application", "error", err)
+		os.Exit(1)
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		app.Logger.Info("server started", "port", cfg.Port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			app.Logger.Error("http server stopped", "error", err)
+		}
+	case <-ctx.Done():
+		app.Logger.Info("shutdown signal received, draining connections")
+	}
+	stop()
+
+	// report not ready first so the load balancer stops sending traffic
+	// before the listener goes away
+	app.Lifecycle.Drain()
+	time.Sleep(cfg.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
-	err = server.ListenAndServe()
+	err = server.Shutdown(shutdownCtx)
 	if err != nil {
-		app.Logger.Fatal(err)
+		app.Logger.Error("http server shutdown", "error", err)
 	}
 
+	err = app.Shutdown(shutdownCtx)
+	if err != nil {
+		app.Logger.Error("application shutdown", "error", err)
+		os.Exit(1)
+	}
+
+	app.Logger.Info("server stopped")
+}
+
+func envOrDefault(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
 }
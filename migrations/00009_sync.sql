@@ -0,0 +1,91 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+-- change_seq orders changes inside a transaction; change_txid orders the
+-- transactions themselves, so a sync cursor never skips a change that
+-- committed late.
+CREATE SEQUENCE IF NOT EXISTS change_seq;
+
+ALTER TABLE workouts
+    ADD COLUMN uuid UUID NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN change_txid BIGINT NOT NULL DEFAULT txid_current(),
+    ADD COLUMN change_seq BIGINT NOT NULL DEFAULT nextval('change_seq'),
+    ADD CONSTRAINT workouts_uuid_key UNIQUE (uuid);
+
+ALTER TABLE workout_entries
+    ADD COLUMN uuid UUID NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN change_txid BIGINT NOT NULL DEFAULT txid_current(),
+    ADD COLUMN change_seq BIGINT NOT NULL DEFAULT nextval('change_seq'),
+    ADD CONSTRAINT workout_entries_uuid_key UNIQUE (uuid);
+
+CREATE INDEX IF NOT EXISTS idx_workouts_user_change ON workouts (user_id, change_txid, change_seq);
+
+CREATE TABLE IF NOT EXISTS sync_tombstones (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    entity TEXT NOT NULL,
+    uuid UUID NOT NULL,
+    change_txid BIGINT NOT NULL DEFAULT txid_current(),
+    change_seq BIGINT NOT NULL DEFAULT nextval('change_seq'),
+    deleted_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_tombstones_user_change ON sync_tombstones (user_id, change_txid, change_seq);
+
+-- +goose StatementBegin
+CREATE FUNCTION track_change() RETURNS trigger AS $$
+BEGIN
+    NEW.change_txid := txid_current();
+    NEW.change_seq := nextval('change_seq');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER workouts_track_change BEFORE UPDATE ON workouts
+    FOR EACH ROW EXECUTE FUNCTION track_change();
+
+CREATE TRIGGER workout_entries_track_change BEFORE UPDATE ON workout_entries
+    FOR EACH ROW EXECUTE FUNCTION track_change();
+
+-- +goose StatementBegin
+CREATE FUNCTION record_workout_tombstone() RETURNS trigger AS $$
+BEGIN
+    -- nothing to record when the whole account is being deleted
+    INSERT INTO sync_tombstones (user_id, entity, uuid)
+    SELECT u.id, 'workout', OLD.uuid FROM users u WHERE u.id = OLD.user_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- Entries removed together with their workout are covered by the workout's
+-- tombstone; the parent row is already gone when the cascade fires.
+-- +goose StatementBegin
+CREATE FUNCTION record_workout_entry_tombstone() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO sync_tombstones (user_id, entity, uuid)
+    SELECT w.user_id, 'workout_entry', OLD.uuid FROM workouts w WHERE w.id = OLD.workout_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER workouts_tombstone AFTER DELETE ON workouts
+    FOR EACH ROW EXECUTE FUNCTION record_workout_tombstone();
+
+CREATE TRIGGER workout_entries_tombstone AFTER DELETE ON workout_entries
+    FOR EACH ROW EXECUTE FUNCTION record_workout_entry_tombstone();
+
+-- +goose Down
+DROP TRIGGER workout_entries_tombstone ON workout_entries;
+DROP TRIGGER workouts_tombstone ON workouts;
+DROP FUNCTION record_workout_entry_tombstone();
+DROP FUNCTION record_workout_tombstone();
+DROP TRIGGER workout_entries_track_change ON workout_entries;
+DROP TRIGGER workouts_track_change ON workouts;
+DROP FUNCTION track_change();
+DROP TABLE sync_tombstones;
+ALTER TABLE workout_entries DROP COLUMN uuid, DROP COLUMN change_txid, DROP COLUMN change_seq;
+ALTER TABLE workouts DROP COLUMN uuid, DROP COLUMN change_txid, DROP COLUMN change_seq;
+DROP SEQUENCE change_seq;
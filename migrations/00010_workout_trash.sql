@@ -0,0 +1,42 @@
+-- +goose Up
+ALTER TABLE workouts ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_workouts_deleted_at ON workouts (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- a trashed workout gives its title back so it can be reused
+ALTER TABLE workouts DROP CONSTRAINT workouts_title_key;
+CREATE UNIQUE INDEX workouts_title_key ON workouts (title) WHERE deleted_at IS NULL;
+
+-- moving a workout to the trash already shows up in the sync feed, purging
+-- it later needs no second tombstone
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_workout_tombstone() RETURNS trigger AS $$
+BEGIN
+    IF OLD.deleted_at IS NOT NULL THEN
+        RETURN OLD;
+    END IF;
+
+    -- nothing to record when the whole account is being deleted
+    INSERT INTO sync_tombstones (user_id, entity, uuid)
+    SELECT u.id, 'workout', OLD.uuid FROM users u WHERE u.id = OLD.user_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_workout_tombstone() RETURNS trigger AS $$
+BEGIN
+    -- nothing to record when the whole account is being deleted
+    INSERT INTO sync_tombstones (user_id, entity, uuid)
+    SELECT u.id, 'workout', OLD.uuid FROM users u WHERE u.id = OLD.user_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DELETE FROM workouts WHERE deleted_at IS NOT NULL;
+DROP INDEX workouts_title_key;
+ALTER TABLE workouts ADD CONSTRAINT workouts_title_key UNIQUE (title);
+ALTER TABLE workouts DROP COLUMN deleted_at;
@@ -0,0 +1,47 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS workout_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    author_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    reverted_from INTEGER,
+    snapshot JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT workout_revisions_workout_id_version_key UNIQUE (workout_id, version)
+);
+
+-- workouts saved before revisions existed start their history at the
+-- version they are at now
+INSERT INTO workout_revisions (workout_id, version, author_id, action, snapshot, created_at)
+SELECT w.id, w.version, w.user_id, 'baseline',
+    jsonb_build_object(
+        'id', w.id,
+        'uuid', w.uuid,
+        'user_id', w.user_id,
+        'title', w.title,
+        'description', COALESCE(w.description, ''),
+        'duration_minutes', w.duration_minutes,
+        'calories_burned', COALESCE(w.calories_burned, 0),
+        'version', w.version,
+        'entries', COALESCE((
+            SELECT jsonb_agg(jsonb_build_object(
+                'id', e.id,
+                'uuid', e.uuid,
+                'exercise_name', e.exercise_name,
+                'sets', e.sets,
+                'reps', e.reps,
+                'duration_seconds', e.duration_seconds,
+                'weight', e.weight,
+                'notes', COALESCE(e.notes, ''),
+                'order_index', e.order_index
+            ) ORDER BY e.order_index)
+            FROM workout_entries e
+            WHERE e.workout_id = w.id
+        ), '[]'::jsonb)
+    ),
+    COALESCE(w.updated_at, CURRENT_TIMESTAMP)
+FROM workouts w;
+
+-- +goose Down
+DROP TABLE workout_revisions;
@@ -0,0 +1,44 @@
+-- +goose Up
+ALTER TABLE workouts ADD COLUMN performed_at TIMESTAMP WITH TIME ZONE;
+UPDATE workouts SET performed_at = COALESCE(created_at, CURRENT_TIMESTAMP);
+ALTER TABLE workouts
+    ALTER COLUMN performed_at SET NOT NULL,
+    ALTER COLUMN performed_at SET DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_workouts_user_performed_at ON workouts (user_id, performed_at);
+
+-- the date never changed before it existed, so older snapshots get today's
+UPDATE workout_revisions r
+SET snapshot = r.snapshot || jsonb_build_object('performed_at', w.performed_at)
+FROM workouts w
+WHERE w.id = r.workout_id;
+
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    format TEXT NOT NULL,
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    filename TEXT NOT NULL DEFAULT '',
+    data BYTEA NOT NULL,
+    total_workouts INTEGER NOT NULL DEFAULT 0,
+    processed_workouts INTEGER NOT NULL DEFAULT 0,
+    created_workouts INTEGER NOT NULL DEFAULT 0,
+    duplicate_workouts INTEGER NOT NULL DEFAULT 0,
+    failed_workouts INTEGER NOT NULL DEFAULT 0,
+    report JSONB,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    heartbeat_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_jobs_user_id ON import_jobs (user_id, id);
+CREATE INDEX IF NOT EXISTS idx_import_jobs_status ON import_jobs (status) WHERE status IN ('pending', 'running');
+
+-- +goose Down
+DROP TABLE import_jobs;
+UPDATE workout_revisions SET snapshot = snapshot - 'performed_at';
+DROP INDEX idx_workouts_user_performed_at;
+ALTER TABLE workouts DROP COLUMN performed_at;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS workout_tracks (
+    workout_id BIGINT PRIMARY KEY REFERENCES workouts(id) ON DELETE CASCADE,
+    format TEXT NOT NULL,
+    sport TEXT NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    elapsed_seconds INTEGER NOT NULL,
+    moving_seconds INTEGER NOT NULL,
+    distance_meters DOUBLE PRECISION NOT NULL,
+    elevation_gain_meters DOUBLE PRECISION NOT NULL,
+    elevation_loss_meters DOUBLE PRECISION NOT NULL,
+    avg_heart_rate INTEGER,
+    max_heart_rate INTEGER,
+    -- points are only ever read as a whole, for the map
+    points JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE workout_tracks;
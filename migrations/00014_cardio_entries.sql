@@ -0,0 +1,58 @@
+-- +goose Up
+ALTER TABLE workout_entries
+    ADD COLUMN entry_type TEXT NOT NULL DEFAULT 'strength',
+    ADD COLUMN distance DOUBLE PRECISION,
+    ADD COLUMN distance_unit TEXT,
+    -- normalized for analytics, so totals do not care how a distance was typed
+    ADD COLUMN distance_meters DOUBLE PRECISION GENERATED ALWAYS AS (
+        CASE distance_unit
+            WHEN 'km' THEN distance * 1000
+            WHEN 'mi' THEN distance * 1609.344
+            ELSE distance
+        END
+    ) STORED,
+    ADD COLUMN elevation_gain_meters DOUBLE PRECISION,
+    ADD COLUMN avg_heart_rate INTEGER,
+    ADD COLUMN max_heart_rate INTEGER,
+    ADD COLUMN intervals JSONB;
+
+-- strength entries keep the old rule; cardio entries are timed and may have
+-- a distance, intervals and the rest
+ALTER TABLE workout_entries DROP CONSTRAINT valid_workout_entry;
+ALTER TABLE workout_entries ADD CONSTRAINT valid_workout_entry CHECK (
+    CASE entry_type
+        WHEN 'strength' THEN
+            (reps IS NOT NULL OR duration_seconds IS NOT NULL) AND
+            (reps IS NULL OR duration_seconds IS NULL) AND
+            distance IS NULL AND elevation_gain_meters IS NULL AND intervals IS NULL
+        WHEN 'cardio' THEN
+            duration_seconds IS NOT NULL AND reps IS NULL
+        ELSE FALSE
+    END AND
+    (distance IS NULL) = (distance_unit IS NULL) AND
+    (distance IS NULL OR distance > 0) AND
+    (distance_unit IS NULL OR distance_unit IN ('m', 'km', 'mi')) AND
+    (elevation_gain_meters IS NULL OR elevation_gain_meters >= 0) AND
+    (avg_heart_rate IS NULL OR avg_heart_rate BETWEEN 20 AND 250) AND
+    (max_heart_rate IS NULL OR max_heart_rate BETWEEN 20 AND 250) AND
+    (avg_heart_rate IS NULL OR max_heart_rate IS NULL OR avg_heart_rate <= max_heart_rate)
+);
+
+CREATE INDEX IF NOT EXISTS idx_workout_entries_cardio ON workout_entries (workout_id) WHERE distance_meters IS NOT NULL;
+
+-- +goose Down
+DROP INDEX idx_workout_entries_cardio;
+ALTER TABLE workout_entries DROP CONSTRAINT valid_workout_entry;
+ALTER TABLE workout_entries
+    DROP COLUMN intervals,
+    DROP COLUMN max_heart_rate,
+    DROP COLUMN avg_heart_rate,
+    DROP COLUMN elevation_gain_meters,
+    DROP COLUMN distance_meters,
+    DROP COLUMN distance_unit,
+    DROP COLUMN distance,
+    DROP COLUMN entry_type;
+ALTER TABLE workout_entries ADD CONSTRAINT valid_workout_entry CHECK (
+    (reps IS NOT NULL OR duration_seconds IS NOT NULL) AND
+    (reps IS NULL OR duration_seconds IS NULL)
+);
@@ -0,0 +1,37 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS heart_rate_settings (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    method TEXT NOT NULL,
+    max_heart_rate INTEGER,
+    resting_heart_rate INTEGER,
+    lactate_threshold_heart_rate INTEGER,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_heart_rate_settings CHECK (
+        CASE method
+            WHEN 'max' THEN max_heart_rate IS NOT NULL
+            WHEN 'reserve' THEN max_heart_rate IS NOT NULL AND resting_heart_rate IS NOT NULL
+            WHEN 'lthr' THEN lactate_threshold_heart_rate IS NOT NULL
+            ELSE FALSE
+        END AND
+        (resting_heart_rate IS NULL OR max_heart_rate IS NULL OR resting_heart_rate < max_heart_rate)
+    )
+);
+
+-- one row per workout with heart rate data, recomputed when the workout or
+-- the settings of its owner change
+CREATE TABLE IF NOT EXISTS workout_training_loads (
+    workout_id BIGINT PRIMARY KEY REFERENCES workouts(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    workout_version INTEGER NOT NULL,
+    settings_updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    method TEXT NOT NULL,
+    zone_seconds JSONB NOT NULL,
+    trimp DOUBLE PRECISION NOT NULL,
+    computed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_workout_training_loads_user_id ON workout_training_loads (user_id);
+
+-- +goose Down
+DROP TABLE workout_training_loads;
+DROP TABLE heart_rate_settings;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN body_weight_kg NUMERIC(5, 2)
+    CONSTRAINT valid_body_weight CHECK (body_weight_kg BETWEEN 20 AND 500);
+
+-- estimated calories are recomputed whenever the workout is saved
+ALTER TABLE workouts ADD COLUMN calories_source TEXT NOT NULL DEFAULT 'reported'
+    CONSTRAINT valid_calories_source CHECK (calories_source IN ('reported', 'estimated'));
+
+-- +goose Down
+ALTER TABLE workouts DROP COLUMN calories_source;
+ALTER TABLE users DROP COLUMN body_weight_kg;
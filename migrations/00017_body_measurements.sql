@@ -0,0 +1,29 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS body_measurements (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    measured_on DATE NOT NULL,
+    body_weight_kg NUMERIC(5, 2),
+    body_fat_percent NUMERIC(4, 1),
+    waist_cm NUMERIC(4, 1),
+    chest_cm NUMERIC(4, 1),
+    arm_cm NUMERIC(4, 1),
+    thigh_cm NUMERIC(4, 1),
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- one entry per day keeps the trend simple; a second weigh-in updates it
+    CONSTRAINT body_measurements_user_day UNIQUE (user_id, measured_on),
+    CONSTRAINT valid_body_measurement CHECK (
+        COALESCE(body_weight_kg, body_fat_percent, waist_cm, chest_cm, arm_cm, thigh_cm) IS NOT NULL AND
+        (body_weight_kg IS NULL OR body_weight_kg BETWEEN 20 AND 500) AND
+        (body_fat_percent IS NULL OR body_fat_percent BETWEEN 1 AND 75) AND
+        (waist_cm IS NULL OR waist_cm BETWEEN 10 AND 300) AND
+        (chest_cm IS NULL OR chest_cm BETWEEN 10 AND 300) AND
+        (arm_cm IS NULL OR arm_cm BETWEEN 10 AND 300) AND
+        (thigh_cm IS NULL OR thigh_cm BETWEEN 10 AND 300)
+    )
+);
+
+-- +goose Down
+DROP TABLE body_measurements;
@@ -0,0 +1,37 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS goals (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    goal_type TEXT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    exercise_name VARCHAR(255),
+    target DOUBLE PRECISION NOT NULL,
+    -- workouts and distance goals may repeat every calendar period
+    period TEXT,
+    -- where a lift or body weight goal started from, for its progress
+    baseline DOUBLE PRECISION,
+    starts_on DATE NOT NULL,
+    deadline DATE,
+    -- for a repeating goal, when it was last achieved
+    achieved_at TIMESTAMP WITH TIME ZONE,
+    checked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_goal CHECK (
+        target > 0 AND
+        (deadline IS NULL OR deadline >= starts_on) AND
+        (period IS NULL OR period IN ('week', 'month', 'quarter', 'year')) AND
+        CASE goal_type
+            WHEN 'lift' THEN exercise_name IS NOT NULL AND period IS NULL
+            WHEN 'workouts' THEN exercise_name IS NULL
+            WHEN 'distance' THEN exercise_name IS NULL
+            WHEN 'body_weight' THEN exercise_name IS NULL AND period IS NULL AND baseline IS NOT NULL
+            ELSE FALSE
+        END
+    )
+);
+
+CREATE INDEX IF NOT EXISTS idx_goals_user_id ON goals (user_id);
+
+-- +goose Down
+DROP TABLE goals;
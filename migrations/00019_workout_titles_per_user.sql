@@ -0,0 +1,12 @@
+-- +goose Up
+-- titles only need to tell apart the workouts of one user. A global unique
+-- title let one account's "Push Day" block every other account's, both for
+-- users and for imports, whose numbered fallback ran out on busy titles.
+-- The index keeps its name and stays partial, so trashed workouts still
+-- free their title and conflicts still map to the title field.
+DROP INDEX workouts_title_key;
+CREATE UNIQUE INDEX workouts_title_key ON workouts (user_id, title) WHERE deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX workouts_title_key;
+CREATE UNIQUE INDEX workouts_title_key ON workouts (title) WHERE deleted_at IS NULL;
@@ -0,0 +1,30 @@
+-- +goose Up
+-- a client that last synced before a workout went to the trash only learns
+-- it is gone from the tombstone written when the trash is purged, so every
+-- delete writes one; clients apply the same deletion twice at most
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_workout_tombstone() RETURNS trigger AS $$
+BEGIN
+    -- nothing to record when the whole account is being deleted
+    INSERT INTO sync_tombstones (user_id, entity, uuid)
+    SELECT u.id, 'workout', OLD.uuid FROM users u WHERE u.id = OLD.user_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_workout_tombstone() RETURNS trigger AS $$
+BEGIN
+    IF OLD.deleted_at IS NOT NULL THEN
+        RETURN OLD;
+    END IF;
+
+    -- nothing to record when the whole account is being deleted
+    INSERT INTO sync_tombstones (user_id, entity, uuid)
+    SELECT u.id, 'workout', OLD.uuid FROM users u WHERE u.id = OLD.user_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
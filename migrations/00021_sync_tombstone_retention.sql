@@ -0,0 +1,14 @@
+-- +goose Up
+-- sync_horizons holds, per user, the newest tombstone that was pruned. A
+-- cursor behind it may have missed deletions and has to start over.
+CREATE TABLE IF NOT EXISTS sync_horizons (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    change_txid BIGINT NOT NULL,
+    change_seq BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_tombstones_deleted_at ON sync_tombstones (deleted_at);
+
+-- +goose Down
+DROP INDEX idx_sync_tombstones_deleted_at;
+DROP TABLE sync_horizons;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- replays carry the headers of the first response, such as ETag and
+-- Location, not only its content type
+ALTER TABLE idempotency_keys ADD COLUMN response_headers JSONB;
+
+UPDATE idempotency_keys
+SET response_headers = jsonb_build_object('Content-Type', jsonb_build_array(content_type))
+WHERE content_type IS NOT NULL;
+
+ALTER TABLE idempotency_keys DROP COLUMN content_type;
+
+-- +goose Down
+ALTER TABLE idempotency_keys ADD COLUMN content_type TEXT;
+
+UPDATE idempotency_keys
+SET content_type = response_headers -> 'Content-Type' ->> 0
+WHERE response_headers IS NOT NULL;
+
+ALTER TABLE idempotency_keys DROP COLUMN response_headers;
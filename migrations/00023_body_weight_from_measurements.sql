@@ -0,0 +1,23 @@
+-- +goose Up
+-- body_measurements is the only record of body weight. A profile weight
+-- becomes a weigh-in on the day the profile was last saved, unless the
+-- user has weighed in already: weigh-ins always took precedence over it.
+INSERT INTO body_measurements (user_id, measured_on, body_weight_kg)
+SELECT u.id, (u.updated_at AT TIME ZONE 'UTC')::date, u.body_weight_kg
+FROM users u
+WHERE u.body_weight_kg IS NOT NULL
+    AND NOT EXISTS (SELECT 1 FROM body_measurements m WHERE m.user_id = u.id AND m.body_weight_kg IS NOT NULL)
+ON CONFLICT (user_id, measured_on) DO UPDATE SET body_weight_kg = EXCLUDED.body_weight_kg;
+
+ALTER TABLE users DROP COLUMN body_weight_kg;
+
+-- +goose Down
+ALTER TABLE users ADD COLUMN body_weight_kg NUMERIC(5, 2)
+    CONSTRAINT valid_body_weight CHECK (body_weight_kg BETWEEN 20 AND 500);
+
+UPDATE users u
+SET body_weight_kg = (
+    SELECT m.body_weight_kg FROM body_measurements m
+    WHERE m.user_id = u.id AND m.body_weight_kg IS NOT NULL
+    ORDER BY m.measured_on DESC LIMIT 1
+);
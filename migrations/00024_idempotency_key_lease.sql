@@ -0,0 +1,12 @@
+-- +goose Up
+-- a reservation holds its key until locked_until. A request that died
+-- without completing or releasing it, say with the process, leaves the key
+-- to be taken over by a retry once the lease ran out.
+ALTER TABLE idempotency_keys ADD COLUMN locked_until TIMESTAMP WITH TIME ZONE;
+
+UPDATE idempotency_keys
+SET locked_until = COALESCE(created_at, CURRENT_TIMESTAMP) + INTERVAL '30 seconds'
+WHERE completed_at IS NULL;
+
+-- +goose Down
+ALTER TABLE idempotency_keys DROP COLUMN locked_until;
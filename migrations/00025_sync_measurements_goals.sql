@@ -0,0 +1,54 @@
+-- +goose Up
+-- body measurements and goals join the sync feed, tracked like workouts
+ALTER TABLE body_measurements
+    ADD COLUMN uuid UUID NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN change_txid BIGINT NOT NULL DEFAULT txid_current(),
+    ADD COLUMN change_seq BIGINT NOT NULL DEFAULT nextval('change_seq'),
+    ADD CONSTRAINT body_measurements_uuid_key UNIQUE (uuid);
+
+ALTER TABLE goals
+    ADD COLUMN uuid UUID NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN change_txid BIGINT NOT NULL DEFAULT txid_current(),
+    ADD COLUMN change_seq BIGINT NOT NULL DEFAULT nextval('change_seq'),
+    ADD CONSTRAINT goals_uuid_key UNIQUE (uuid);
+
+CREATE INDEX IF NOT EXISTS idx_body_measurements_user_change ON body_measurements (user_id, change_txid, change_seq);
+CREATE INDEX IF NOT EXISTS idx_goals_user_change ON goals (user_id, change_txid, change_seq);
+
+CREATE TRIGGER body_measurements_track_change BEFORE UPDATE ON body_measurements
+    FOR EACH ROW EXECUTE FUNCTION track_change();
+
+-- the goal tracker stamps checked_at on every run; only edits and
+-- achievements are changes a client has to see
+CREATE TRIGGER goals_track_change BEFORE UPDATE ON goals
+    FOR EACH ROW
+    WHEN (OLD.updated_at IS DISTINCT FROM NEW.updated_at OR OLD.achieved_at IS DISTINCT FROM NEW.achieved_at)
+    EXECUTE FUNCTION track_change();
+
+-- the entity recorded is the trigger's argument
+-- +goose StatementBegin
+CREATE FUNCTION record_tombstone() RETURNS trigger AS $$
+BEGIN
+    -- nothing to record when the whole account is being deleted
+    INSERT INTO sync_tombstones (user_id, entity, uuid)
+    SELECT u.id, TG_ARGV[0], OLD.uuid FROM users u WHERE u.id = OLD.user_id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER body_measurements_tombstone AFTER DELETE ON body_measurements
+    FOR EACH ROW EXECUTE FUNCTION record_tombstone('body_measurement');
+
+CREATE TRIGGER goals_tombstone AFTER DELETE ON goals
+    FOR EACH ROW EXECUTE FUNCTION record_tombstone('goal');
+
+-- +goose Down
+DROP TRIGGER goals_tombstone ON goals;
+DROP TRIGGER body_measurements_tombstone ON body_measurements;
+DROP FUNCTION record_tombstone();
+DROP TRIGGER goals_track_change ON goals;
+DROP TRIGGER body_measurements_track_change ON body_measurements;
+DELETE FROM sync_tombstones WHERE entity IN ('body_measurement', 'goal');
+ALTER TABLE goals DROP COLUMN uuid, DROP COLUMN change_txid, DROP COLUMN change_seq;
+ALTER TABLE body_measurements DROP COLUMN uuid, DROP COLUMN change_txid, DROP COLUMN change_seq;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- workouts whose training load failed to compute go to the back of the
+-- stale list, so one that keeps failing doesn't hold up the others
+CREATE TABLE IF NOT EXISTS training_load_failures (
+    workout_id BIGINT PRIMARY KEY REFERENCES workouts(id) ON DELETE CASCADE,
+    failed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE training_load_failures;